@@ -33,6 +33,8 @@ type Config struct {
 	MountPath      string     `mapstructure:"mount_path"`
 	SourceAmi      string     `mapstructure:"source_ami"`
 
+	SourceAmiFilter awscommon.AMIFilterOptions `mapstructure:"source_ami_filter"`
+
 	tpl *packer.ConfigTemplate
 }
 
@@ -121,10 +123,17 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
-	if b.config.SourceAmi == "" {
-		errs = packer.MultiErrorAppend(errs, errors.New("source_ami is required."))
+	if b.config.SourceAmi == "" && b.config.SourceAmiFilter.Empty() {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("source_ami or source_ami_filter is required."))
+	} else if b.config.SourceAmi != "" && !b.config.SourceAmiFilter.Empty() {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("Only one of source_ami or source_ami_filter can be specified."))
 	}
 
+	errs = packer.MultiErrorAppend(
+		errs, b.config.SourceAmiFilter.Prepare(b.config.tpl, "source_ami_filter")...)
+
 	templates := map[string]*string{
 		"device_path": &b.config.DevicePath,
 		"source_ami":  &b.config.SourceAmi,
@@ -181,6 +190,12 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 
 	// Build the steps
 	steps := []multistep.Step{
+		&awscommon.StepDeregisterAMI{
+			ForceDeregister:     b.config.PackerForce,
+			ForceDeleteSnapshot: b.config.AMIForceDeleteSnapshot,
+			AMIName:             b.config.AMIName,
+			Regions:             b.config.AMIRegions,
+		},
 		&StepInstanceInfo{},
 		&StepSourceAMIInfo{},
 		&StepFlock{},
@@ -196,7 +211,11 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		&StepSnapshot{},
 		&StepRegisterAMI{},
 		&awscommon.StepAMIRegionCopy{
-			Regions: b.config.AMIRegions,
+			Name:                  b.config.AMIName,
+			Regions:               b.config.AMIRegions,
+			EncryptBootVolume:     b.config.AMIEncryptBootVolume,
+			KmsKeyIds:             b.config.AMIKmsKeyIds,
+			DeregisterUnencrypted: b.config.DeregisterUnencrypted(),
 		},
 		&awscommon.StepModifyAMIAttributes{
 			Description: b.config.AMIDescription,
@@ -204,7 +223,9 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			Groups:      b.config.AMIGroups,
 		},
 		&awscommon.StepCreateTags{
-			Tags: b.config.AMITags,
+			Tags:         b.config.AMITags,
+			SnapshotTags: b.config.SnapshotTags,
+			Tpl:          b.config.tpl,
 		},
 	}
 
@@ -117,3 +117,36 @@ func TestBuilderPrepare_CommandWrapper(t *testing.T) {
 		t.Errorf("err: %s", err)
 	}
 }
+
+func TestBuilderPrepare_SourceAmiFilter(t *testing.T) {
+	b := &Builder{}
+	config := testConfig()
+
+	delete(config, "source_ami")
+	config["source_ami_filter"] = map[string]interface{}{
+		"name":        "ubuntu/images/*",
+		"owners":      []string{"099720109477"},
+		"most_recent": true,
+	}
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !b.config.SourceAmiFilter.MostRecent {
+		t.Fatalf("bad: %#v", b.config.SourceAmiFilter)
+	}
+
+	config["source_ami"] = "foo"
+	b = &Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
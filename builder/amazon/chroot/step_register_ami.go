@@ -29,8 +29,15 @@ func (s *StepRegisterAMI) Run(state multistep.StateBag) multistep.StepAction {
 		blockDevices[i] = newDevice
 	}
 
+	// If the boot volume is to be encrypted, ami_name goes to the
+	// encrypted copy instead.
+	amiName := config.AMIName
+	if config.AMIEncryptBootVolume {
+		amiName = awscommon.UnencryptedAMIName()
+	}
+
 	registerOpts := &ec2.RegisterImage{
-		Name:           config.AMIName,
+		Name:           amiName,
 		Architecture:   image.Architecture,
 		KernelId:       image.KernelId,
 		RamdiskId:      image.RamdiskId,
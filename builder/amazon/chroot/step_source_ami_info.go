@@ -19,23 +19,38 @@ func (s *StepSourceAMIInfo) Run(state multistep.StateBag) multistep.StepAction {
 	ec2conn := state.Get("ec2").(*ec2.EC2)
 	ui := state.Get("ui").(packer.Ui)
 
-	ui.Say("Inspecting the source AMI...")
-	imageResp, err := ec2conn.Images([]string{config.SourceAmi}, ec2.NewFilter())
-	if err != nil {
-		err := fmt.Errorf("Error querying AMI: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
-	}
+	var image *ec2.Image
+	if config.SourceAmi == "" {
+		ui.Say("Finding the source AMI matching source_ami_filter...")
+		var err error
+		image, err = config.SourceAmiFilter.FindImage(ec2conn)
+		if err != nil {
+			err := fmt.Errorf("Error finding source AMI: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 
-	if len(imageResp.Images) == 0 {
-		err := fmt.Errorf("Source AMI '%s' was not found!", config.SourceAmi)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
-	}
+		ui.Message(fmt.Sprintf("Found source AMI: %s (%s)", image.Id, image.Name))
+	} else {
+		ui.Say("Inspecting the source AMI...")
+		imageResp, err := ec2conn.Images([]string{config.SourceAmi}, ec2.NewFilter())
+		if err != nil {
+			err := fmt.Errorf("Error querying AMI: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 
-	image := &imageResp.Images[0]
+		if len(imageResp.Images) == 0 {
+			err := fmt.Errorf("Source AMI '%s' was not found!", config.SourceAmi)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		image = &imageResp.Images[0]
+	}
 
 	// It must be EBS-backed otherwise the build won't work
 	if image.RootDeviceType != "ebs" {
@@ -3,6 +3,7 @@ package common
 import (
 	"fmt"
 	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/packer/common/uuid"
 	"github.com/mitchellh/packer/packer"
 )
 
@@ -15,6 +16,26 @@ type AMIConfig struct {
 	AMIProductCodes []string          `mapstructure:"ami_product_codes"`
 	AMIRegions      []string          `mapstructure:"ami_regions"`
 	AMITags         map[string]string `mapstructure:"tags"`
+	SnapshotTags    map[string]string `mapstructure:"snapshot_tags"`
+
+	AMIForceDeleteSnapshot bool `mapstructure:"force_delete_snapshot"`
+
+	AMIEncryptBootVolume     bool              `mapstructure:"encrypt_boot"`
+	AMIKmsKeyIds             map[string]string `mapstructure:"kms_key_id"`
+	AMIDeregisterUnencrypted *bool             `mapstructure:"deregister_unencrypted"`
+}
+
+// DeregisterUnencrypted is true if the unencrypted AMI is to be
+// deregistered once its encrypted copies are ready.
+func (c *AMIConfig) DeregisterUnencrypted() bool {
+	return c.AMIDeregisterUnencrypted != nil && *c.AMIDeregisterUnencrypted
+}
+
+// UnencryptedAMIName returns a name for the AMI that is built when the
+// boot volume is to be encrypted. The encrypted copy of that AMI in the
+// build region is named ami_name, and AMI names are unique per region.
+func UnencryptedAMIName() string {
+	return fmt.Sprintf("packer-unencrypted-%s", uuid.TimeOrderedUUID())
 }
 
 func (c *AMIConfig) Prepare(t *packer.ConfigTemplate) []error {
@@ -88,26 +109,48 @@ func (c *AMIConfig) Prepare(t *packer.ConfigTemplate) []error {
 		c.AMIRegions = regions
 	}
 
-	newTags := make(map[string]string)
-	for k, v := range c.AMITags {
-		k, err := t.Process(k, nil)
-		if err != nil {
-			errs = append(errs,
-				fmt.Errorf("Error processing tag key %s: %s", k, err))
+	newKmsKeyIds := make(map[string]string)
+	for region, keyId := range c.AMIKmsKeyIds {
+		if _, ok := aws.Regions[region]; !ok {
+			errs = append(errs, fmt.Errorf("Unknown region in kms_key_id: %s", region))
 			continue
 		}
 
-		v, err := t.Process(v, nil)
+		keyId, err := t.Process(keyId, nil)
 		if err != nil {
 			errs = append(errs,
-				fmt.Errorf("Error processing tag value '%s': %s", v, err))
+				fmt.Errorf("Error processing kms_key_id for %s: %s", region, err))
 			continue
 		}
 
-		newTags[k] = v
+		newKmsKeyIds[region] = keyId
 	}
 
-	c.AMITags = newTags
+	c.AMIKmsKeyIds = newKmsKeyIds
+
+	if c.AMIEncryptBootVolume {
+		// Don't leave an unencrypted AMI behind unless asked to
+		if c.AMIDeregisterUnencrypted == nil {
+			deregister := true
+			c.AMIDeregisterUnencrypted = &deregister
+		}
+	} else {
+		if len(c.AMIKmsKeyIds) > 0 {
+			errs = append(errs, fmt.Errorf("kms_key_id requires encrypt_boot to be true"))
+		}
+
+		if c.AMIDeregisterUnencrypted != nil && *c.AMIDeregisterUnencrypted {
+			errs = append(errs, fmt.Errorf(
+				"deregister_unencrypted requires encrypt_boot to be true"))
+		}
+	}
+
+	var tagErrs []error
+	c.AMITags, tagErrs = PrepareTags(t, c.AMITags, "tags")
+	errs = append(errs, tagErrs...)
+
+	c.SnapshotTags, tagErrs = PrepareTags(t, c.SnapshotTags, "snapshot_tags")
+	errs = append(errs, tagErrs...)
 
 	if len(errs) > 0 {
 		return errs
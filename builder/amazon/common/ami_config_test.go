@@ -45,3 +45,70 @@ func TestAMIConfigPrepare_regions(t *testing.T) {
 		t.Fatalf("bad: %#v", c.AMIRegions)
 	}
 }
+
+func TestAMIConfigPrepare_kmsKeyIds(t *testing.T) {
+	c := testAMIConfig()
+	c.AMIKmsKeyIds = map[string]string{"us-east-1": "foo"}
+	if err := c.Prepare(nil); err == nil {
+		t.Fatal("should have error without encrypt_boot")
+	}
+
+	c.AMIEncryptBootVolume = true
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+
+	c.AMIKmsKeyIds = map[string]string{"foo": "bar"}
+	if err := c.Prepare(nil); err == nil {
+		t.Fatal("should have error for unknown region")
+	}
+}
+
+func TestAMIConfigPrepare_deregisterUnencrypted(t *testing.T) {
+	deregister := true
+	c := testAMIConfig()
+	c.AMIDeregisterUnencrypted = &deregister
+	if err := c.Prepare(nil); err == nil {
+		t.Fatal("should have error without encrypt_boot")
+	}
+
+	c.AMIEncryptBootVolume = true
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+
+	// Defaults to true with encrypt_boot
+	c = testAMIConfig()
+	c.AMIEncryptBootVolume = true
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if !c.DeregisterUnencrypted() {
+		t.Fatal("should deregister unencrypted by default")
+	}
+
+	// Can be turned off
+	deregister = false
+	c = testAMIConfig()
+	c.AMIEncryptBootVolume = true
+	c.AMIDeregisterUnencrypted = &deregister
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+	if c.DeregisterUnencrypted() {
+		t.Fatal("should keep unencrypted")
+	}
+}
+
+func TestAMIConfigPrepare_snapshotTags(t *testing.T) {
+	c := testAMIConfig()
+	c.SnapshotTags = map[string]string{"Region": "{{.BuildRegion}}"}
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("shouldn't have err: %s", err)
+	}
+
+	c.SnapshotTags = map[string]string{"Region": "{{.BuildRegion"}
+	if err := c.Prepare(nil); err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,159 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/packer/packer"
+	"sort"
+)
+
+// AMIFilterOptions describes how to look up an AMI by its attributes,
+// rather than by ID, so that templates can always build from the latest
+// version of an image.
+type AMIFilterOptions struct {
+	Name               string            `mapstructure:"name"`
+	Owners             []string          `mapstructure:"owners"`
+	Tags               map[string]string `mapstructure:"tags"`
+	VirtualizationType string            `mapstructure:"virtualization_type"`
+	MostRecent         bool              `mapstructure:"most_recent"`
+}
+
+// Empty tells whether no filter was configured at all.
+func (f *AMIFilterOptions) Empty() bool {
+	return f.Name == "" && len(f.Owners) == 0 && len(f.Tags) == 0 &&
+		f.VirtualizationType == ""
+}
+
+// Prepare processes the templates in the filter and validates it. The
+// name is used in error messages as the configuration key of the filter.
+func (f *AMIFilterOptions) Prepare(t *packer.ConfigTemplate, name string) []error {
+	var err error
+	if t == nil {
+		t, err = packer.NewConfigTemplate()
+		if err != nil {
+			return []error{err}
+		}
+	}
+
+	errs := make([]error, 0)
+	f.Name, err = t.Process(f.Name, nil)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("Error processing %s name: %s", name, err))
+	}
+
+	for i, owner := range f.Owners {
+		f.Owners[i], err = t.Process(owner, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf(
+				"Error processing %s owners[%d]: %s", name, i, err))
+		}
+	}
+
+	newTags := make(map[string]string)
+	for k, v := range f.Tags {
+		k, err := t.Process(k, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf(
+				"Error processing %s tag key %s: %s", name, k, err))
+			continue
+		}
+
+		v, err := t.Process(v, nil)
+		if err != nil {
+			errs = append(errs, fmt.Errorf(
+				"Error processing %s tag value '%s': %s", name, v, err))
+			continue
+		}
+
+		newTags[k] = v
+	}
+
+	f.Tags = newTags
+
+	if f.VirtualizationType != "" &&
+		f.VirtualizationType != "hvm" && f.VirtualizationType != "paravirtual" {
+		errs = append(errs, fmt.Errorf(
+			"%s virtualization_type must be \"hvm\" or \"paravirtual\"", name))
+	}
+
+	return errs
+}
+
+// FindImage looks up the AMI matching the filter. It is an error if no
+// AMI matches, or if more than one does unless MostRecent is set.
+func (f *AMIFilterOptions) FindImage(conn *ec2.EC2) (*ec2.Image, error) {
+	id, err := f.findImageId(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// The rest of the builder works with goamz images, so look the
+	// chosen one up again by ID.
+	resp, err := conn.Images([]string{id}, ec2.NewFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.Images) == 0 {
+		return nil, fmt.Errorf("AMI (%s) was not found.", id)
+	}
+
+	return &resp.Images[0], nil
+}
+
+// findImageId returns the ID of the AMI FindImage looks up.
+func (f *AMIFilterOptions) findImageId(conn *ec2.EC2) (string, error) {
+	// The owners are passed as is, since DescribeImages takes account
+	// IDs, aliases such as "amazon", and "self" for the caller's account.
+	images, err := describeImages(conn, f.Owners, f.filters())
+	if err != nil {
+		return "", err
+	}
+
+	if len(images) == 0 {
+		return "", errors.New("No AMI was found matching the filters.")
+	}
+
+	if len(images) > 1 {
+		if !f.MostRecent {
+			return "", fmt.Errorf(
+				"%d AMIs were found matching the filters. Use a more specific "+
+					"filter or set most_recent to true.", len(images))
+		}
+
+		// Creation dates are ISO 8601 timestamps, which sort as strings.
+		sort.Sort(imagesByCreationDate(images))
+	}
+
+	return images[len(images)-1].Id, nil
+}
+
+// filters returns the filters for all the options except the owners.
+func (f *AMIFilterOptions) filters() map[string][]string {
+	filters := map[string][]string{
+		"state": {"available"},
+	}
+
+	if f.Name != "" {
+		filters["name"] = []string{f.Name}
+	}
+
+	for k, v := range f.Tags {
+		filters["tag:"+k] = []string{v}
+	}
+
+	if f.VirtualizationType != "" {
+		filters["virtualization-type"] = []string{f.VirtualizationType}
+	}
+
+	return filters
+}
+
+type imagesByCreationDate []describedImage
+
+func (a imagesByCreationDate) Len() int      { return len(a) }
+func (a imagesByCreationDate) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a imagesByCreationDate) Less(i, j int) bool {
+	return a[i].CreationDate < a[j].CreationDate
+}
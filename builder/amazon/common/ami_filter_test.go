@@ -0,0 +1,106 @@
+package common
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"testing"
+)
+
+func TestAMIFilterOptions_Empty(t *testing.T) {
+	var f AMIFilterOptions
+	if !f.Empty() {
+		t.Fatal("should be empty")
+	}
+
+	f.MostRecent = true
+	if !f.Empty() {
+		t.Fatal("most_recent alone should be empty")
+	}
+
+	f.Owners = []string{"amazon"}
+	if f.Empty() {
+		t.Fatal("should not be empty")
+	}
+}
+
+func TestAMIFilterOptionsPrepare(t *testing.T) {
+	f := AMIFilterOptions{
+		Name:               "ubuntu/images/*",
+		Owners:             []string{"099720109477", "amazon"},
+		Tags:               map[string]string{"Name": "foo"},
+		VirtualizationType: "hvm",
+	}
+
+	if errs := f.Prepare(nil, "source_ami_filter"); len(errs) != 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	f.VirtualizationType = "foo"
+	if errs := f.Prepare(nil, "source_ami_filter"); len(errs) != 1 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	f.VirtualizationType = ""
+	f.Owners = []string{"self"}
+	if errs := f.Prepare(nil, "source_ami_filter"); len(errs) != 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+}
+
+func TestAMIFilterOptionsFindImageId(t *testing.T) {
+	conn, closeFn := testEC2Query(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		switch {
+		case q.Get("Action") == "DescribeImages":
+			if q.Get("Owner.1") != "self" || q.Get("Owner.2") != "amazon" {
+				t.Errorf("bad owners: %#v", q)
+			}
+			if q.Get("Filter.1.Name") != "name" || q.Get("Filter.1.Value.1") != "foo-*" {
+				t.Errorf("bad filters: %#v", q)
+			}
+
+			fmt.Fprint(w, `<DescribeImagesResponse><imagesSet>
+				<item><imageId>ami-b</imageId><creationDate>2014-02-01T00:00:00.000Z</creationDate></item>
+				<item><imageId>ami-c</imageId><creationDate>2014-03-01T00:00:00.000Z</creationDate></item>
+				<item><imageId>ami-a</imageId><creationDate>2014-01-01T00:00:00.000Z</creationDate></item>
+				</imagesSet></DescribeImagesResponse>`)
+		default:
+			t.Errorf("unexpected request: %#v", q)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+	defer closeFn()
+
+	f := AMIFilterOptions{
+		Name:   "foo-*",
+		Owners: []string{"self", "amazon"},
+	}
+
+	if _, err := f.findImageId(conn); err == nil {
+		t.Fatal("should error without most_recent")
+	}
+
+	f.MostRecent = true
+	id, err := f.findImageId(conn)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if id != "ami-c" {
+		t.Fatalf("bad: %s", id)
+	}
+}
+
+func TestImagesByCreationDate(t *testing.T) {
+	images := []describedImage{
+		{Id: "b", CreationDate: "2014-02-01T00:00:00.000Z"},
+		{Id: "c", CreationDate: "2014-03-01T00:00:00.000Z"},
+		{Id: "a", CreationDate: "2014-01-01T00:00:00.000Z"},
+	}
+
+	sort.Sort(imagesByCreationDate(images))
+	if images[len(images)-1].Id != "c" {
+		t.Fatalf("bad: %#v", images)
+	}
+}
@@ -0,0 +1,245 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/ec2"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The version of the EC2 API that EC2Query calls. goamz is pinned to an
+// older version, which predates encrypted copies of AMIs and VM import.
+// EC2Query is only meant to bridge that gap until goamz catches up, so
+// calls that goamz can make should go through goamz.
+const ec2APIVersion = "2016-11-15"
+
+// ec2QueryClient is the HTTP client of EC2Query. Unlike http.Get, it
+// gives up on an endpoint that stops responding.
+var ec2QueryClient = &http.Client{Timeout: 60 * time.Second}
+
+// ec2QueryRetries is how many times a throttled or unavailable request
+// is retried, and ec2QueryRetryDelay how long to wait before the first
+// retry. The delay doubles for every retry after that.
+var ec2QueryRetries = 5
+var ec2QueryRetryDelay = 1 * time.Second
+
+// EC2Query makes an EC2 API call that goamz has no method for, or that
+// needs parameters its methods don't send. The request is signed with the
+// credentials of the connection the same way goamz signs its own, and
+// the XML response is decoded into resp. API errors are returned as
+// *ec2.Error, like goamz does.
+//
+// Requests that are throttled, or that fail because EC2 is unavailable,
+// are retried with a backoff. Nothing else is retried, as the request
+// may have taken effect.
+func EC2Query(conn *ec2.EC2, params map[string]string, resp interface{}) error {
+	delay := ec2QueryRetryDelay
+	for i := 0; ; i++ {
+		err := ec2Query(conn, params, resp)
+		if err == nil || i >= ec2QueryRetries || !retryableEC2Error(err) {
+			return err
+		}
+
+		log.Printf("Retrying %s in %s: %s", params["Action"], delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// retryableEC2Error is true if err means that EC2 didn't act on the
+// request, so that it can be made again.
+func retryableEC2Error(err error) bool {
+	ec2err, ok := err.(*ec2.Error)
+	if !ok {
+		return false
+	}
+
+	switch ec2err.Code {
+	case "RequestLimitExceeded", "Throttling", "Unavailable", "InternalError":
+		return true
+	}
+
+	return ec2err.StatusCode == http.StatusServiceUnavailable
+}
+
+func ec2Query(conn *ec2.EC2, params map[string]string, resp interface{}) error {
+	endpoint, err := url.Parse(conn.Region.EC2Endpoint)
+	if err != nil {
+		return err
+	}
+	if endpoint.Path == "" {
+		endpoint.Path = "/"
+	}
+
+	query := make(map[string]string)
+	for k, v := range params {
+		query[k] = v
+	}
+	query["Version"] = ec2APIVersion
+	query["Timestamp"] = time.Now().UTC().Format(time.RFC3339)
+	signV2(conn.Auth, "GET", endpoint, query)
+
+	values := make(url.Values)
+	for k, v := range query {
+		values.Set(k, v)
+	}
+	endpoint.RawQuery = values.Encode()
+
+	r, err := ec2QueryClient.Get(endpoint.String())
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		var errResp struct {
+			RequestId string `xml:"RequestID"`
+			Errors    []struct {
+				Code    string
+				Message string
+			} `xml:"Errors>Error"`
+		}
+
+		ec2err := &ec2.Error{StatusCode: r.StatusCode, Message: r.Status}
+		if err := xml.NewDecoder(r.Body).Decode(&errResp); err == nil && len(errResp.Errors) > 0 {
+			ec2err.Code = errResp.Errors[0].Code
+			ec2err.Message = errResp.Errors[0].Message
+			ec2err.RequestId = errResp.RequestId
+		}
+
+		return ec2err
+	}
+
+	return xml.NewDecoder(r.Body).Decode(resp)
+}
+
+// signV2 adds the signature version 2 parameters to a query, which is
+// how goamz signs EC2 requests.
+func signV2(auth aws.Auth, method string, endpoint *url.URL, params map[string]string) {
+	params["AWSAccessKeyId"] = auth.AccessKey
+	params["SignatureVersion"] = "2"
+	params["SignatureMethod"] = "HmacSHA256"
+	if auth.Token != "" {
+		params["SecurityToken"] = auth.Token
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = awsEscape(k) + "=" + awsEscape(params[k])
+	}
+
+	payload := strings.Join([]string{
+		method,
+		strings.ToLower(endpoint.Host),
+		endpoint.Path,
+		strings.Join(pairs, "&"),
+	}, "\n")
+
+	hash := hmac.New(sha256.New, []byte(auth.SecretKey))
+	hash.Write([]byte(payload))
+	params["Signature"] = base64.StdEncoding.EncodeToString(hash.Sum(nil))
+}
+
+// awsEscape percent-encodes everything but the unreserved characters of
+// RFC 3986, as AWS signatures require.
+func awsEscape(s string) string {
+	var result []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			result = append(result, c)
+		} else {
+			result = append(result, []byte(fmt.Sprintf("%%%02X", c))...)
+		}
+	}
+
+	return string(result)
+}
+
+// copyImage copies an AMI from the source region to the region of the
+// connection, returning the ID of the copy. The copy is named name.
+// goamz can't encrypt copies, so the call is made with EC2Query.
+func copyImage(conn *ec2.EC2, sourceRegion, imageId, name string, encrypted bool, kmsKeyId string) (string, error) {
+	params := map[string]string{
+		"Action":        "CopyImage",
+		"SourceRegion":  sourceRegion,
+		"SourceImageId": imageId,
+		"Name":          name,
+	}
+
+	if encrypted {
+		params["Encrypted"] = "true"
+		if kmsKeyId != "" {
+			params["KmsKeyId"] = kmsKeyId
+		}
+	}
+
+	var resp struct {
+		ImageId string `xml:"imageId"`
+	}
+	if err := EC2Query(conn, params, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.ImageId, nil
+}
+
+// describedImage is what is needed of an AMI when looking AMIs up with
+// describeImages.
+type describedImage struct {
+	Id           string   `xml:"imageId"`
+	Name         string   `xml:"name"`
+	OwnerId      string   `xml:"imageOwnerId"`
+	CreationDate string   `xml:"creationDate"`
+	SnapshotIds  []string `xml:"blockDeviceMapping>item>ebs>snapshotId"`
+}
+
+// describeImages returns the AMIs of the owners that match the filters.
+// Owners are account IDs, aliases such as "amazon", or "self" for the
+// AMIs of the caller's account. goamz can't restrict the owners of the
+// AMIs it describes, so the call is made with EC2Query.
+func describeImages(conn *ec2.EC2, owners []string, filters map[string][]string) ([]describedImage, error) {
+	params := map[string]string{"Action": "DescribeImages"}
+	for i, owner := range owners {
+		params[fmt.Sprintf("Owner.%d", i+1)] = owner
+	}
+
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		prefix := fmt.Sprintf("Filter.%d.", i+1)
+		params[prefix+"Name"] = name
+		for j, value := range filters[name] {
+			params[fmt.Sprintf("%sValue.%d", prefix, j+1)] = value
+		}
+	}
+
+	var resp struct {
+		Images []describedImage `xml:"imagesSet>item"`
+	}
+	if err := EC2Query(conn, params, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Images, nil
+}
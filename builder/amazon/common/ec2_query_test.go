@@ -0,0 +1,251 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/ec2"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSignV2(t *testing.T) {
+	endpoint, _ := url.Parse("https://EC2.us-east-1.amazonaws.com/")
+	params := map[string]string{
+		"Action":    "DescribeImages",
+		"Filter.1":  "a b/c~",
+		"Timestamp": "2014-01-01T00:00:00Z",
+	}
+	signV2(aws.Auth{AccessKey: "access", SecretKey: "secret", Token: "token"}, "GET", endpoint, params)
+
+	payload := "GET\nec2.us-east-1.amazonaws.com\n/\n" +
+		"AWSAccessKeyId=access&Action=DescribeImages&Filter.1=a%20b%2Fc~&" +
+		"SecurityToken=token&SignatureMethod=HmacSHA256&SignatureVersion=2&" +
+		"Timestamp=2014-01-01T00%3A00%3A00Z"
+	hash := hmac.New(sha256.New, []byte("secret"))
+	hash.Write([]byte(payload))
+	expected := base64.StdEncoding.EncodeToString(hash.Sum(nil))
+
+	if params["Signature"] != expected {
+		t.Fatalf("bad: %s != %s", params["Signature"], expected)
+	}
+}
+
+func testEC2Query(handler http.HandlerFunc) (*ec2.EC2, func()) {
+	server := httptest.NewServer(handler)
+	region := aws.Region{
+		Name:        "us-west-2",
+		EC2Endpoint: server.URL,
+	}
+
+	return ec2.New(aws.Auth{AccessKey: "foo", SecretKey: "bar"}, region), server.Close
+}
+
+func TestCopyImage(t *testing.T) {
+	var form url.Values
+	conn, closeFn := testEC2Query(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.Form
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<CopyImageResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <requestId>60bc441d-fa2c-494d-b155-5d6a3EXAMPLE</requestId>
+  <imageId>ami-4d3c2b1a</imageId>
+</CopyImageResponse>`))
+	})
+	defer closeFn()
+
+	imageId, err := copyImage(conn, "us-east-1", "ami-1a2b3c4d", "packer-foo", true, "alias/foo")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if imageId != "ami-4d3c2b1a" {
+		t.Fatalf("bad: %s", imageId)
+	}
+
+	expected := map[string]string{
+		"Action":           "CopyImage",
+		"SourceRegion":     "us-east-1",
+		"SourceImageId":    "ami-1a2b3c4d",
+		"Name":             "packer-foo",
+		"Encrypted":        "true",
+		"KmsKeyId":         "alias/foo",
+		"Version":          ec2APIVersion,
+		"AWSAccessKeyId":   "foo",
+		"SignatureVersion": "2",
+	}
+	for k, v := range expected {
+		if form.Get(k) != v {
+			t.Fatalf("bad %s: %#v", k, form)
+		}
+	}
+	if form.Get("Signature") == "" {
+		t.Fatal("should be signed")
+	}
+
+	// Unencrypted copies leave the encryption parameters out
+	if _, err := copyImage(conn, "us-east-1", "ami-1a2b3c4d", "packer-foo", false, ""); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, ok := form["Encrypted"]; ok {
+		t.Fatalf("bad: %#v", form)
+	}
+}
+
+func TestEC2Query_error(t *testing.T) {
+	conn, closeFn := testEC2Query(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<Response>
+  <Errors>
+    <Error>
+      <Code>InvalidAMIID.NotFound</Code>
+      <Message>The image id '[ami-1a2b3c4d]' does not exist</Message>
+    </Error>
+  </Errors>
+  <RequestID>ab123</RequestID>
+</Response>`))
+	})
+	defer closeFn()
+
+	_, err := copyImage(conn, "us-east-1", "ami-1a2b3c4d", "packer-foo", false, "")
+	ec2err, ok := err.(*ec2.Error)
+	if !ok {
+		t.Fatalf("bad: %#v", err)
+	}
+
+	if ec2err.Code != "InvalidAMIID.NotFound" || ec2err.RequestId != "ab123" ||
+		ec2err.StatusCode != http.StatusBadRequest {
+		t.Fatalf("bad: %#v", ec2err)
+	}
+}
+
+func TestEC2Query_retry(t *testing.T) {
+	defer func(d time.Duration) { ec2QueryRetryDelay = d }(ec2QueryRetryDelay)
+	ec2QueryRetryDelay = time.Millisecond
+
+	requests := 0
+	conn, closeFn := testEC2Query(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<Response>
+  <Errors>
+    <Error>
+      <Code>RequestLimitExceeded</Code>
+      <Message>Request limit exceeded.</Message>
+    </Error>
+  </Errors>
+  <RequestID>ab123</RequestID>
+</Response>`))
+			return
+		}
+
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<CopyImageResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <imageId>ami-4d3c2b1a</imageId>
+</CopyImageResponse>`))
+	})
+	defer closeFn()
+
+	imageId, err := copyImage(conn, "us-east-1", "ami-1a2b3c4d", "packer-foo", false, "")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if imageId != "ami-4d3c2b1a" {
+		t.Fatalf("bad: %s", imageId)
+	}
+	if requests != 3 {
+		t.Fatalf("bad: %d", requests)
+	}
+
+	// Other errors aren't retried
+	requests = 0
+	conn, closeFn = testEC2Query(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	defer closeFn()
+
+	if _, err := copyImage(conn, "us-east-1", "ami-1a2b3c4d", "packer-foo", false, ""); err == nil {
+		t.Fatal("should have error")
+	}
+	if requests != 1 {
+		t.Fatalf("bad: %d", requests)
+	}
+}
+
+func TestDescribeImages(t *testing.T) {
+	var form url.Values
+	conn, closeFn := testEC2Query(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.Form
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<DescribeImagesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <requestId>59dbff89-35bd-4eac-99ed-be587EXAMPLE</requestId>
+  <imagesSet>
+    <item>
+      <imageId>ami-1a2b3c4d</imageId>
+      <name>packer-foo</name>
+      <imageOwnerId>123456789012</imageOwnerId>
+      <creationDate>2014-01-01T00:00:00.000Z</creationDate>
+      <blockDeviceMapping>
+        <item>
+          <deviceName>/dev/sda1</deviceName>
+          <ebs>
+            <snapshotId>snap-1a2b3c4d</snapshotId>
+            <volumeSize>8</volumeSize>
+          </ebs>
+        </item>
+        <item>
+          <deviceName>/dev/sdb</deviceName>
+          <virtualName>ephemeral0</virtualName>
+        </item>
+      </blockDeviceMapping>
+    </item>
+  </imagesSet>
+</DescribeImagesResponse>`))
+	})
+	defer closeFn()
+
+	images, err := describeImages(conn, []string{"self"}, map[string][]string{
+		"name":  []string{"packer-foo"},
+		"state": []string{"available", "pending"},
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"Action":           "DescribeImages",
+		"Owner.1":          "self",
+		"Filter.1.Name":    "name",
+		"Filter.1.Value.1": "packer-foo",
+		"Filter.2.Name":    "state",
+		"Filter.2.Value.1": "available",
+		"Filter.2.Value.2": "pending",
+	}
+	for k, v := range expected {
+		if form.Get(k) != v {
+			t.Fatalf("bad %s: %#v", k, form)
+		}
+	}
+
+	if len(images) != 1 {
+		t.Fatalf("bad: %#v", images)
+	}
+
+	image := images[0]
+	if image.Id != "ami-1a2b3c4d" || image.OwnerId != "123456789012" ||
+		image.CreationDate != "2014-01-01T00:00:00.000Z" {
+		t.Fatalf("bad: %#v", image)
+	}
+	if len(image.SnapshotIds) != 1 || image.SnapshotIds[0] != "snap-1a2b3c4d" {
+		t.Fatalf("bad: %#v", image.SnapshotIds)
+	}
+}
@@ -0,0 +1,76 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+)
+
+// ImportImageDisk is a disk image in S3 to import as an AMI.
+type ImportImageDisk struct {
+	Description string
+	Format      string
+	S3Bucket    string
+	S3Key       string
+}
+
+// ImportImageTask is the state of a task that imports disk images as an
+// AMI. The ImageId is only set once the task is completed.
+type ImportImageTask struct {
+	ImportTaskId  string `xml:"importTaskId"`
+	ImageId       string `xml:"imageId"`
+	Status        string `xml:"status"`
+	StatusMessage string `xml:"statusMessage"`
+	Progress      string `xml:"progress"`
+}
+
+// ImportImage starts a task that imports the disks as an AMI, returning
+// the ID of the task. goamz has no support for VM import, so the call is
+// made with EC2Query.
+func ImportImage(conn *ec2.EC2, description, licenseType string, disks []ImportImageDisk) (string, error) {
+	params := map[string]string{"Action": "ImportImage"}
+	if description != "" {
+		params["Description"] = description
+	}
+	if licenseType != "" {
+		params["LicenseType"] = licenseType
+	}
+
+	for i, disk := range disks {
+		prefix := fmt.Sprintf("DiskContainer.%d.", i+1)
+		if disk.Description != "" {
+			params[prefix+"Description"] = disk.Description
+		}
+		params[prefix+"Format"] = disk.Format
+		params[prefix+"UserBucket.S3Bucket"] = disk.S3Bucket
+		params[prefix+"UserBucket.S3Key"] = disk.S3Key
+	}
+
+	var resp ImportImageTask
+	if err := EC2Query(conn, params, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.ImportTaskId, nil
+}
+
+// DescribeImportImageTask returns the state of an import task, or nil
+// if there is no such task.
+func DescribeImportImageTask(conn *ec2.EC2, importTaskId string) (*ImportImageTask, error) {
+	params := map[string]string{
+		"Action":         "DescribeImportImageTasks",
+		"ImportTaskId.1": importTaskId,
+	}
+
+	var resp struct {
+		Tasks []ImportImageTask `xml:"importImageTaskSet>item"`
+	}
+	if err := EC2Query(conn, params, &resp); err != nil {
+		return nil, err
+	}
+
+	if len(resp.Tasks) == 0 {
+		return nil, nil
+	}
+
+	return &resp.Tasks[0], nil
+}
@@ -5,29 +5,37 @@ import (
 	"fmt"
 	"github.com/mitchellh/packer/packer"
 	"os"
+	"strconv"
 	"time"
 )
 
 // RunConfig contains configuration for running an instance from a source
 // AMI and details on how to access that launched image.
 type RunConfig struct {
-	SourceAmi            string   `mapstructure:"source_ami"`
-	IamInstanceProfile   string   `mapstructure:"iam_instance_profile"`
-	InstanceType         string   `mapstructure:"instance_type"`
-	UserData             string   `mapstructure:"user_data"`
-	UserDataFile         string   `mapstructure:"user_data_file"`
-	RawSSHTimeout        string   `mapstructure:"ssh_timeout"`
-	SSHUsername          string   `mapstructure:"ssh_username"`
-	SSHPort              int      `mapstructure:"ssh_port"`
-	SecurityGroupId      string   `mapstructure:"security_group_id"`
-	SecurityGroupIds     []string `mapstructure:"security_group_ids"`
-	SubnetId             string   `mapstructure:"subnet_id"`
-	TemporaryKeyPairName string   `mapstructure:"temporary_key_pair_name"`
-	VpcId                string   `mapstructure:"vpc_id"`
-	AvailabilityZone     string   `mapstructure:"availability_zone"`
+	SourceAmi            string            `mapstructure:"source_ami"`
+	SourceAmiFilter      AMIFilterOptions  `mapstructure:"source_ami_filter"`
+	SpotPrice            string            `mapstructure:"spot_price"`
+	SpotPriceAutoProduct string            `mapstructure:"spot_price_auto_product"`
+	IamInstanceProfile   string            `mapstructure:"iam_instance_profile"`
+	InstanceType         string            `mapstructure:"instance_type"`
+	UserData             string            `mapstructure:"user_data"`
+	UserDataFile         string            `mapstructure:"user_data_file"`
+	RawSSHTimeout        string            `mapstructure:"ssh_timeout"`
+	SSHUsername          string            `mapstructure:"ssh_username"`
+	SSHPort              int               `mapstructure:"ssh_port"`
+	SecurityGroupId      string            `mapstructure:"security_group_id"`
+	SecurityGroupIds     []string          `mapstructure:"security_group_ids"`
+	SubnetId             string            `mapstructure:"subnet_id"`
+	TemporaryKeyPairName string            `mapstructure:"temporary_key_pair_name"`
+	VpcId                string            `mapstructure:"vpc_id"`
+	AvailabilityZone     string            `mapstructure:"availability_zone"`
+	RunTags              map[string]string `mapstructure:"run_tags"`
+
+	RawWindowsPasswordTimeout string `mapstructure:"windows_password_timeout"`
 
 	// Unexported fields that are calculated from others
-	sshTimeout time.Duration
+	sshTimeout             time.Duration
+	windowsPasswordTimeout time.Duration
 }
 
 func (c *RunConfig) Prepare(t *packer.ConfigTemplate) []error {
@@ -55,8 +63,10 @@ func (c *RunConfig) Prepare(t *packer.ConfigTemplate) []error {
 	// Validation
 	var err error
 	errs := make([]error, 0)
-	if c.SourceAmi == "" {
-		errs = append(errs, errors.New("A source_ami must be specified"))
+	if c.SourceAmi == "" && c.SourceAmiFilter.Empty() {
+		errs = append(errs, errors.New("A source_ami or source_ami_filter must be specified"))
+	} else if c.SourceAmi != "" && !c.SourceAmiFilter.Empty() {
+		errs = append(errs, errors.New("Only one of source_ami or source_ami_filter can be specified."))
 	}
 
 	if c.InstanceType == "" {
@@ -85,15 +95,18 @@ func (c *RunConfig) Prepare(t *packer.ConfigTemplate) []error {
 	}
 
 	templates := map[string]*string{
-		"iam_instance_profile":    &c.IamInstanceProfile,
-		"instance_type":           &c.InstanceType,
-		"ssh_timeout":             &c.RawSSHTimeout,
-		"ssh_username":            &c.SSHUsername,
-		"source_ami":              &c.SourceAmi,
-		"subnet_id":               &c.SubnetId,
-		"temporary_key_pair_name": &c.TemporaryKeyPairName,
-		"vpc_id":                  &c.VpcId,
-		"availability_zone":       &c.AvailabilityZone,
+		"iam_instance_profile":     &c.IamInstanceProfile,
+		"instance_type":            &c.InstanceType,
+		"ssh_timeout":              &c.RawSSHTimeout,
+		"ssh_username":             &c.SSHUsername,
+		"source_ami":               &c.SourceAmi,
+		"spot_price":               &c.SpotPrice,
+		"spot_price_auto_product":  &c.SpotPriceAutoProduct,
+		"subnet_id":                &c.SubnetId,
+		"temporary_key_pair_name":  &c.TemporaryKeyPairName,
+		"vpc_id":                   &c.VpcId,
+		"windows_password_timeout": &c.RawWindowsPasswordTimeout,
+		"availability_zone":        &c.AvailabilityZone,
 	}
 
 	for n, ptr := range templates {
@@ -120,14 +133,44 @@ func (c *RunConfig) Prepare(t *packer.ConfigTemplate) []error {
 		}
 	}
 
+	errs = append(errs, c.SourceAmiFilter.Prepare(t, "source_ami_filter")...)
+
+	var tagErrs []error
+	c.RunTags, tagErrs = PrepareTags(t, c.RunTags, "run_tags")
+	errs = append(errs, tagErrs...)
+
+	if c.SpotPrice == "auto" {
+		if c.SpotPriceAutoProduct == "" {
+			errs = append(errs, errors.New(
+				"spot_price_auto_product must be specified when spot_price is auto"))
+		}
+	} else if c.SpotPrice != "" {
+		if _, err := strconv.ParseFloat(c.SpotPrice, 64); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"spot_price must be a number or \"auto\": %s", c.SpotPrice))
+		}
+	}
+
 	c.sshTimeout, err = time.ParseDuration(c.RawSSHTimeout)
 	if err != nil {
 		errs = append(errs, fmt.Errorf("Failed parsing ssh_timeout: %s", err))
 	}
 
+	// The Windows password is only waited for if a timeout is given
+	if c.RawWindowsPasswordTimeout != "" {
+		c.windowsPasswordTimeout, err = time.ParseDuration(c.RawWindowsPasswordTimeout)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Failed parsing windows_password_timeout: %s", err))
+		}
+	}
+
 	return errs
 }
 
 func (c *RunConfig) SSHTimeout() time.Duration {
 	return c.sshTimeout
 }
+
+func (c *RunConfig) WindowsPasswordTimeout() time.Duration {
+	return c.windowsPasswordTimeout
+}
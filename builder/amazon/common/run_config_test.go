@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -47,6 +48,21 @@ func TestRunConfigPrepare_SourceAmi(t *testing.T) {
 	}
 }
 
+func TestRunConfigPrepare_SourceAmiFilter(t *testing.T) {
+	c := testConfig()
+	c.SourceAmi = ""
+	c.SourceAmiFilter = AMIFilterOptions{Name: "ubuntu/images/*"}
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Only one of source_ami and source_ami_filter can be set
+	c.SourceAmi = "abcd"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("err: %s", err)
+	}
+}
+
 func TestRunConfigPrepare_SSHPort(t *testing.T) {
 	c := testConfig()
 	c.SSHPort = 0
@@ -138,3 +154,67 @@ func TestRunConfigPrepare_TemporaryKeyPairName(t *testing.T) {
 		t.Fatal("keypair empty")
 	}
 }
+
+func TestRunConfigPrepare_SpotPrice(t *testing.T) {
+	c := testConfig()
+	c.SpotPrice = "0.05"
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.SpotPrice = "bad"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_SpotPriceAuto(t *testing.T) {
+	c := testConfig()
+	c.SpotPrice = "auto"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.SpotPriceAutoProduct = "Linux/UNIX"
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_RunTags(t *testing.T) {
+	c := testConfig()
+	c.RunTags = map[string]string{"Source": "{{.SourceAMI}}"}
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.RunTags = map[string]string{"Source": "{{.SourceAMI"}
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestRunConfigPrepare_WindowsPasswordTimeout(t *testing.T) {
+	c := testConfig()
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	if c.WindowsPasswordTimeout() != 0 {
+		t.Fatalf("bad: %s", c.WindowsPasswordTimeout())
+	}
+
+	c.RawWindowsPasswordTimeout = "20m"
+	if err := c.Prepare(nil); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	if c.WindowsPasswordTimeout() != 20*time.Minute {
+		t.Fatalf("bad: %s", c.WindowsPasswordTimeout())
+	}
+
+	c.RawWindowsPasswordTimeout = "bad"
+	if err := c.Prepare(nil); len(err) != 1 {
+		t.Fatalf("err: %s", err)
+	}
+}
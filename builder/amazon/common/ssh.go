@@ -46,7 +46,7 @@ func SSHAddress(e *ec2.EC2, port int) func(multistep.StateBag) (string, error) {
 
 // SSHConfig returns a function that can be used for the SSH communicator
 // config for connecting to the instance created over SSH using the generated
-// private key.
+// private key, or the Windows password if one was retrieved.
 func SSHConfig(username string) func(multistep.StateBag) (*gossh.ClientConfig, error) {
 	return func(state multistep.StateBag) (*gossh.ClientConfig, error) {
 		privateKey := state.Get("privateKey").(string)
@@ -56,11 +56,20 @@ func SSHConfig(username string) func(multistep.StateBag) (*gossh.ClientConfig, e
 			return nil, fmt.Errorf("Error setting up SSH config: %s", err)
 		}
 
+		auth := []gossh.ClientAuth{
+			gossh.ClientAuthKeyring(keyring),
+		}
+
+		if password, ok := state.GetOk("password"); ok {
+			auth = append(auth,
+				gossh.ClientAuthPassword(ssh.Password(password.(string))),
+				gossh.ClientAuthKeyboardInteractive(
+					ssh.PasswordKeyboardInteractive(password.(string))))
+		}
+
 		return &gossh.ClientConfig{
 			User: username,
-			Auth: []gossh.ClientAuth{
-				gossh.ClientAuthKeyring(keyring),
-			},
+			Auth: auth,
 		}, nil
 	}
 }
@@ -82,6 +82,57 @@ func InstanceStateRefreshFunc(conn *ec2.EC2, i *ec2.Instance) StateRefreshFunc {
 	}
 }
 
+// SpotRequestStateRefreshFunc returns a StateRefreshFunc that is used to watch
+// a spot instance request.
+func SpotRequestStateRefreshFunc(conn *ec2.EC2, spotRequestId string) StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		resp, err := conn.DescribeSpotRequests([]string{spotRequestId}, ec2.NewFilter())
+		if err != nil {
+			if ec2err, ok := err.(*ec2.Error); ok && ec2err.Code == "InvalidSpotInstanceRequestID.NotFound" {
+				// Set this to nil as if we didn't find anything.
+				resp = nil
+			} else {
+				log.Printf("Error on SpotRequestStateRefresh: %s", err)
+				return nil, "", err
+			}
+		}
+
+		if resp == nil || len(resp.SpotRequestResults) == 0 {
+			// Sometimes AWS has consistency issues and doesn't see the
+			// spot request. Return an empty state.
+			return nil, "", nil
+		}
+
+		i := resp.SpotRequestResults[0]
+		return i, i.State, nil
+	}
+}
+
+// ImportImageStateRefreshFunc returns a StateRefreshFunc that is used to
+// watch an import image task. Tasks that are cancelled or fail end up in
+// the "deleted" state, which is reported as an error along with the
+// reason AWS gives.
+func ImportImageStateRefreshFunc(conn *ec2.EC2, importTaskId string) StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		i, err := DescribeImportImageTask(conn, importTaskId)
+		if err != nil {
+			log.Printf("Error on ImportImageStateRefresh: %s", err)
+			return nil, "", err
+		}
+
+		if i == nil {
+			return nil, "", nil
+		}
+
+		if i.Status == "deleting" || i.Status == "deleted" {
+			return nil, "", fmt.Errorf("import task %s failed: %s", importTaskId, i.StatusMessage)
+		}
+
+		log.Printf("Import task %s is %s: %s %s%%", importTaskId, i.Status, i.StatusMessage, i.Progress)
+		return i, i.Status, nil
+	}
+}
+
 // WaitForState watches an object and waits for it to achieve a certain
 // state.
 func WaitForState(conf *StateChangeConf) (i interface{}, err error) {
@@ -126,8 +177,7 @@ func WaitForState(conf *StateChangeConf) (i interface{}, err error) {
 			}
 
 			if !found {
-				fmt.Errorf("unexpected state '%s', wanted target '%s'", currentState, conf.Target)
-				return
+				return nil, fmt.Errorf("unexpected state '%s', wanted target '%s'", currentState, conf.Target)
 			}
 		}
 
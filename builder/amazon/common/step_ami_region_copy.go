@@ -8,8 +8,26 @@ import (
 	"github.com/mitchellh/packer/packer"
 )
 
+// StepAMIRegionCopy copies the AMI that was built to other regions, naming
+// the copies Name. If EncryptBootVolume is set, the copies are encrypted, using the KMS key
+// for each region in KmsKeyIds or the default EBS key otherwise, and an
+// encrypted copy replaces the AMI in the build region as well. The
+// unencrypted AMI is deregistered if DeregisterUnencrypted is set.
+//
+// Uses:
+//   amis map[string]string
+//   ec2  *ec2.EC2
+//   ui   packer.Ui
+//
+// Produces:
+//   amis map[string]string - The AMIs in each region, replaced by the
+//     encrypted copies if the boot volume is encrypted.
 type StepAMIRegionCopy struct {
-	Regions []string
+	Name                  string
+	Regions               []string
+	EncryptBootVolume     bool
+	KmsKeyIds             map[string]string
+	DeregisterUnencrypted bool
 }
 
 func (s *StepAMIRegionCopy) Run(state multistep.StateBag) multistep.StepAction {
@@ -18,21 +36,38 @@ func (s *StepAMIRegionCopy) Run(state multistep.StateBag) multistep.StepAction {
 	amis := state.Get("amis").(map[string]string)
 	ami := amis[ec2conn.Region.Name]
 
-	if len(s.Regions) == 0 {
+	regions := s.Regions
+	if s.EncryptBootVolume {
+		// The build region needs an encrypted copy too.
+		regions = []string{ec2conn.Region.Name}
+		for _, region := range s.Regions {
+			if region != ec2conn.Region.Name {
+				regions = append(regions, region)
+			}
+		}
+	}
+
+	if len(regions) == 0 {
 		return multistep.ActionContinue
 	}
 
-	ui.Say(fmt.Sprintf("Copying AMI (%s) to other regions...", ami))
-	for _, region := range s.Regions {
+	if s.EncryptBootVolume {
+		ui.Say(fmt.Sprintf("Copying AMI (%s) with an encrypted boot volume...", ami))
+	} else {
+		ui.Say(fmt.Sprintf("Copying AMI (%s) to other regions...", ami))
+	}
+
+	for _, region := range regions {
 		ui.Message(fmt.Sprintf("Copying to: %s", region))
 
 		// Connect to the region where the AMI will be copied to
-		regionconn := ec2.New(ec2conn.Auth, aws.Regions[region])
-		resp, err := regionconn.CopyImage(&ec2.CopyImage{
-			SourceRegion:  ec2conn.Region.Name,
-			SourceImageId: ami,
-		})
+		regionconn := ec2conn
+		if region != ec2conn.Region.Name {
+			regionconn = ec2.New(ec2conn.Auth, aws.Regions[region])
+		}
 
+		imageId, err := copyImage(regionconn, ec2conn.Region.Name, ami, s.Name,
+			s.EncryptBootVolume, s.KmsKeyIds[region])
 		if err != nil {
 			err := fmt.Errorf("Error Copying AMI (%s) to region (%s): %s", ami, region, err)
 			state.Put("error", err)
@@ -44,26 +79,74 @@ func (s *StepAMIRegionCopy) Run(state multistep.StateBag) multistep.StepAction {
 			Conn:      regionconn,
 			Pending:   []string{"pending"},
 			Target:    "available",
-			Refresh:   AMIStateRefreshFunc(regionconn, resp.ImageId),
+			Refresh:   AMIStateRefreshFunc(regionconn, imageId),
 			StepState: state,
 		}
 
 		ui.Say(fmt.Sprintf("Waiting for AMI (%s) in region (%s) to become ready...",
-			resp.ImageId, region))
+			imageId, region))
 		if _, err := WaitForState(&stateChange); err != nil {
-			err := fmt.Errorf("Error waiting for AMI (%s) in region (%s): %s", resp.ImageId, region, err)
+			err := fmt.Errorf("Error waiting for AMI (%s) in region (%s): %s", imageId, region, err)
 			state.Put("error", err)
 			ui.Error(err.Error())
 			return multistep.ActionHalt
 		}
 
-		amis[region] = resp.ImageId
+		amis[region] = imageId
 	}
 
 	state.Put("amis", amis)
+
+	if s.EncryptBootVolume {
+		if !s.DeregisterUnencrypted {
+			ui.Message(fmt.Sprintf(
+				"Keeping unencrypted AMI (%s). It isn't part of the artifact, "+
+					"so it must be deregistered by hand.", ami))
+			return multistep.ActionContinue
+		}
+
+		// The encrypted copies already exist and are the artifact, so
+		// failing to clean up after them doesn't fail the build.
+		if err := s.deregisterUnencrypted(ec2conn, ui, ami); err != nil {
+			ui.Error(fmt.Sprintf(
+				"%s\nThe unencrypted AMI (%s) must be deregistered by hand.", err, ami))
+		}
+	}
+
 	return multistep.ActionContinue
 }
 
 func (s *StepAMIRegionCopy) Cleanup(state multistep.StateBag) {
 	// No cleanup...
 }
+
+// deregisterUnencrypted deregisters the unencrypted AMI in the build
+// region and deletes its snapshots, so that no unencrypted copy of the
+// boot volume is left around.
+func (s *StepAMIRegionCopy) deregisterUnencrypted(ec2conn *ec2.EC2, ui packer.Ui, ami string) error {
+	ui.Say(fmt.Sprintf("Deregistering unencrypted AMI (%s)...", ami))
+	resp, err := ec2conn.Images([]string{ami}, ec2.NewFilter())
+	if err != nil {
+		return fmt.Errorf("Error querying unencrypted AMI (%s): %s", ami, err)
+	}
+
+	if _, err := ec2conn.DeregisterImage(ami); err != nil {
+		return fmt.Errorf("Error deregistering unencrypted AMI (%s): %s", ami, err)
+	}
+
+	for _, image := range resp.Images {
+		for _, device := range image.BlockDevices {
+			if device.SnapshotId == "" {
+				continue
+			}
+
+			ui.Message(fmt.Sprintf("Deleting snapshot: %s", device.SnapshotId))
+			if _, err := ec2conn.DeleteSnapshots([]string{device.SnapshotId}); err != nil {
+				return fmt.Errorf(
+					"Error deleting snapshot (%s) of unencrypted AMI: %s", device.SnapshotId, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package common
+
+import (
+	"bytes"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestStepAMIRegionCopy_impl(t *testing.T) {
+	var _ multistep.Step = new(StepAMIRegionCopy)
+}
+
+// testAMIRegionCopyServer answers the EC2 calls of StepAMIRegionCopy
+// for the AMI ami-1a2b3c4d, whose copies are ami-4d3c2b1a, recording the
+// requests that were made.
+func testAMIRegionCopyServer(requests *[]url.Values) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		*requests = append(*requests, r.Form)
+
+		switch r.Form.Get("Action") {
+		case "CopyImage":
+			w.Write([]byte(`<CopyImageResponse>
+  <imageId>ami-4d3c2b1a</imageId>
+</CopyImageResponse>`))
+		case "DescribeImages":
+			w.Write([]byte(`<DescribeImagesResponse>
+  <imagesSet>
+    <item>
+      <imageId>` + r.Form.Get("ImageId.1") + `</imageId>
+      <imageState>available</imageState>
+      <blockDeviceMapping>
+        <item>
+          <deviceName>/dev/sda1</deviceName>
+          <ebs>
+            <snapshotId>snap-1a2b3c4d</snapshotId>
+          </ebs>
+        </item>
+      </blockDeviceMapping>
+    </item>
+  </imagesSet>
+</DescribeImagesResponse>`))
+		default:
+			w.Write([]byte(`<Response><return>true</return></Response>`))
+		}
+	}
+}
+
+func TestStepAMIRegionCopy_encryptBuildRegion(t *testing.T) {
+	var requests []url.Values
+	conn, closeFn := testEC2Query(testAMIRegionCopyServer(&requests))
+	defer closeFn()
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ec2", conn)
+	state.Put("amis", map[string]string{"us-west-2": "ami-1a2b3c4d"})
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+
+	step := &StepAMIRegionCopy{
+		Name:                  "packer-foo",
+		EncryptBootVolume:     true,
+		DeregisterUnencrypted: true,
+	}
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	amis := state.Get("amis").(map[string]string)
+	if len(amis) != 1 || amis["us-west-2"] != "ami-4d3c2b1a" {
+		t.Fatalf("bad: %#v", amis)
+	}
+
+	actions := make([]string, len(requests))
+	for i, form := range requests {
+		actions[i] = form.Get("Action")
+	}
+
+	expected := []string{
+		"CopyImage", "DescribeImages", "DescribeImages", "DeregisterImage", "DeleteSnapshot",
+	}
+	if len(actions) != len(expected) {
+		t.Fatalf("bad: %#v", actions)
+	}
+	for i := range expected {
+		if actions[i] != expected[i] {
+			t.Fatalf("bad: %#v", actions)
+		}
+	}
+
+	copyForm := requests[0]
+	if copyForm.Get("Name") != "packer-foo" || copyForm.Get("Encrypted") != "true" ||
+		copyForm.Get("SourceRegion") != "us-west-2" ||
+		copyForm.Get("SourceImageId") != "ami-1a2b3c4d" {
+		t.Fatalf("bad: %#v", copyForm)
+	}
+
+	if requests[3].Get("ImageId") != "ami-1a2b3c4d" {
+		t.Fatalf("bad: %#v", requests[3])
+	}
+	if requests[4].Get("SnapshotId.1") != "snap-1a2b3c4d" {
+		t.Fatalf("bad: %#v", requests[4])
+	}
+}
+
+func TestStepAMIRegionCopy_deregisterError(t *testing.T) {
+	var requests []url.Values
+	server := testAMIRegionCopyServer(&requests)
+	conn, closeFn := testEC2Query(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("Action") == "DeregisterImage" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		server(w, r)
+	})
+	defer closeFn()
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ec2", conn)
+	state.Put("amis", map[string]string{"us-west-2": "ami-1a2b3c4d"})
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+
+	step := &StepAMIRegionCopy{
+		Name:                  "packer-foo",
+		EncryptBootVolume:     true,
+		DeregisterUnencrypted: true,
+	}
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state.GetOk("error"); ok {
+		t.Fatal("should not have error")
+	}
+
+	// The encrypted copy is still the artifact
+	amis := state.Get("amis").(map[string]string)
+	if amis["us-west-2"] != "ami-4d3c2b1a" {
+		t.Fatalf("bad: %#v", amis)
+	}
+}
+
+func TestStepAMIRegionCopy_noRegions(t *testing.T) {
+	var requests []url.Values
+	conn, closeFn := testEC2Query(testAMIRegionCopyServer(&requests))
+	defer closeFn()
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ec2", conn)
+	state.Put("amis", map[string]string{"us-west-2": "ami-1a2b3c4d"})
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+
+	step := &StepAMIRegionCopy{Name: "packer-foo"}
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if len(requests) > 0 {
+		t.Fatalf("bad: %#v", requests)
+	}
+
+	amis := state.Get("amis").(map[string]string)
+	if amis["us-west-2"] != "ami-1a2b3c4d" {
+		t.Fatalf("bad: %#v", amis)
+	}
+}
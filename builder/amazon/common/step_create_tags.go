@@ -8,8 +8,19 @@ import (
 	"github.com/mitchellh/packer/packer"
 )
 
+// StepCreateTags tags the AMIs in each region, and the snapshots that
+// back them. The tag values are processed as templates with
+// TagsTemplateData.
+//
+// Uses:
+//   amis         map[string]string
+//   ec2          *ec2.EC2
+//   source_image *ec2.Image - optional, the source AMI info
+//   ui           packer.Ui
 type StepCreateTags struct {
-	Tags map[string]string
+	Tags         map[string]string
+	SnapshotTags map[string]string
+	Tpl          *packer.ConfigTemplate
 }
 
 func (s *StepCreateTags) Run(state multistep.StateBag) multistep.StepAction {
@@ -17,25 +28,72 @@ func (s *StepCreateTags) Run(state multistep.StateBag) multistep.StepAction {
 	ui := state.Get("ui").(packer.Ui)
 	amis := state.Get("amis").(map[string]string)
 
-	if len(s.Tags) > 0 {
-		for region, ami := range amis {
-			ui.Say(fmt.Sprintf("Adding tags to AMI (%s)...", ami))
+	if len(s.Tags) == 0 && len(s.SnapshotTags) == 0 {
+		return multistep.ActionContinue
+	}
 
-			var ec2Tags []ec2.Tag
-			for key, value := range s.Tags {
-				ui.Message(fmt.Sprintf("Adding tag: \"%s\": \"%s\"", key, value))
-				ec2Tags = append(ec2Tags, ec2.Tag{key, value})
-			}
+	data := tagsTemplateData(state)
+
+	// Process the tags once so they are the same in every region
+	ec2Tags, err := processTags(s.Tpl, s.Tags, data)
+	if err != nil {
+		err := fmt.Errorf("Error processing tags: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	snapshotTags, err := processTags(s.Tpl, s.SnapshotTags, data)
+	if err != nil {
+		err := fmt.Errorf("Error processing snapshot_tags: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	for region, ami := range amis {
+		regionconn := ec2.New(ec2conn.Auth, aws.Regions[region])
 
-			regionconn := ec2.New(ec2conn.Auth, aws.Regions[region])
-			_, err := regionconn.CreateTags([]string{ami}, ec2Tags)
-			if err != nil {
-				err := fmt.Errorf("Error adding tags to AMI (%s): %s", ami, err)
+		if len(ec2Tags) > 0 {
+			resource := fmt.Sprintf("AMI (%s)", ami)
+			if err := createTags(regionconn, ui, resource, []string{ami}, ec2Tags); err != nil {
 				state.Put("error", err)
 				ui.Error(err.Error())
 				return multistep.ActionHalt
 			}
 		}
+
+		if len(snapshotTags) == 0 {
+			continue
+		}
+
+		imageResp, err := regionconn.Images([]string{ami}, ec2.NewFilter())
+		if err != nil {
+			err := fmt.Errorf("Error querying AMI (%s): %s", ami, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		var snapshotIds []string
+		for _, image := range imageResp.Images {
+			for _, device := range image.BlockDevices {
+				if device.SnapshotId != "" {
+					snapshotIds = append(snapshotIds, device.SnapshotId)
+				}
+			}
+		}
+
+		if len(snapshotIds) == 0 {
+			continue
+		}
+
+		resource := fmt.Sprintf("snapshots of AMI (%s)", ami)
+		if err := createTags(regionconn, ui, resource, snapshotIds, snapshotTags); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
 	}
 
 	return multistep.ActionContinue
@@ -44,3 +102,47 @@ func (s *StepCreateTags) Run(state multistep.StateBag) multistep.StepAction {
 func (s *StepCreateTags) Cleanup(state multistep.StateBag) {
 	// No cleanup...
 }
+
+// CreateTags adds tags to other resources of the build, such as volumes,
+// in the build region. The tags are processed with the same data as the
+// tags of StepCreateTags, and should be prepared with PrepareTags. The
+// resource describes the resources in messages.
+func CreateTags(state multistep.StateBag, tpl *packer.ConfigTemplate, resource string, ids []string, tags map[string]string) error {
+	ec2conn := state.Get("ec2").(*ec2.EC2)
+	ui := state.Get("ui").(packer.Ui)
+
+	ec2Tags, err := processTags(tpl, tags, tagsTemplateData(state))
+	if err != nil {
+		return fmt.Errorf("Error processing tags of %s: %s", resource, err)
+	}
+
+	if len(ec2Tags) == 0 {
+		return nil
+	}
+
+	return createTags(ec2conn, ui, resource, ids, ec2Tags)
+}
+
+// tagsTemplateData returns the data that tags are processed with.
+func tagsTemplateData(state multistep.StateBag) *TagsTemplateData {
+	ec2conn := state.Get("ec2").(*ec2.EC2)
+	data := &TagsTemplateData{BuildRegion: ec2conn.Region.Name}
+	if rawImage, ok := state.GetOk("source_image"); ok {
+		data.SourceAMI = rawImage.(*ec2.Image).Id
+	}
+
+	return data
+}
+
+func createTags(conn *ec2.EC2, ui packer.Ui, resource string, ids []string, tags []ec2.Tag) error {
+	ui.Say(fmt.Sprintf("Adding tags to %s...", resource))
+	for _, tag := range tags {
+		ui.Message(fmt.Sprintf("Adding tag: \"%s\": \"%s\"", tag.Key, tag.Value))
+	}
+
+	if _, err := conn.CreateTags(ids, tags); err != nil {
+		return fmt.Errorf("Error adding tags to %s: %s", resource, err)
+	}
+
+	return nil
+}
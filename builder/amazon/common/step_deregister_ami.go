@@ -0,0 +1,97 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+)
+
+// StepDeregisterAMI checks that the account has no AMI with the name of
+// the AMI that is going to be created yet, in the build region or any of
+// the regions the AMI is copied to. If ForceDeregister is set, existing
+// AMIs are deregistered instead, along with their snapshots if
+// ForceDeleteSnapshot is set.
+//
+// Uses:
+//   ec2 *ec2.EC2
+//   ui  packer.Ui
+type StepDeregisterAMI struct {
+	ForceDeregister     bool
+	ForceDeleteSnapshot bool
+	AMIName             string
+	Regions             []string
+}
+
+func (s *StepDeregisterAMI) Run(state multistep.StateBag) multistep.StepAction {
+	ec2conn := state.Get("ec2").(*ec2.EC2)
+	ui := state.Get("ui").(packer.Ui)
+
+	regions := []string{ec2conn.Region.Name}
+	for _, region := range s.Regions {
+		if region != ec2conn.Region.Name {
+			regions = append(regions, region)
+		}
+	}
+
+	for _, region := range regions {
+		regionconn := ec2conn
+		if region != ec2conn.Region.Name {
+			regionconn = ec2.New(ec2conn.Auth, aws.Regions[region])
+		}
+
+		// Only the AMIs of the account count, since the name of an AMI
+		// only has to be unique within an account. Public and shared
+		// AMIs of others must never be deregistered.
+		images, err := describeImages(regionconn, []string{"self"},
+			map[string][]string{"name": []string{s.AMIName}})
+		if err != nil {
+			err := fmt.Errorf("Error querying AMIs in region (%s): %s", region, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		for _, image := range images {
+			if !s.ForceDeregister {
+				err := fmt.Errorf(
+					"AMI name '%s' is already used by %s in region (%s). "+
+						"Use -force to deregister it.", s.AMIName, image.Id, region)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+
+			ui.Say(fmt.Sprintf(
+				"Deregistering existing AMI (%s) in region (%s)...", image.Id, region))
+			if _, err := regionconn.DeregisterImage(image.Id); err != nil {
+				err := fmt.Errorf("Error deregistering existing AMI (%s): %s", image.Id, err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+
+			if !s.ForceDeleteSnapshot {
+				continue
+			}
+
+			for _, snapshotId := range image.SnapshotIds {
+				ui.Message(fmt.Sprintf("Deleting snapshot: %s", snapshotId))
+				if _, err := regionconn.DeleteSnapshots([]string{snapshotId}); err != nil {
+					err := fmt.Errorf(
+						"Error deleting snapshot (%s) of existing AMI: %s", snapshotId, err)
+					state.Put("error", err)
+					ui.Error(err.Error())
+					return multistep.ActionHalt
+				}
+			}
+		}
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepDeregisterAMI) Cleanup(state multistep.StateBag) {
+	// No cleanup...
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"bytes"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"net/http"
+	"testing"
+)
+
+func TestStepDeregisterAMI_impl(t *testing.T) {
+	var _ multistep.Step = new(StepDeregisterAMI)
+}
+
+func TestStepDeregisterAMI_existing(t *testing.T) {
+	var owners []string
+	conn, closeFn := testEC2Query(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		owners = append(owners, r.Form.Get("Owner.1"))
+		w.Write([]byte(`<DescribeImagesResponse>
+  <imagesSet>
+    <item>
+      <imageId>ami-1a2b3c4d</imageId>
+      <name>packer-foo</name>
+      <imageOwnerId>123456789012</imageOwnerId>
+    </item>
+  </imagesSet>
+</DescribeImagesResponse>`))
+	})
+	defer closeFn()
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ec2", conn)
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+
+	step := &StepDeregisterAMI{AMIName: "packer-foo"}
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+
+	// Only the AMIs of the account are looked up
+	if len(owners) != 1 || owners[0] != "self" {
+		t.Fatalf("bad: %#v", owners)
+	}
+}
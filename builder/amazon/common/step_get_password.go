@@ -0,0 +1,154 @@
+package common
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"strings"
+	"time"
+)
+
+// StepGetPassword waits up to Timeout for the administrator password of
+// a Windows source instance to become available and decrypts it with the
+// private key of the temporary key pair. It does nothing if Timeout is
+// zero, which is the case unless windows_password_timeout is set. The
+// password is only used to log in over SSH, since there is no WinRM
+// communicator.
+//
+// Uses:
+//   ec2        *ec2.EC2
+//   instance   *ec2.Instance
+//   privateKey string
+//   ui         packer.Ui
+//
+// Produces:
+//   password string - The administrator password, if Timeout is set.
+type StepGetPassword struct {
+	Debug   bool
+	Timeout time.Duration
+
+	// The time between checks for the password, which defaults to
+	// 5 seconds.
+	pollInterval time.Duration
+}
+
+func (s *StepGetPassword) Run(state multistep.StateBag) multistep.StepAction {
+	ec2conn := state.Get("ec2").(*ec2.EC2)
+	instance := state.Get("instance").(*ec2.Instance)
+	privateKey := state.Get("privateKey").(string)
+	ui := state.Get("ui").(packer.Ui)
+
+	if s.Timeout == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui.Say(fmt.Sprintf(
+		"Waiting for the Windows password of instance (%s)...", instance.InstanceId))
+	ui.Message("It is normal for this to take 15 minutes or more.")
+
+	password, err := s.waitForPassword(state, ec2conn, instance.InstanceId, privateKey)
+	if err != nil {
+		err := fmt.Errorf("Error waiting for the Windows password: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	ui.Message("Retrieved the Windows password.")
+	if s.Debug {
+		ui.Message(fmt.Sprintf("Password (since debug is enabled): %s", password))
+	}
+
+	state.Put("password", password)
+	return multistep.ActionContinue
+}
+
+func (s *StepGetPassword) Cleanup(multistep.StateBag) {}
+
+func (s *StepGetPassword) waitForPassword(
+	state multistep.StateBag, ec2conn *ec2.EC2, instanceId, privateKey string) (string, error) {
+	interval := s.pollInterval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+
+	timeout := time.After(s.Timeout)
+	for {
+		if _, ok := state.GetOk(multistep.StateCancelled); ok {
+			return "", errors.New("interrupted")
+		}
+
+		data, err := getPasswordData(ec2conn, instanceId)
+		if err != nil {
+			return "", err
+		}
+
+		// The password data stays empty until Windows has finished
+		// setting up and generated the password.
+		if data != "" {
+			return decryptPasswordData(data, privateKey)
+		}
+
+		log.Printf("Password data not available yet for %s", instanceId)
+
+		select {
+		case <-timeout:
+			return "", errors.New("timeout")
+		case <-time.After(interval):
+		}
+	}
+}
+
+// getPasswordData returns the encrypted password data of the instance,
+// which is empty until the password is available. goamz has no method
+// for GetPasswordData.
+func getPasswordData(conn *ec2.EC2, instanceId string) (string, error) {
+	params := map[string]string{
+		"Action":     "GetPasswordData",
+		"InstanceId": instanceId,
+	}
+
+	var resp struct {
+		PasswordData string `xml:"passwordData"`
+	}
+	if err := EC2Query(conn, params, &resp); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(resp.PasswordData), nil
+}
+
+// decryptPasswordData decrypts the base64 encoded password data from
+// GetPasswordData with the PEM encoded private key of the key pair the
+// instance was launched with.
+func decryptPasswordData(data, privateKey string) (string, error) {
+	encrypted, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", fmt.Errorf("Error decoding password data: %s", err)
+	}
+
+	block, _ := pem.Decode([]byte(privateKey))
+	if block == nil {
+		return "", errors.New("Error decoding the private key")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing the private key: %s", err)
+	}
+
+	password, err := rsa.DecryptPKCS1v15(rand.Reader, key, encrypted)
+	if err != nil {
+		return "", fmt.Errorf("Error decrypting password data: %s", err)
+	}
+
+	return string(password), nil
+}
@@ -0,0 +1,171 @@
+package common
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePasswordEC2 is a local stand-in for the EC2 API that returns no
+// password data for the first few requests, like a Windows instance
+// that is still starting.
+type fakePasswordEC2 struct {
+	sync.Mutex
+	passwordData string
+	emptyCount   int
+	requests     int
+}
+
+func (f *fakePasswordEC2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	f.Lock()
+	defer f.Unlock()
+
+	f.requests++
+	if r.Form.Get("Action") != "GetPasswordData" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	data := ""
+	if f.requests > f.emptyCount {
+		data = f.passwordData
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<GetPasswordDataResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <requestId>59dbff89-35bd-4eac-99ed-be587EXAMPLE</requestId>
+  <instanceId>%s</instanceId>
+  <timestamp>2014-01-01T00:00:00.000Z</timestamp>
+  <passwordData>
+    %s
+  </passwordData>
+</GetPasswordDataResponse>`, r.Form.Get("InstanceId"), data)
+}
+
+func testPasswordKey(t *testing.T, password string) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	encrypted, err := rsa.EncryptPKCS1v15(rand.Reader, &key.PublicKey, []byte(password))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	privateKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+
+	return string(privateKey), base64.StdEncoding.EncodeToString(encrypted)
+}
+
+func testStepGetPasswordState(t *testing.T, fake *fakePasswordEC2, privateKey string) (multistep.StateBag, func()) {
+	conn, closeFn := testEC2Query(fake.ServeHTTP)
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ec2", conn)
+	state.Put("instance", &ec2.Instance{InstanceId: "i-12345"})
+	state.Put("privateKey", privateKey)
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	return state, closeFn
+}
+
+func TestStepGetPassword_impl(t *testing.T) {
+	var _ multistep.Step = new(StepGetPassword)
+}
+
+func TestStepGetPassword(t *testing.T) {
+	privateKey, data := testPasswordKey(t, "s3cr3t")
+	fake := &fakePasswordEC2{passwordData: data, emptyCount: 2}
+	state, closeFn := testStepGetPasswordState(t, fake, privateKey)
+	defer closeFn()
+
+	step := &StepGetPassword{
+		Timeout:      5 * time.Second,
+		pollInterval: 10 * time.Millisecond,
+	}
+	defer step.Cleanup(state)
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v %#v", action, state.Get("error"))
+	}
+
+	if password := state.Get("password").(string); password != "s3cr3t" {
+		t.Fatalf("bad: %#v", password)
+	}
+
+	if fake.requests != 3 {
+		t.Fatalf("bad: %d", fake.requests)
+	}
+}
+
+func TestStepGetPassword_disabled(t *testing.T) {
+	fake := new(fakePasswordEC2)
+	state, closeFn := testStepGetPasswordState(t, fake, "")
+	defer closeFn()
+
+	step := new(StepGetPassword)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state.GetOk("password"); ok {
+		t.Fatal("should not have a password")
+	}
+
+	if fake.requests != 0 {
+		t.Fatalf("bad: %d", fake.requests)
+	}
+}
+
+func TestStepGetPassword_timeout(t *testing.T) {
+	privateKey, data := testPasswordKey(t, "s3cr3t")
+	fake := &fakePasswordEC2{passwordData: data, emptyCount: 1000}
+	state, closeFn := testStepGetPasswordState(t, fake, privateKey)
+	defer closeFn()
+
+	step := &StepGetPassword{
+		Timeout:      50 * time.Millisecond,
+		pollInterval: 10 * time.Millisecond,
+	}
+
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestDecryptPasswordData_badKey(t *testing.T) {
+	_, data := testPasswordKey(t, "s3cr3t")
+	otherKey, _ := testPasswordKey(t, "other")
+
+	if _, err := decryptPasswordData(data, otherKey); err == nil {
+		t.Fatal("should fail with the wrong key")
+	}
+
+	if _, err := decryptPasswordData(data, "foo"); err == nil {
+		t.Fatal("should fail with an invalid key")
+	}
+}
@@ -1,12 +1,16 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"github.com/mitchellh/goamz/ec2"
 	"github.com/mitchellh/multistep"
 	"github.com/mitchellh/packer/packer"
 	"io/ioutil"
 	"log"
+	"math"
+	"strconv"
+	"time"
 )
 
 type StepRunSourceInstance struct {
@@ -16,12 +20,18 @@ type StepRunSourceInstance struct {
 	UserData           string
 	UserDataFile       string
 	SourceAMI          string
+	SourceAMIFilter    AMIFilterOptions
+	SpotPrice          string
+	SpotPriceProduct   string
 	IamInstanceProfile string
 	SubnetId           string
 	AvailabilityZone   string
 	BlockDevices       BlockDevices
+	Tags               map[string]string
+	Tpl                *packer.ConfigTemplate
 
-	instance *ec2.Instance
+	instance    *ec2.Instance
+	spotRequest *ec2.SpotRequestResult
 }
 
 func (s *StepRunSourceInstance) Run(state multistep.StateBag) multistep.StepAction {
@@ -46,49 +56,157 @@ func (s *StepRunSourceInstance) Run(state multistep.StateBag) multistep.StepActi
 		securityGroups[n] = ec2.SecurityGroup{Id: securityGroupId}
 	}
 
-	runOpts := &ec2.RunInstances{
-		KeyName:            keyName,
-		ImageId:            s.SourceAMI,
-		InstanceType:       s.InstanceType,
-		UserData:           []byte(userData),
-		MinCount:           0,
-		MaxCount:           0,
-		SecurityGroups:     securityGroups,
-		IamInstanceProfile: s.IamInstanceProfile,
-		SubnetId:           s.SubnetId,
-		BlockDevices:       s.BlockDevices.BuildLaunchDevices(),
-		AvailZone:          s.AvailabilityZone,
+	var image *ec2.Image
+	if s.SourceAMI == "" {
+		ui.Say("Finding the source AMI matching source_ami_filter...")
+		var err error
+		image, err = s.SourceAMIFilter.FindImage(ec2conn)
+		if err != nil {
+			err := fmt.Errorf("Error finding source AMI: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		ui.Message(fmt.Sprintf("Found source AMI: %s (%s)", image.Id, image.Name))
+		s.SourceAMI = image.Id
 	}
 
 	ui.Say("Launching a source AWS instance...")
-	imageResp, err := ec2conn.Images([]string{s.SourceAMI}, ec2.NewFilter())
-	if err != nil {
-		state.Put("error", fmt.Errorf("There was a problem with the source AMI: %s", err))
-		return multistep.ActionHalt
-	}
+	if image == nil {
+		imageResp, err := ec2conn.Images([]string{s.SourceAMI}, ec2.NewFilter())
+		if err != nil {
+			state.Put("error", fmt.Errorf("There was a problem with the source AMI: %s", err))
+			return multistep.ActionHalt
+		}
 
-	if len(imageResp.Images) != 1 {
-		state.Put("error", fmt.Errorf("The source AMI '%s' could not be found.", s.SourceAMI))
-		return multistep.ActionHalt
+		if len(imageResp.Images) != 1 {
+			state.Put("error", fmt.Errorf("The source AMI '%s' could not be found.", s.SourceAMI))
+			return multistep.ActionHalt
+		}
+
+		image = &imageResp.Images[0]
 	}
 
-	if s.ExpectedRootDevice != "" && imageResp.Images[0].RootDeviceType != s.ExpectedRootDevice {
+	if s.ExpectedRootDevice != "" && image.RootDeviceType != s.ExpectedRootDevice {
 		state.Put("error", fmt.Errorf(
 			"The provided source AMI has an invalid root device type.\n"+
 				"Expected '%s', got '%s'.",
-			s.ExpectedRootDevice, imageResp.Images[0].RootDeviceType))
+			s.ExpectedRootDevice, image.RootDeviceType))
 		return multistep.ActionHalt
 	}
 
-	runResp, err := ec2conn.RunInstances(runOpts)
-	if err != nil {
-		err := fmt.Errorf("Error launching source instance: %s", err)
-		state.Put("error", err)
-		ui.Error(err.Error())
-		return multistep.ActionHalt
+	state.Put("source_image", image)
+
+	spotPrice := s.SpotPrice
+	availabilityZone := s.AvailabilityZone
+	if spotPrice == "auto" {
+		ui.Message(fmt.Sprintf(
+			"Finding spot price for %s %s...",
+			s.SpotPriceProduct, s.InstanceType))
+
+		// Detect the spot price
+		startTime := time.Now().Add(-1 * time.Hour)
+		resp, err := ec2conn.DescribeSpotPriceHistory(&ec2.DescribeSpotPriceHistory{
+			InstanceType:       []string{s.InstanceType},
+			ProductDescription: []string{s.SpotPriceProduct},
+			AvailabilityZone:   s.AvailabilityZone,
+			StartTime:          startTime,
+		})
+		if err != nil {
+			err := fmt.Errorf("Error finding spot price: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		// Only pick the zone if neither it nor a subnet, which is in a
+		// zone of its own, was configured.
+		pickZone := s.AvailabilityZone == "" && s.SubnetId == ""
+		var zone string
+		spotPrice, zone, err = chooseSpotPrice(resp.History, pickZone)
+		if err != nil {
+			err := fmt.Errorf("No candidate spot prices found for %s %s: %s",
+				s.SpotPriceProduct, s.InstanceType, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		if pickZone {
+			availabilityZone = zone
+		}
+	}
+
+	if spotPrice == "" {
+		runOpts := &ec2.RunInstances{
+			KeyName:            keyName,
+			ImageId:            s.SourceAMI,
+			InstanceType:       s.InstanceType,
+			UserData:           []byte(userData),
+			MinCount:           0,
+			MaxCount:           0,
+			SecurityGroups:     securityGroups,
+			IamInstanceProfile: s.IamInstanceProfile,
+			SubnetId:           s.SubnetId,
+			BlockDevices:       s.BlockDevices.BuildLaunchDevices(),
+			AvailZone:          availabilityZone,
+		}
+
+		runResp, err := ec2conn.RunInstances(runOpts)
+		if err != nil {
+			err := fmt.Errorf("Error launching source instance: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		s.instance = &runResp.Instances[0]
+	} else {
+		ui.Message(fmt.Sprintf("Requesting spot instance at %s...", spotPrice))
+		runSpotResp, err := ec2conn.RequestSpotInstances(&ec2.RequestSpotInstances{
+			SpotPrice:          spotPrice,
+			KeyName:            keyName,
+			ImageId:            s.SourceAMI,
+			InstanceType:       s.InstanceType,
+			UserData:           []byte(userData),
+			SecurityGroups:     securityGroups,
+			IamInstanceProfile: s.IamInstanceProfile,
+			SubnetId:           s.SubnetId,
+			BlockDevices:       s.BlockDevices.BuildLaunchDevices(),
+			AvailZone:          availabilityZone,
+		})
+		if err != nil {
+			err := fmt.Errorf("Error launching source spot instance: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		s.spotRequest = &runSpotResp.SpotRequestResults[0]
+		spotRequestId := s.spotRequest.SpotRequestId
+		log.Printf("spot request id: %s", spotRequestId)
+
+		ui.Message(fmt.Sprintf("Waiting for spot request (%s) to become active...", spotRequestId))
+		stateChange := StateChangeConf{
+			Conn:      ec2conn,
+			Pending:   []string{"open"},
+			Target:    "active",
+			Refresh:   SpotRequestStateRefreshFunc(ec2conn, spotRequestId),
+			StepState: state,
+		}
+		spotResult, err := WaitForState(&stateChange)
+		if err != nil {
+			err := fmt.Errorf("Error waiting for spot request (%s) to become ready: %s", spotRequestId, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		instanceId := spotResult.(ec2.SpotRequestResult).InstanceId
+		s.instance = &ec2.Instance{InstanceId: instanceId}
 	}
 
-	s.instance = &runResp.Instances[0]
 	log.Printf("instance id: %s", s.instance.InstanceId)
 
 	ui.Say(fmt.Sprintf("Waiting for instance (%s) to become ready...", s.instance.InstanceId))
@@ -109,6 +227,14 @@ func (s *StepRunSourceInstance) Run(state multistep.StateBag) multistep.StepActi
 
 	s.instance = latestInstance.(*ec2.Instance)
 
+	if len(s.Tags) > 0 {
+		if err := s.createRunTags(ec2conn, ui, image); err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
 	if s.Debug {
 		if s.instance.DNSName != "" {
 			ui.Message(fmt.Sprintf("Public DNS: %s", s.instance.DNSName))
@@ -124,14 +250,80 @@ func (s *StepRunSourceInstance) Run(state multistep.StateBag) multistep.StepActi
 	return multistep.ActionContinue
 }
 
-func (s *StepRunSourceInstance) Cleanup(state multistep.StateBag) {
-	if s.instance == nil {
-		return
+// spotPriceHeadroom is what the spot price found for a spot_price of
+// "auto" is multiplied by to get the bid, so that the request isn't
+// outbid by the first small rise in the price.
+const spotPriceHeadroom = 1.2
+
+// chooseSpotPrice picks the price to bid for a spot instance from the
+// recent spot price history. If pickZone is true, the bid is based on
+// the lowest price, and the zone of that price is returned to launch the
+// instance in. Otherwise the zone is fixed, possibly by a subnet whose
+// zone isn't known, so the bid is based on the highest price.
+func chooseSpotPrice(history []ec2.SpotPriceHistory, pickZone bool) (string, string, error) {
+	var price float64
+	var zone string
+	for _, h := range history {
+		log.Printf("Candidate spot price: %s (%s)", h.SpotPrice, h.AvailabilityZone)
+		current, err := strconv.ParseFloat(h.SpotPrice, 64)
+		if err != nil {
+			log.Printf("Error parsing spot price: %s", err)
+			continue
+		}
+
+		if price == 0 || (pickZone && current < price) || (!pickZone && current > price) {
+			price = current
+			zone = h.AvailabilityZone
+		}
 	}
 
+	if price == 0 {
+		return "", "", errors.New("no valid prices in the last hour")
+	}
+
+	// Round the bid up to a tenth of a cent
+	bid := math.Ceil(price*spotPriceHeadroom*1000) / 1000
+	return strconv.FormatFloat(bid, 'f', 3, 64), zone, nil
+}
+
+// createRunTags tags the source instance and its volumes.
+func (s *StepRunSourceInstance) createRunTags(ec2conn *ec2.EC2, ui packer.Ui, image *ec2.Image) error {
+	tags, err := processTags(s.Tpl, s.Tags, &TagsTemplateData{
+		SourceAMI:   image.Id,
+		BuildRegion: ec2conn.Region.Name,
+	})
+	if err != nil {
+		return fmt.Errorf("Error processing run_tags: %s", err)
+	}
+
+	ids := []string{s.instance.InstanceId}
+	for _, device := range s.instance.BlockDevices {
+		if device.VolumeId != "" {
+			ids = append(ids, device.VolumeId)
+		}
+	}
+
+	resource := fmt.Sprintf("source instance (%s)", s.instance.InstanceId)
+	return createTags(ec2conn, ui, resource, ids, tags)
+}
+
+func (s *StepRunSourceInstance) Cleanup(state multistep.StateBag) {
 	ec2conn := state.Get("ec2").(*ec2.EC2)
 	ui := state.Get("ui").(packer.Ui)
 
+	// Cancel the spot request if it exists so that it doesn't launch
+	// another instance once we terminate this one.
+	if s.spotRequest != nil {
+		ui.Say("Cancelling the spot request...")
+		if _, err := ec2conn.CancelSpotRequests([]string{s.spotRequest.SpotRequestId}); err != nil {
+			ui.Error(fmt.Sprintf("Error cancelling the spot request, may still be around: %s", err))
+		}
+	}
+
+	if s.instance == nil {
+		return
+	}
+
 	ui.Say("Terminating the source AWS instance...")
 	if _, err := ec2conn.TerminateInstances([]string{s.instance.InstanceId}); err != nil {
 		ui.Error(fmt.Sprintf("Error terminating instance, may still be around: %s", err))
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"testing"
+)
+
+func TestStepRunSourceInstance_impl(t *testing.T) {
+	var _ multistep.Step = new(StepRunSourceInstance)
+}
+
+func testSpotPriceHistory() []ec2.SpotPriceHistory {
+	return []ec2.SpotPriceHistory{
+		{SpotPrice: "0.0300", AvailabilityZone: "us-east-1a"},
+		{SpotPrice: "0.0100", AvailabilityZone: "us-east-1b"},
+		{SpotPrice: "bad", AvailabilityZone: "us-east-1c"},
+		{SpotPrice: "0.0200", AvailabilityZone: "us-east-1d"},
+	}
+}
+
+func TestChooseSpotPrice_pickZone(t *testing.T) {
+	price, zone, err := chooseSpotPrice(testSpotPriceHistory(), true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The cheapest zone, with headroom on its price
+	if zone != "us-east-1b" {
+		t.Fatalf("bad: %s", zone)
+	}
+	if price != "0.012" {
+		t.Fatalf("bad: %s", price)
+	}
+}
+
+func TestChooseSpotPrice_fixedZone(t *testing.T) {
+	price, _, err := chooseSpotPrice(testSpotPriceHistory(), false)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The zone may be any of them, so the highest price is bid on
+	if price != "0.036" {
+		t.Fatalf("bad: %s", price)
+	}
+}
+
+func TestChooseSpotPrice_roundUp(t *testing.T) {
+	history := []ec2.SpotPriceHistory{
+		{SpotPrice: "0.0031", AvailabilityZone: "us-east-1a"},
+	}
+
+	price, _, err := chooseSpotPrice(history, true)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if price != "0.004" {
+		t.Fatalf("bad: %s", price)
+	}
+}
+
+func TestChooseSpotPrice_none(t *testing.T) {
+	history := []ec2.SpotPriceHistory{
+		{SpotPrice: "bad", AvailabilityZone: "us-east-1a"},
+	}
+
+	if _, _, err := chooseSpotPrice(history, true); err == nil {
+		t.Fatal("should have error")
+	}
+
+	if _, _, err := chooseSpotPrice(nil, true); err == nil {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/packer/packer"
+	"sort"
+)
+
+// TagsTemplateData is the data available to the templates of tag values.
+// Tag values are processed when the tags are created during the build,
+// rather than when the configuration is prepared, so that they can refer
+// to details of the build.
+type TagsTemplateData struct {
+	SourceAMI   string
+	BuildRegion string
+}
+
+// PrepareTags processes the templates of the tag keys and validates the
+// templates of the tag values, which are processed later by processTags.
+// The name is used in error messages as the configuration key of the
+// tags.
+func PrepareTags(t *packer.ConfigTemplate, tags map[string]string, name string) (map[string]string, []error) {
+	errs := make([]error, 0)
+	newTags := make(map[string]string)
+	for k, v := range tags {
+		k, err := t.Process(k, nil)
+		if err != nil {
+			errs = append(errs,
+				fmt.Errorf("Error processing %s key %s: %s", name, k, err))
+			continue
+		}
+
+		if err := t.Validate(v); err != nil {
+			errs = append(errs,
+				fmt.Errorf("Error parsing %s value '%s': %s", name, v, err))
+			continue
+		}
+
+		newTags[k] = v
+	}
+
+	return newTags, errs
+}
+
+// processTags processes the templates of the tag values with the given
+// data and returns the resulting EC2 tags, sorted by key.
+func processTags(t *packer.ConfigTemplate, tags map[string]string, data *TagsTemplateData) ([]ec2.Tag, error) {
+	if t == nil {
+		var err error
+		t, err = packer.NewConfigTemplate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]ec2.Tag, 0, len(tags))
+	for _, k := range keys {
+		v, err := t.Process(tags[k], data)
+		if err != nil {
+			return nil, fmt.Errorf("Error processing tag value of %s: %s", k, err)
+		}
+
+		result = append(result, ec2.Tag{Key: k, Value: v})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/packer/packer"
+	"reflect"
+	"testing"
+)
+
+func TestPrepareTags(t *testing.T) {
+	tpl, err := packer.NewConfigTemplate()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	tags, errs := PrepareTags(tpl, map[string]string{
+		"Name":   "packer {{.SourceAMI}}",
+		"Region": "{{.BuildRegion}}",
+	}, "tags")
+	if len(errs) > 0 {
+		t.Fatalf("err: %#v", errs)
+	}
+
+	// Values are only processed when the tags are created
+	if tags["Name"] != "packer {{.SourceAMI}}" {
+		t.Fatalf("bad: %#v", tags)
+	}
+
+	_, errs = PrepareTags(tpl, map[string]string{"Name": "{{"}, "tags")
+	if len(errs) != 1 {
+		t.Fatalf("bad: %#v", errs)
+	}
+}
+
+func TestProcessTags(t *testing.T) {
+	tags := map[string]string{
+		"Source": "{{.SourceAMI}}",
+		"Region": "{{.BuildRegion}}",
+	}
+
+	result, err := processTags(nil, tags, &TagsTemplateData{
+		SourceAMI:   "ami-foo",
+		BuildRegion: "us-east-1",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := []ec2.Tag{
+		{Key: "Region", Value: "us-east-1"},
+		{Key: "Source", Value: "ami-foo"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
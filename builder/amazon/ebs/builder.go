@@ -82,6 +82,12 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 
 	// Build the steps
 	steps := []multistep.Step{
+		&awscommon.StepDeregisterAMI{
+			ForceDeregister:     b.config.PackerForce,
+			ForceDeleteSnapshot: b.config.AMIForceDeleteSnapshot,
+			AMIName:             b.config.AMIName,
+			Regions:             b.config.AMIRegions,
+		},
 		&awscommon.StepKeyPair{
 			Debug:        b.config.PackerDebug,
 			DebugKeyPath: fmt.Sprintf("ec2_%s.pem", b.config.PackerBuildName),
@@ -99,10 +105,19 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			UserData:           b.config.UserData,
 			UserDataFile:       b.config.UserDataFile,
 			SourceAMI:          b.config.SourceAmi,
+			SourceAMIFilter:    b.config.SourceAmiFilter,
+			SpotPrice:          b.config.SpotPrice,
+			SpotPriceProduct:   b.config.SpotPriceAutoProduct,
 			IamInstanceProfile: b.config.IamInstanceProfile,
 			SubnetId:           b.config.SubnetId,
 			AvailabilityZone:   b.config.AvailabilityZone,
 			BlockDevices:       b.config.BlockDevices,
+			Tags:               b.config.RunTags,
+			Tpl:                b.config.tpl,
+		},
+		&awscommon.StepGetPassword{
+			Debug:   b.config.PackerDebug,
+			Timeout: b.config.WindowsPasswordTimeout(),
 		},
 		&common.StepConnectSSH{
 			SSHAddress:     awscommon.SSHAddress(ec2conn, b.config.SSHPort),
@@ -113,7 +128,11 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		&stepStopInstance{},
 		&stepCreateAMI{},
 		&awscommon.StepAMIRegionCopy{
-			Regions: b.config.AMIRegions,
+			Name:                  b.config.AMIName,
+			Regions:               b.config.AMIRegions,
+			EncryptBootVolume:     b.config.AMIEncryptBootVolume,
+			KmsKeyIds:             b.config.AMIKmsKeyIds,
+			DeregisterUnencrypted: b.config.DeregisterUnencrypted(),
 		},
 		&awscommon.StepModifyAMIAttributes{
 			Description: b.config.AMIDescription,
@@ -121,7 +140,9 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			Groups:      b.config.AMIGroups,
 		},
 		&awscommon.StepCreateTags{
-			Tags: b.config.AMITags,
+			Tags:         b.config.AMITags,
+			SnapshotTags: b.config.SnapshotTags,
+			Tpl:          b.config.tpl,
 		},
 	}
 
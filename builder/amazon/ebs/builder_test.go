@@ -77,6 +77,52 @@ func TestBuilderPrepare_AMIName(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_DeregisterUnencrypted(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	config["encrypt_boot"] = true
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if !b.config.DeregisterUnencrypted() {
+		t.Fatal("should deregister unencrypted by default")
+	}
+
+	// Test set
+	config["deregister_unencrypted"] = false
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.DeregisterUnencrypted() {
+		t.Fatal("should keep unencrypted")
+	}
+
+	// Test without encryption
+	delete(config, "encrypt_boot")
+	delete(config, "deregister_unencrypted")
+	b = Builder{}
+	if _, err := b.Prepare(config); err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.DeregisterUnencrypted() {
+		t.Fatal("should not deregister without encrypt_boot")
+	}
+}
+
 func TestBuilderPrepare_InvalidKey(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -16,11 +16,17 @@ func (s *stepCreateAMI) Run(state multistep.StateBag) multistep.StepAction {
 	instance := state.Get("instance").(*ec2.Instance)
 	ui := state.Get("ui").(packer.Ui)
 
-	// Create the image
-	ui.Say(fmt.Sprintf("Creating the AMI: %s", config.AMIName))
+	// Create the image. If the boot volume is to be encrypted, ami_name
+	// goes to the encrypted copy instead.
+	amiName := config.AMIName
+	if config.AMIEncryptBootVolume {
+		amiName = awscommon.UnencryptedAMIName()
+	}
+
+	ui.Say(fmt.Sprintf("Creating the AMI: %s", amiName))
 	createOpts := &ec2.CreateImage{
 		InstanceId:   instance.InstanceId,
-		Name:         config.AMIName,
+		Name:         amiName,
 		BlockDevices: config.BlockDevices.BuildAMIDevices(),
 	}
 
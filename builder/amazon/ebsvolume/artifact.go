@@ -0,0 +1,89 @@
+package ebsvolume
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Artifact is an artifact implementation that contains built EBS volumes.
+type Artifact struct {
+	// A map of regions to EBS volume IDs.
+	Volumes map[string][]string
+
+	// BuilderId is the unique ID for the builder that created these volumes
+	BuilderIdValue string
+
+	// EC2 connection for performing API stuff.
+	Conn *ec2.EC2
+}
+
+func (a *Artifact) BuilderId() string {
+	return a.BuilderIdValue
+}
+
+func (*Artifact) Files() []string {
+	// We have no files
+	return nil
+}
+
+func (a *Artifact) Id() string {
+	parts := make([]string, 0, len(a.Volumes))
+	for _, region := range a.regions() {
+		for _, volumeId := range a.Volumes[region] {
+			parts = append(parts, fmt.Sprintf("%s:%s", region, volumeId))
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func (a *Artifact) String() string {
+	volumeStrings := make([]string, 0, len(a.Volumes))
+	for _, region := range a.regions() {
+		for _, volumeId := range a.Volumes[region] {
+			single := fmt.Sprintf("%s: %s", region, volumeId)
+			volumeStrings = append(volumeStrings, single)
+		}
+	}
+
+	return fmt.Sprintf("EBS volumes were created:\n\n%s", strings.Join(volumeStrings, "\n"))
+}
+
+func (a *Artifact) Destroy() error {
+	errors := make([]error, 0)
+
+	for region, volumeIds := range a.Volumes {
+		regionconn := ec2.New(a.Conn.Auth, aws.Regions[region])
+		for _, volumeId := range volumeIds {
+			log.Printf("Deleting volume ID (%s) from region (%s)", volumeId, region)
+			if _, err := regionconn.DeleteVolume(volumeId); err != nil {
+				errors = append(errors, err)
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		if len(errors) == 1 {
+			return errors[0]
+		} else {
+			return &packer.MultiError{Errors: errors}
+		}
+	}
+
+	return nil
+}
+
+func (a *Artifact) regions() []string {
+	regions := make([]string, 0, len(a.Volumes))
+	for region, _ := range a.Volumes {
+		regions = append(regions, region)
+	}
+
+	sort.Strings(regions)
+	return regions
+}
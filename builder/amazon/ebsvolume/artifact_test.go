@@ -0,0 +1,44 @@
+package ebsvolume
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"testing"
+)
+
+func TestArtifact_Impl(t *testing.T) {
+	var _ packer.Artifact = new(Artifact)
+}
+
+func TestArtifactId(t *testing.T) {
+	expected := `east:foo,east:baz,west:bar`
+
+	volumes := make(map[string][]string)
+	volumes["east"] = []string{"foo", "baz"}
+	volumes["west"] = []string{"bar"}
+
+	a := &Artifact{
+		Volumes: volumes,
+	}
+
+	result := a.Id()
+	if result != expected {
+		t.Fatalf("bad: %s", result)
+	}
+}
+
+func TestArtifactString(t *testing.T) {
+	expected := `EBS volumes were created:
+
+east: foo
+west: bar`
+
+	volumes := make(map[string][]string)
+	volumes["east"] = []string{"foo"}
+	volumes["west"] = []string{"bar"}
+
+	a := &Artifact{Volumes: volumes}
+	result := a.String()
+	if result != expected {
+		t.Fatalf("bad: %s", result)
+	}
+}
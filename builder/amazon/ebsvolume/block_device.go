@@ -0,0 +1,32 @@
+package ebsvolume
+
+import (
+	awscommon "github.com/mitchellh/packer/builder/amazon/common"
+)
+
+// BlockDevice is a launch block device mapping that can be preserved
+// after the source instance is terminated, becoming part of the artifact.
+type BlockDevice struct {
+	awscommon.BlockDevice `mapstructure:",squash"`
+
+	Preserve bool              `mapstructure:"preserve"`
+	Tags     map[string]string `mapstructure:"tags"`
+}
+
+// BuildLaunchDevices returns the block devices to launch the source
+// instance with. Preserved volumes must not be deleted when the
+// instance is terminated, so that is forced off for them.
+func BuildLaunchDevices(devices []BlockDevice) awscommon.BlockDevices {
+	result := awscommon.BlockDevices{
+		LaunchMappings: make([]awscommon.BlockDevice, len(devices)),
+	}
+
+	for i, device := range devices {
+		result.LaunchMappings[i] = device.BlockDevice
+		if device.Preserve {
+			result.LaunchMappings[i].DeleteOnTermination = false
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,186 @@
+// The ebsvolume package contains a packer.Builder implementation that
+// builds EBS volumes for Amazon EC2 using an ephemeral instance.
+package ebsvolume
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	awscommon "github.com/mitchellh/packer/builder/amazon/common"
+	"github.com/mitchellh/packer/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+)
+
+// The unique ID for this builder
+const BuilderId = "mitchellh.amazon.ebsvolume"
+
+type config struct {
+	common.PackerConfig    `mapstructure:",squash"`
+	awscommon.AccessConfig `mapstructure:",squash"`
+	awscommon.RunConfig    `mapstructure:",squash"`
+
+	VolumeMappings []BlockDevice `mapstructure:"launch_block_device_mappings"`
+
+	tpl *packer.ConfigTemplate
+}
+
+type Builder struct {
+	config config
+	runner multistep.Runner
+}
+
+func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
+	md, err := common.DecodeConfig(&b.config, raws...)
+	if err != nil {
+		return nil, err
+	}
+
+	b.config.tpl, err = packer.NewConfigTemplate()
+	if err != nil {
+		return nil, err
+	}
+	b.config.tpl.UserVars = b.config.PackerUserVars
+	b.config.tpl.Funcs(awscommon.TemplateFuncs)
+
+	// Accumulate any errors
+	errs := common.CheckUnusedConfig(md)
+	errs = packer.MultiErrorAppend(errs, b.config.AccessConfig.Prepare(b.config.tpl)...)
+	errs = packer.MultiErrorAppend(errs, b.config.RunConfig.Prepare(b.config.tpl)...)
+
+	preserved := false
+	for i, mapping := range b.config.VolumeMappings {
+		if mapping.DeviceName == "" {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+				"launch_block_device_mappings[%d]: device_name must be specified", i))
+		}
+
+		if mapping.Preserve {
+			preserved = true
+		}
+
+		newTags, tagErrs := awscommon.PrepareTags(b.config.tpl, mapping.Tags,
+			fmt.Sprintf("launch_block_device_mappings[%d] tags", i))
+		errs = packer.MultiErrorAppend(errs, tagErrs...)
+		b.config.VolumeMappings[i].Tags = newTags
+	}
+
+	if !preserved {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+			"At least one of launch_block_device_mappings must have preserve set"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, errs
+	}
+
+	log.Println(common.ScrubConfig(b.config, b.config.AccessKey, b.config.SecretKey))
+	return nil, nil
+}
+
+func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
+	region, err := b.config.Region()
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := b.config.AccessConfig.Auth()
+	if err != nil {
+		return nil, err
+	}
+
+	ec2conn := ec2.New(auth, region)
+
+	// Setup the state bag and initial state for the steps
+	state := new(multistep.BasicStateBag)
+	state.Put("config", b.config)
+	state.Put("ec2", ec2conn)
+	state.Put("hook", hook)
+	state.Put("ui", ui)
+
+	// Build the steps
+	steps := []multistep.Step{
+		&awscommon.StepKeyPair{
+			Debug:        b.config.PackerDebug,
+			DebugKeyPath: fmt.Sprintf("ec2_%s.pem", b.config.PackerBuildName),
+			KeyPairName:  b.config.TemporaryKeyPairName,
+		},
+		&awscommon.StepSecurityGroup{
+			SecurityGroupIds: b.config.SecurityGroupIds,
+			SSHPort:          b.config.SSHPort,
+			VpcId:            b.config.VpcId,
+		},
+		&stepCleanupVolumes{
+			VolumeMapping: b.config.VolumeMappings,
+		},
+		&awscommon.StepRunSourceInstance{
+			Debug:              b.config.PackerDebug,
+			ExpectedRootDevice: "ebs",
+			InstanceType:       b.config.InstanceType,
+			UserData:           b.config.UserData,
+			UserDataFile:       b.config.UserDataFile,
+			SourceAMI:          b.config.SourceAmi,
+			SourceAMIFilter:    b.config.SourceAmiFilter,
+			SpotPrice:          b.config.SpotPrice,
+			SpotPriceProduct:   b.config.SpotPriceAutoProduct,
+			IamInstanceProfile: b.config.IamInstanceProfile,
+			SubnetId:           b.config.SubnetId,
+			AvailabilityZone:   b.config.AvailabilityZone,
+			BlockDevices:       BuildLaunchDevices(b.config.VolumeMappings),
+			Tags:               b.config.RunTags,
+			Tpl:                b.config.tpl,
+		},
+		&awscommon.StepGetPassword{
+			Debug:   b.config.PackerDebug,
+			Timeout: b.config.WindowsPasswordTimeout(),
+		},
+		&common.StepConnectSSH{
+			SSHAddress:     awscommon.SSHAddress(ec2conn, b.config.SSHPort),
+			SSHConfig:      awscommon.SSHConfig(b.config.SSHUsername),
+			SSHWaitTimeout: b.config.SSHTimeout(),
+		},
+		&common.StepProvision{},
+		&stepPreserveVolumes{
+			VolumeMapping: b.config.VolumeMappings,
+			Tpl:           b.config.tpl,
+		},
+	}
+
+	// Run!
+	if b.config.PackerDebug {
+		b.runner = &multistep.DebugRunner{
+			Steps:   steps,
+			PauseFn: common.MultistepDebugFn(ui),
+		}
+	} else {
+		b.runner = &multistep.BasicRunner{Steps: steps}
+	}
+
+	b.runner.Run(state)
+
+	// If there was an error, return that
+	if rawErr, ok := state.GetOk("error"); ok {
+		return nil, rawErr.(error)
+	}
+
+	// If there are no volumes, then just return
+	if _, ok := state.GetOk("ebsvolumes"); !ok {
+		return nil, nil
+	}
+
+	// Build the artifact and return it
+	artifact := &Artifact{
+		Volumes:        state.Get("ebsvolumes").(map[string][]string),
+		BuilderIdValue: BuilderId,
+		Conn:           ec2conn,
+	}
+
+	return artifact, nil
+}
+
+func (b *Builder) Cancel() {
+	if b.runner != nil {
+		log.Println("Cancelling the step runner...")
+		b.runner.Cancel()
+	}
+}
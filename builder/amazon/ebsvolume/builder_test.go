@@ -0,0 +1,136 @@
+package ebsvolume
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"testing"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"access_key":    "foo",
+		"secret_key":    "bar",
+		"source_ami":    "foo",
+		"instance_type": "foo",
+		"region":        "us-east-1",
+		"ssh_username":  "root",
+		"launch_block_device_mappings": []map[string]interface{}{
+			map[string]interface{}{
+				"device_name": "/dev/sdb",
+				"volume_size": 10,
+				"preserve":    true,
+			},
+		},
+	}
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var raw interface{}
+	raw = &Builder{}
+	if _, ok := raw.(packer.Builder); !ok {
+		t.Fatalf("Builder should be a builder")
+	}
+}
+
+func TestBuilderPrepare(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if len(b.config.VolumeMappings) != 1 {
+		t.Fatalf("bad: %#v", b.config.VolumeMappings)
+	}
+
+	mapping := b.config.VolumeMappings[0]
+	if mapping.DeviceName != "/dev/sdb" || !mapping.Preserve {
+		t.Fatalf("bad: %#v", mapping)
+	}
+}
+
+func TestBuilderPrepare_NoPreserve(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	config["launch_block_device_mappings"] = []map[string]interface{}{
+		map[string]interface{}{
+			"device_name": "/dev/sdb",
+		},
+	}
+
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuilderPrepare_Tags(t *testing.T) {
+	var b Builder
+	config := testConfig()
+	config["launch_block_device_mappings"] = []map[string]interface{}{
+		map[string]interface{}{
+			"device_name": "/dev/sdb",
+			"preserve":    true,
+			"tags": map[string]string{
+				"Name": "{{user `name`}}",
+			},
+		},
+	}
+	config[packer.UserVariablesConfigKey] = map[string]string{"name": "data"}
+
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Tag values are processed during the build, like the tags of AMIs
+	v := b.config.VolumeMappings[0].Tags["Name"]
+	if v, err := b.config.tpl.Process(v, nil); err != nil || v != "data" {
+		t.Fatalf("bad: %#v %s", v, err)
+	}
+
+	config["launch_block_device_mappings"] = []map[string]interface{}{
+		map[string]interface{}{
+			"device_name": "/dev/sdb",
+			"preserve":    true,
+			"tags":        map[string]string{"Name": "{{"},
+		},
+	}
+
+	b = Builder{}
+	if _, err := b.Prepare(config); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestBuildLaunchDevices(t *testing.T) {
+	devices := []BlockDevice{
+		BlockDevice{Preserve: true},
+		BlockDevice{},
+	}
+	devices[0].DeviceName = "/dev/sdb"
+	devices[0].DeleteOnTermination = true
+	devices[1].DeviceName = "/dev/sdc"
+	devices[1].DeleteOnTermination = true
+
+	result := BuildLaunchDevices(devices)
+	if len(result.LaunchMappings) != 2 {
+		t.Fatalf("bad: %#v", result)
+	}
+	if result.LaunchMappings[0].DeleteOnTermination {
+		t.Fatal("preserved device should not be deleted on termination")
+	}
+	if !result.LaunchMappings[1].DeleteOnTermination {
+		t.Fatal("other device should be deleted on termination")
+	}
+}
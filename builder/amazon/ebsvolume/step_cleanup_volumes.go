@@ -0,0 +1,64 @@
+package ebsvolume
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+)
+
+// stepCleanupVolumes deletes the volumes that would have been preserved
+// if the build is cancelled or halted. It doesn't do anything when run,
+// and must come before the step that launches the source instance so that
+// its cleanup runs after the instance has been terminated.
+//
+// Uses:
+//   ec2      *ec2.EC2
+//   instance *ec2.Instance
+//   ui       packer.Ui
+//
+// Produces:
+//   <nothing>
+type stepCleanupVolumes struct {
+	VolumeMapping []BlockDevice
+}
+
+func (s *stepCleanupVolumes) Run(state multistep.StateBag) multistep.StepAction {
+	// Everything happens in the cleanup
+	return multistep.ActionContinue
+}
+
+func (s *stepCleanupVolumes) Cleanup(state multistep.StateBag) {
+	_, cancelled := state.GetOk(multistep.StateCancelled)
+	_, halted := state.GetOk(multistep.StateHalted)
+	if !cancelled && !halted {
+		return
+	}
+
+	raw, ok := state.GetOk("instance")
+	if !ok {
+		return
+	}
+
+	ec2conn := state.Get("ec2").(*ec2.EC2)
+	instance := raw.(*ec2.Instance)
+	ui := state.Get("ui").(packer.Ui)
+
+	for _, mapping := range s.VolumeMapping {
+		if !mapping.Preserve {
+			continue
+		}
+
+		for _, device := range instance.BlockDevices {
+			if device.DeviceName != mapping.DeviceName {
+				continue
+			}
+
+			ui.Say(fmt.Sprintf("Deleting volume (%s) since we cancelled or halted...", device.VolumeId))
+			if _, err := ec2conn.DeleteVolume(device.VolumeId); err != nil {
+				ui.Error(fmt.Sprintf(
+					"Error deleting volume, may still be around: %s", err))
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package ebsvolume
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	awscommon "github.com/mitchellh/packer/builder/amazon/common"
+	"github.com/mitchellh/packer/packer"
+)
+
+// stepPreserveVolumes finds the volumes of the source instance that are
+// configured to be preserved, tags them, and records their IDs. The tags
+// are added with awscommon.CreateTags, so they can refer to the build
+// like the tags of AMIs.
+//
+// Uses:
+//   ec2      *ec2.EC2
+//   instance *ec2.Instance
+//   ui       packer.Ui
+//
+// Produces:
+//   ebsvolumes map[string][]string - Region to preserved volume IDs.
+type stepPreserveVolumes struct {
+	VolumeMapping []BlockDevice
+	Tpl           *packer.ConfigTemplate
+}
+
+func (s *stepPreserveVolumes) Run(state multistep.StateBag) multistep.StepAction {
+	ec2conn := state.Get("ec2").(*ec2.EC2)
+	instance := state.Get("instance").(*ec2.Instance)
+	ui := state.Get("ui").(packer.Ui)
+
+	volumes := make(map[string][]string)
+	for _, mapping := range s.VolumeMapping {
+		if !mapping.Preserve {
+			continue
+		}
+
+		volumeId := ""
+		for _, device := range instance.BlockDevices {
+			if device.DeviceName == mapping.DeviceName {
+				volumeId = device.VolumeId
+				break
+			}
+		}
+
+		if volumeId == "" {
+			err := fmt.Errorf(
+				"Couldn't find the volume for device %s on the source instance",
+				mapping.DeviceName)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		volumes[ec2conn.Region.Name] = append(volumes[ec2conn.Region.Name], volumeId)
+
+		resource := fmt.Sprintf("volume (%s)", volumeId)
+		err := awscommon.CreateTags(state, s.Tpl, resource, []string{volumeId}, mapping.Tags)
+		if err != nil {
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+	}
+
+	state.Put("ebsvolumes", volumes)
+	return multistep.ActionContinue
+}
+
+func (s *stepPreserveVolumes) Cleanup(state multistep.StateBag) {
+	// No cleanup...
+}
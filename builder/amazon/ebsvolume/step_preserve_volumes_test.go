@@ -0,0 +1,139 @@
+package ebsvolume
+
+import (
+	"bytes"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeEC2 is a local stand-in for the EC2 API that records the
+// requests made to it.
+type fakeEC2 struct {
+	sync.Mutex
+	requests []url.Values
+}
+
+func (f *fakeEC2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	f.Lock()
+	f.requests = append(f.requests, r.Form)
+	f.Unlock()
+
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<Response>
+  <requestId>59dbff89-35bd-4eac-99ed-be587EXAMPLE</requestId>
+  <return>true</return>
+</Response>`))
+}
+
+func testEC2(t *testing.T) (*ec2.EC2, *fakeEC2, func()) {
+	fake := new(fakeEC2)
+	server := httptest.NewServer(fake)
+
+	region := aws.Region{
+		Name:        "us-east-1",
+		EC2Endpoint: server.URL,
+	}
+
+	conn := ec2.New(aws.Auth{AccessKey: "foo", SecretKey: "bar"}, region)
+	return conn, fake, server.Close
+}
+
+func testStepPreserveState(t *testing.T, conn *ec2.EC2) multistep.StateBag {
+	instance := &ec2.Instance{
+		InstanceId: "i-12345",
+		BlockDevices: []ec2.BlockDevice{
+			ec2.BlockDevice{DeviceName: "/dev/sda1", VolumeId: "vol-root"},
+			ec2.BlockDevice{DeviceName: "/dev/sdb", VolumeId: "vol-data"},
+		},
+	}
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ec2", conn)
+	state.Put("instance", instance)
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	return state
+}
+
+func TestStepPreserveVolumes_impl(t *testing.T) {
+	var _ multistep.Step = new(stepPreserveVolumes)
+}
+
+func TestStepPreserveVolumes(t *testing.T) {
+	conn, fake, closeFn := testEC2(t)
+	defer closeFn()
+
+	state := testStepPreserveState(t, conn)
+
+	mapping := BlockDevice{
+		Preserve: true,
+		Tags:     map[string]string{"Name": "data-{{.BuildRegion}}"},
+	}
+	mapping.DeviceName = "/dev/sdb"
+
+	step := &stepPreserveVolumes{
+		VolumeMapping: []BlockDevice{mapping, BlockDevice{}},
+	}
+	defer step.Cleanup(state)
+
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	volumes := state.Get("ebsvolumes").(map[string][]string)
+	expected := map[string][]string{"us-east-1": []string{"vol-data"}}
+	if !reflect.DeepEqual(volumes, expected) {
+		t.Fatalf("bad: %#v", volumes)
+	}
+
+	if len(fake.requests) != 1 {
+		t.Fatalf("bad: %#v", fake.requests)
+	}
+
+	req := fake.requests[0]
+	if req.Get("Action") != "CreateTags" {
+		t.Fatalf("bad: %#v", req)
+	}
+	if req.Get("ResourceId.1") != "vol-data" {
+		t.Fatalf("bad: %#v", req)
+	}
+	if req.Get("Tag.1.Key") != "Name" || req.Get("Tag.1.Value") != "data-us-east-1" {
+		t.Fatalf("bad: %#v", req)
+	}
+}
+
+func TestStepPreserveVolumes_missingDevice(t *testing.T) {
+	conn, _, closeFn := testEC2(t)
+	defer closeFn()
+
+	state := testStepPreserveState(t, conn)
+
+	mapping := BlockDevice{Preserve: true}
+	mapping.DeviceName = "/dev/sdz"
+
+	step := &stepPreserveVolumes{
+		VolumeMapping: []BlockDevice{mapping},
+	}
+	defer step.Cleanup(state)
+
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
@@ -155,6 +155,12 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 			errs, fmt.Errorf("x509_key_path points to bad file: %s", err))
 	}
 
+	// Only EBS volumes can be encrypted, not instance-store AMIs.
+	if b.config.AMIEncryptBootVolume {
+		errs = packer.MultiErrorAppend(errs, errors.New(
+			"encrypt_boot is not supported for instance-store AMIs"))
+	}
+
 	if errs != nil && len(errs.Errors) > 0 {
 		return nil, errs
 	}
@@ -185,6 +191,12 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 
 	// Build the steps
 	steps := []multistep.Step{
+		&awscommon.StepDeregisterAMI{
+			ForceDeregister:     b.config.PackerForce,
+			ForceDeleteSnapshot: b.config.AMIForceDeleteSnapshot,
+			AMIName:             b.config.AMIName,
+			Regions:             b.config.AMIRegions,
+		},
 		&awscommon.StepKeyPair{
 			Debug:        b.config.PackerDebug,
 			DebugKeyPath: fmt.Sprintf("ec2_%s.pem", b.config.PackerBuildName),
@@ -203,9 +215,18 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			UserData:           b.config.UserData,
 			UserDataFile:       b.config.UserDataFile,
 			SourceAMI:          b.config.SourceAmi,
+			SourceAMIFilter:    b.config.SourceAmiFilter,
+			SpotPrice:          b.config.SpotPrice,
+			SpotPriceProduct:   b.config.SpotPriceAutoProduct,
 			SubnetId:           b.config.SubnetId,
 			AvailabilityZone:   b.config.AvailabilityZone,
 			BlockDevices:       b.config.BlockDevices,
+			Tags:               b.config.RunTags,
+			Tpl:                b.config.tpl,
+		},
+		&awscommon.StepGetPassword{
+			Debug:   b.config.PackerDebug,
+			Timeout: b.config.WindowsPasswordTimeout(),
 		},
 		&common.StepConnectSSH{
 			SSHAddress:     awscommon.SSHAddress(ec2conn, b.config.SSHPort),
@@ -218,6 +239,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		&StepUploadBundle{},
 		&StepRegisterAMI{},
 		&awscommon.StepAMIRegionCopy{
+			Name:    b.config.AMIName,
 			Regions: b.config.AMIRegions,
 		},
 		&awscommon.StepModifyAMIAttributes{
@@ -227,7 +249,9 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 			ProductCodes: b.config.AMIProductCodes,
 		},
 		&awscommon.StepCreateTags{
-			Tags: b.config.AMITags,
+			Tags:         b.config.AMITags,
+			SnapshotTags: b.config.SnapshotTags,
+			Tpl:          b.config.tpl,
 		},
 	}
 
@@ -271,3 +271,17 @@ func TestBuilderPrepare_X509UploadPath(t *testing.T) {
 		t.Fatalf("should not have error: %s", err)
 	}
 }
+
+func TestBuilderPrepare_EncryptBoot(t *testing.T) {
+	b := &Builder{}
+	config := testConfig()
+
+	config["encrypt_boot"] = true
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
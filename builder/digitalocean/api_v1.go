@@ -0,0 +1,428 @@
+// The v1 API client. Plain JSON is used in place of a proper client
+// library.
+
+package digitalocean
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DIGITALOCEAN_API_URL = "https://api.digitalocean.com"
+
+// DigitalOceanClientV1 talks to the v1 API, authenticating with a client
+// ID and API key.
+type DigitalOceanClientV1 struct {
+	// The http client for communicating
+	client *http.Client
+
+	// The base URL of the API
+	BaseURL string
+
+	// Credentials
+	ClientID string
+	APIKey   string
+
+	// The time between checks of the status of an event, which
+	// defaults to 5 seconds.
+	pollInterval time.Duration
+}
+
+// Creates a new client for communicating with DO. The base URL of the API
+// defaults to DIGITALOCEAN_API_URL.
+func (d DigitalOceanClientV1) New(client string, key string, baseURL string) *DigitalOceanClientV1 {
+	if baseURL == "" {
+		baseURL = DIGITALOCEAN_API_URL
+	}
+
+	c := &DigitalOceanClientV1{
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+			},
+		},
+		BaseURL:  baseURL,
+		ClientID: client,
+		APIKey:   key,
+	}
+	return c
+}
+
+// Creates an SSH Key and returns it's id
+func (d DigitalOceanClientV1) CreateKey(name string, pub string) (uint, error) {
+	params := url.Values{}
+	params.Set("name", name)
+	params.Set("ssh_pub_key", pub)
+
+	body, err := NewRequest(d, "ssh_keys/new", params)
+	if err != nil {
+		return 0, err
+	}
+
+	// Read the SSH key's ID we just created
+	key := body["ssh_key"].(map[string]interface{})
+	keyId := key["id"].(float64)
+	return uint(keyId), nil
+}
+
+// Destroys an SSH key
+func (d DigitalOceanClientV1) DestroyKey(id uint) error {
+	path := fmt.Sprintf("ssh_keys/%v/destroy", id)
+	_, err := NewRequest(d, path, url.Values{})
+	return err
+}
+
+// Creates a droplet and returns it's id. The size, image and region
+// can be given as IDs, or as slugs or names that are looked up. The v1
+// API doesn't support IPv6 or user data.
+func (d DigitalOceanClientV1) CreateDroplet(name string, size string, image string, region string, keyId uint, opts *DropletOptions) (uint, error) {
+	if opts.IPv6 || opts.UserData != "" {
+		return 0, errors.New("IPv6 and user data require version 2 of the API")
+	}
+
+	sizeId, err := d.sizeId(size)
+	if err != nil {
+		return 0, err
+	}
+
+	imageId, err := d.imageId(image)
+	if err != nil {
+		return 0, err
+	}
+
+	regionId, err := d.regionId(region)
+	if err != nil {
+		return 0, err
+	}
+
+	params := url.Values{}
+	params.Set("name", name)
+	params.Set("size_id", fmt.Sprintf("%v", sizeId))
+	params.Set("image_id", fmt.Sprintf("%v", imageId))
+	params.Set("region_id", fmt.Sprintf("%v", regionId))
+	params.Set("ssh_key_ids", fmt.Sprintf("%v", keyId))
+	params.Set("private_networking", fmt.Sprintf("%t", opts.PrivateNetworking))
+
+	body, err := NewRequest(d, "droplets/new", params)
+	if err != nil {
+		return 0, err
+	}
+
+	// Read the Droplets ID
+	droplet := body["droplet"].(map[string]interface{})
+	dropletId := droplet["id"].(float64)
+	return uint(dropletId), err
+}
+
+// Destroys a droplet
+func (d DigitalOceanClientV1) DestroyDroplet(id uint) error {
+	path := fmt.Sprintf("droplets/%v/destroy", id)
+	_, err := NewRequest(d, path, url.Values{})
+	return err
+}
+
+// Powers off a droplet
+func (d DigitalOceanClientV1) PowerOffDroplet(id uint) error {
+	path := fmt.Sprintf("droplets/%v/power_off", id)
+
+	_, err := NewRequest(d, path, url.Values{})
+
+	return err
+}
+
+// Shutsdown a droplet. This is a "soft" shutdown.
+func (d DigitalOceanClientV1) ShutdownDroplet(id uint) error {
+	path := fmt.Sprintf("droplets/%v/shutdown", id)
+
+	_, err := NewRequest(d, path, url.Values{})
+
+	return err
+}
+
+// Creates a snaphot of a droplet by it's ID
+func (d DigitalOceanClientV1) CreateSnapshot(id uint, name string) error {
+	path := fmt.Sprintf("droplets/%v/snapshot", id)
+
+	params := url.Values{}
+	params.Set("name", name)
+
+	_, err := NewRequest(d, path, params)
+
+	return err
+}
+
+// Returns all available images.
+func (d DigitalOceanClientV1) Images() ([]Image, error) {
+	resp, err := NewRequest(d, "images", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result ImagesResp
+	if err := mapstructure.Decode(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Images, nil
+}
+
+// Transfers an image to another region, given by ID, slug or name, and
+// waits for the transfer event to be done.
+func (d DigitalOceanClientV1) TransferImage(id uint, region string) error {
+	regionId, err := d.regionId(region)
+	if err != nil {
+		return err
+	}
+
+	params := url.Values{}
+	params.Set("region_id", fmt.Sprintf("%v", regionId))
+
+	path := fmt.Sprintf("images/%v/transfer", id)
+	body, err := NewRequest(d, path, params)
+	if err != nil {
+		return err
+	}
+
+	eventId := uint(body["event_id"].(float64))
+	return d.waitForEvent(eventId)
+}
+
+// Polls an event until it is done, or doesn't finish within
+// actionTimeout.
+func (d DigitalOceanClientV1) waitForEvent(id uint) error {
+	interval := d.pollInterval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+
+	timeout := time.After(actionTimeout)
+	for {
+		body, err := NewRequest(d, fmt.Sprintf("events/%v", id), url.Values{})
+		if err != nil {
+			return err
+		}
+
+		event := body["event"].(map[string]interface{})
+		if event["action_status"] == "done" {
+			return nil
+		}
+
+		log.Printf("Waiting for event %d, percentage: %v", id, event["percentage"])
+		select {
+		case <-timeout:
+			return fmt.Errorf("Timeout while waiting for event %d", id)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Destroys an image by its ID.
+func (d DigitalOceanClientV1) DestroyImage(id uint) error {
+	path := fmt.Sprintf("images/%d/destroy", id)
+	_, err := NewRequest(d, path, url.Values{})
+	return err
+}
+
+// Returns DO's string representation of status "off" "new" "active" etc.
+func (d DigitalOceanClientV1) DropletStatus(id uint) (string, string, error) {
+	path := fmt.Sprintf("droplets/%v", id)
+
+	body, err := NewRequest(d, path, url.Values{})
+	if err != nil {
+		return "", "", err
+	}
+
+	var ip string
+
+	// Read the droplet's "status"
+	droplet := body["droplet"].(map[string]interface{})
+	status := droplet["status"].(string)
+
+	if droplet["ip_address"] != nil {
+		ip = droplet["ip_address"].(string)
+	}
+
+	return ip, status, err
+}
+
+// Sends an api request and returns a generic map[string]interface of
+// the response.
+func NewRequest(d DigitalOceanClientV1, path string, params url.Values) (map[string]interface{}, error) {
+	client := d.client
+
+	// Add the authentication parameters
+	params.Set("client_id", d.ClientID)
+	params.Set("api_key", d.APIKey)
+
+	url := fmt.Sprintf("%s/%s?%s", d.BaseURL, path, params.Encode())
+
+	// Do some basic scrubbing so sensitive information doesn't appear in logs
+	scrubbedUrl := strings.Replace(url, d.ClientID, "CLIENT_ID", -1)
+	scrubbedUrl = strings.Replace(scrubbedUrl, d.APIKey, "API_KEY", -1)
+	log.Printf("sending new request to digitalocean: %s", scrubbedUrl)
+
+	var lastErr error
+	for attempts := 1; attempts < 10; attempts++ {
+		resp, err := client.Get(url)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		log.Printf("response from digitalocean: %s", body)
+
+		var decodedResponse map[string]interface{}
+		err = json.Unmarshal(body, &decodedResponse)
+		if err != nil {
+			err = errors.New(fmt.Sprintf("Failed to decode JSON response (HTTP %v) from DigitalOcean: %s",
+				resp.StatusCode, body))
+			return decodedResponse, err
+		}
+
+		// Check for errors sent by digitalocean
+		status := decodedResponse["status"].(string)
+		if status == "OK" {
+			return decodedResponse, nil
+		}
+
+		if status == "ERROR" {
+			statusRaw, ok := decodedResponse["error_message"]
+			if ok {
+				status = statusRaw.(string)
+			} else {
+				status = fmt.Sprintf(
+					"Unknown error. Full response body: %s", body)
+			}
+		}
+
+		lastErr = errors.New(fmt.Sprintf("Received error from DigitalOcean (%d): %s",
+			resp.StatusCode, status))
+		log.Println(lastErr)
+		if strings.Contains(status, "a pending event") {
+			// Retry, DigitalOcean sends these dumb "pending event"
+			// errors all the time.
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		// Some other kind of error. Just return.
+		return decodedResponse, lastErr
+	}
+
+	return nil, lastErr
+}
+
+// Returns all available regions.
+func (d DigitalOceanClientV1) Regions() ([]Region, error) {
+	resp, err := NewRequest(d, "regions", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result RegionsResp
+	if err := mapstructure.Decode(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Regions, nil
+}
+
+// Looks up a region by its ID, slug or name.
+func (d DigitalOceanClientV1) Region(region string) (Region, error) {
+	regions, err := d.Regions()
+	if err != nil {
+		return Region{}, err
+	}
+
+	for _, r := range regions {
+		if fmt.Sprintf("%v", r.Id) == region || r.Slug == region || r.Name == region {
+			return r, nil
+		}
+	}
+
+	return Region{}, fmt.Errorf("Unknown region: %s", region)
+}
+
+// Returns all available droplet sizes.
+func (d DigitalOceanClientV1) Sizes() ([]Size, error) {
+	resp, err := NewRequest(d, "sizes", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result SizesResp
+	if err := mapstructure.Decode(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Sizes, nil
+}
+
+// The v1 API only takes IDs, so these resolve the slugs or names of
+// sizes, images and regions to their IDs. Values that already are IDs
+// are used as is, without asking the API.
+
+func (d DigitalOceanClientV1) sizeId(size string) (uint, error) {
+	if id, err := strconv.ParseUint(size, 10, 0); err == nil {
+		return uint(id), nil
+	}
+
+	sizes, err := d.Sizes()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, s := range sizes {
+		if s.Slug == size || s.Name == size {
+			return s.Id, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown size: %s", size)
+}
+
+func (d DigitalOceanClientV1) imageId(image string) (uint, error) {
+	if id, err := strconv.ParseUint(image, 10, 0); err == nil {
+		return uint(id), nil
+	}
+
+	images, err := d.Images()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, i := range images {
+		if i.Slug == image || i.Name == image {
+			return i.Id, nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown image: %s", image)
+}
+
+func (d DigitalOceanClientV1) regionId(region string) (uint, error) {
+	if id, err := strconv.ParseUint(region, 10, 0); err == nil {
+		return uint(id), nil
+	}
+
+	r, err := d.Region(region)
+	if err != nil {
+		return 0, err
+	}
+
+	return r.Id, nil
+}
@@ -0,0 +1,201 @@
+package digitalocean
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// fakeV1 is a local stand-in for the v1 API that serves canned JSON
+// responses by path and records the requests made to it.
+type fakeV1 struct {
+	sync.Mutex
+	responses map[string]string
+	requests  map[string]url.Values
+}
+
+func (f *fakeV1) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.requests[r.URL.Path] = r.URL.Query()
+
+	resp, ok := f.responses[r.URL.Path]
+	if !ok {
+		resp = `{"status": "ERROR", "error_message": "Not Found"}`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(resp))
+}
+
+func testClientV1(t *testing.T, responses map[string]string) (*DigitalOceanClientV1, *fakeV1, func()) {
+	fake := &fakeV1{
+		responses: responses,
+		requests:  make(map[string]url.Values),
+	}
+	server := httptest.NewServer(fake)
+
+	client := DigitalOceanClientV1{}.New("foo", "bar", server.URL)
+	return client, fake, server.Close
+}
+
+func TestDigitalOceanClientV1_Impl(t *testing.T) {
+	var _ DigitalOceanClient = new(DigitalOceanClientV1)
+}
+
+func TestDigitalOceanClientV1_CreateDroplet(t *testing.T) {
+	client, fake, closeFn := testClientV1(t, map[string]string{
+		"/droplets/new": `{"status": "OK", "droplet": {"id": 100}}`,
+	})
+	defer closeFn()
+
+	id, err := client.CreateDroplet("foo", "66", "284203", "1", 42,
+		&DropletOptions{PrivateNetworking: true})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != 100 {
+		t.Fatalf("bad: %d", id)
+	}
+
+	params := fake.requests["/droplets/new"]
+	expected := map[string]string{
+		"client_id":          "foo",
+		"api_key":            "bar",
+		"name":               "foo",
+		"size_id":            "66",
+		"image_id":           "284203",
+		"region_id":          "1",
+		"ssh_key_ids":        "42",
+		"private_networking": "true",
+	}
+	for k, v := range expected {
+		if params.Get(k) != v {
+			t.Fatalf("bad %s: %#v", k, params)
+		}
+	}
+
+	// IDs are used as is
+	if len(fake.requests) != 1 {
+		t.Fatalf("bad: %#v", fake.requests)
+	}
+}
+
+func TestDigitalOceanClientV1_CreateDroplet_slugs(t *testing.T) {
+	client, fake, closeFn := testClientV1(t, map[string]string{
+		"/droplets/new": `{"status": "OK", "droplet": {"id": 100}}`,
+		"/sizes":        `{"status": "OK", "sizes": [{"id": 66, "name": "512MB", "slug": "512mb"}]}`,
+		"/images":       `{"status": "OK", "images": [{"id": 3101045, "name": "Ubuntu 14.04 x64", "slug": "ubuntu-14-04-x64"}]}`,
+		"/regions":      `{"status": "OK", "regions": [{"id": 3, "name": "San Francisco 1", "slug": "sfo1"}]}`,
+	})
+	defer closeFn()
+
+	if _, err := client.CreateDroplet("foo", "512mb", "ubuntu-14-04-x64", "sfo1", 42, &DropletOptions{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	params := fake.requests["/droplets/new"]
+	if params.Get("size_id") != "66" ||
+		params.Get("image_id") != "3101045" ||
+		params.Get("region_id") != "3" {
+		t.Fatalf("bad: %#v", params)
+	}
+
+	// Unknown slugs are an error
+	if _, err := client.CreateDroplet("foo", "512mb", "ubuntu-14-04-x64", "nyc9", 42, &DropletOptions{}); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestDigitalOceanClientV1_CreateDroplet_v2Options(t *testing.T) {
+	client, fake, closeFn := testClientV1(t, map[string]string{
+		"/droplets/new": `{"status": "OK", "droplet": {"id": 100}}`,
+	})
+	defer closeFn()
+
+	opts := []*DropletOptions{
+		&DropletOptions{IPv6: true},
+		&DropletOptions{UserData: "#cloud-config"},
+	}
+	for _, o := range opts {
+		if _, err := client.CreateDroplet("foo", "66", "284203", "1", 42, o); err == nil {
+			t.Fatalf("should have error: %#v", o)
+		}
+	}
+
+	if len(fake.requests) != 0 {
+		t.Fatalf("bad: %#v", fake.requests)
+	}
+}
+
+func TestDigitalOceanClientV1_TransferImage(t *testing.T) {
+	client, fake, closeFn := testClientV1(t, map[string]string{
+		"/regions":            `{"status": "OK", "regions": [{"id": 3, "name": "San Francisco 1", "slug": "sfo1"}]}`,
+		"/images/42/transfer": `{"status": "OK", "event_id": 7501}`,
+		"/events/7501":        `{"status": "OK", "event": {"id": 7501, "action_status": "done", "percentage": "100"}}`,
+	})
+	defer closeFn()
+
+	if err := client.TransferImage(42, "sfo1"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if fake.requests["/images/42/transfer"].Get("region_id") != "3" {
+		t.Fatalf("bad: %#v", fake.requests)
+	}
+	if _, ok := fake.requests["/events/7501"]; !ok {
+		t.Fatalf("bad: %#v", fake.requests)
+	}
+}
+
+func TestDigitalOceanClientV1_DropletStatus(t *testing.T) {
+	client, _, closeFn := testClientV1(t, map[string]string{
+		"/droplets/100": `{"status": "OK", "droplet": {"id": 100, "status": "active", "ip_address": "10.0.0.1"}}`,
+	})
+	defer closeFn()
+
+	ip, status, err := client.DropletStatus(100)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ip != "10.0.0.1" || status != "active" {
+		t.Fatalf("bad: %s %s", ip, status)
+	}
+}
+
+func TestDigitalOceanClientV1_Region(t *testing.T) {
+	client, _, closeFn := testClientV1(t, map[string]string{
+		"/regions": `{"status": "OK", "regions": [{"id": 3, "name": "San Francisco 1", "slug": "sfo1"}]}`,
+	})
+	defer closeFn()
+
+	for _, name := range []string{"3", "sfo1", "San Francisco 1"} {
+		region, err := client.Region(name)
+		if err != nil {
+			t.Fatalf("%s: err: %s", name, err)
+		}
+		if region.Name != "San Francisco 1" {
+			t.Fatalf("%s: bad: %#v", name, region)
+		}
+	}
+
+	if _, err := client.Region("nyc9"); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestDigitalOceanClientV1_error(t *testing.T) {
+	client, _, closeFn := testClientV1(t, map[string]string{})
+	defer closeFn()
+
+	err := client.DestroyDroplet(100)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if err.Error() != "Received error from DigitalOcean (200): Not Found" {
+		t.Fatalf("bad: %s", err)
+	}
+}
@@ -0,0 +1,403 @@
+// The v2 API client. Like the v1 client, plain JSON is used in place of a
+// proper client library.
+
+package digitalocean
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DIGITALOCEAN_API_V2_URL = "https://api.digitalocean.com/v2"
+
+// DigitalOceanClientV2 talks to the v2 API, authenticating with a
+// personal access token.
+type DigitalOceanClientV2 struct {
+	// The http client for communicating
+	client *http.Client
+
+	// The base URL of the API
+	BaseURL string
+
+	// Credentials
+	APIToken string
+
+	// The time between checks of the status of an action, which
+	// defaults to 5 seconds.
+	pollInterval time.Duration
+}
+
+type v2Action struct {
+	Id     uint   `json:"id"`
+	Status string `json:"status"`
+	Type   string `json:"type"`
+}
+
+type v2Droplet struct {
+	Id       uint   `json:"id"`
+	Status   string `json:"status"`
+	Networks struct {
+		V4 []struct {
+			IPAddress string `json:"ip_address"`
+			Type      string `json:"type"`
+		} `json:"v4"`
+	} `json:"networks"`
+}
+
+type v2Links struct {
+	Pages struct {
+		Next string `json:"next"`
+	} `json:"pages"`
+}
+
+// Creates a new client for communicating with DO. The base URL of the API
+// defaults to DIGITALOCEAN_API_V2_URL.
+func (d DigitalOceanClientV2) New(token string, baseURL string) *DigitalOceanClientV2 {
+	if baseURL == "" {
+		baseURL = DIGITALOCEAN_API_V2_URL
+	}
+
+	c := &DigitalOceanClientV2{
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+			},
+		},
+		BaseURL:  strings.TrimRight(baseURL, "/"),
+		APIToken: token,
+	}
+	return c
+}
+
+// Creates an SSH Key and returns it's id
+func (d DigitalOceanClientV2) CreateKey(name string, pub string) (uint, error) {
+	params := map[string]interface{}{
+		"name":       name,
+		"public_key": pub,
+	}
+
+	var resp struct {
+		SSHKey struct {
+			Id uint `json:"id"`
+		} `json:"ssh_key"`
+	}
+	if err := d.request("POST", "account/keys", params, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.SSHKey.Id, nil
+}
+
+// Destroys an SSH key
+func (d DigitalOceanClientV2) DestroyKey(id uint) error {
+	return d.request("DELETE", fmt.Sprintf("account/keys/%v", id), nil, nil)
+}
+
+// Creates a droplet and returns it's id. The size and region are slugs,
+// and the image is either a slug or an ID.
+func (d DigitalOceanClientV2) CreateDroplet(name string, size string, image string, region string, keyId uint, opts *DropletOptions) (uint, error) {
+	params := map[string]interface{}{
+		"name":               name,
+		"size":               size,
+		"image":              image,
+		"region":             region,
+		"ssh_keys":           []uint{keyId},
+		"private_networking": opts.PrivateNetworking,
+		"ipv6":               opts.IPv6,
+	}
+
+	if opts.UserData != "" {
+		params["user_data"] = opts.UserData
+	}
+
+	// Images are referred to by slug, or by ID for private images
+	// which don't have one.
+	if id, err := strconv.ParseUint(image, 10, 0); err == nil {
+		params["image"] = id
+	}
+
+	var resp struct {
+		Droplet v2Droplet `json:"droplet"`
+	}
+	if err := d.request("POST", "droplets", params, &resp); err != nil {
+		return 0, err
+	}
+
+	return resp.Droplet.Id, nil
+}
+
+// Destroys a droplet
+func (d DigitalOceanClientV2) DestroyDroplet(id uint) error {
+	return d.request("DELETE", fmt.Sprintf("droplets/%v", id), nil, nil)
+}
+
+// Powers off a droplet
+func (d DigitalOceanClientV2) PowerOffDroplet(id uint) error {
+	_, err := d.dropletAction(id, map[string]interface{}{"type": "power_off"})
+	return err
+}
+
+// Shutsdown a droplet. This is a "soft" shutdown.
+func (d DigitalOceanClientV2) ShutdownDroplet(id uint) error {
+	_, err := d.dropletAction(id, map[string]interface{}{"type": "shutdown"})
+	return err
+}
+
+// Creates a snaphot of a droplet by it's ID. The snapshot only shows up
+// in the images once the snapshot action has completed, so this waits
+// for it.
+func (d DigitalOceanClientV2) CreateSnapshot(id uint, name string) error {
+	action, err := d.dropletAction(id, map[string]interface{}{
+		"type": "snapshot",
+		"name": name,
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.waitForAction(action)
+}
+
+// Transfers an image to another region, given by slug, and waits for
+// the transfer action to complete.
+func (d DigitalOceanClientV2) TransferImage(id uint, region string) error {
+	var resp struct {
+		Action v2Action `json:"action"`
+	}
+	params := map[string]interface{}{
+		"type":   "transfer",
+		"region": region,
+	}
+	path := fmt.Sprintf("images/%v/actions", id)
+	if err := d.request("POST", path, params, &resp); err != nil {
+		return err
+	}
+
+	return d.waitForAction(&resp.Action)
+}
+
+// Returns all available images.
+func (d DigitalOceanClientV2) Images() ([]Image, error) {
+	var result []Image
+	path := "images?per_page=200"
+	for path != "" {
+		var resp struct {
+			Images []Image `json:"images"`
+			Links  v2Links `json:"links"`
+		}
+		if err := d.request("GET", path, nil, &resp); err != nil {
+			return nil, err
+		}
+
+		result = append(result, resp.Images...)
+		path = resp.Links.Pages.Next
+	}
+
+	return result, nil
+}
+
+// Destroys an image by its ID.
+func (d DigitalOceanClientV2) DestroyImage(id uint) error {
+	return d.request("DELETE", fmt.Sprintf("images/%v", id), nil, nil)
+}
+
+// Returns DO's string representation of status "off" "new" "active" etc.
+func (d DigitalOceanClientV2) DropletStatus(id uint) (string, string, error) {
+	var resp struct {
+		Droplet v2Droplet `json:"droplet"`
+	}
+	if err := d.request("GET", fmt.Sprintf("droplets/%v", id), nil, &resp); err != nil {
+		return "", "", err
+	}
+
+	var ip string
+	for _, n := range resp.Droplet.Networks.V4 {
+		if n.Type == "public" {
+			ip = n.IPAddress
+		}
+	}
+
+	return ip, resp.Droplet.Status, nil
+}
+
+// Returns all available regions.
+func (d DigitalOceanClientV2) Regions() ([]Region, error) {
+	var result []Region
+	path := "regions"
+	for path != "" {
+		var resp struct {
+			Regions []Region `json:"regions"`
+			Links   v2Links  `json:"links"`
+		}
+		if err := d.request("GET", path, nil, &resp); err != nil {
+			return nil, err
+		}
+
+		result = append(result, resp.Regions...)
+		path = resp.Links.Pages.Next
+	}
+
+	return result, nil
+}
+
+// Looks up a region by its slug or name.
+func (d DigitalOceanClientV2) Region(region string) (Region, error) {
+	regions, err := d.Regions()
+	if err != nil {
+		return Region{}, err
+	}
+
+	for _, r := range regions {
+		if r.Slug == region || r.Name == region {
+			return r, nil
+		}
+	}
+
+	return Region{}, fmt.Errorf("Unknown region: %s", region)
+}
+
+// Starts an action on a droplet, such as "power_off", and returns it.
+func (d DigitalOceanClientV2) dropletAction(id uint, params map[string]interface{}) (*v2Action, error) {
+	var resp struct {
+		Action v2Action `json:"action"`
+	}
+	path := fmt.Sprintf("droplets/%v/actions", id)
+	if err := d.request("POST", path, params, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp.Action, nil
+}
+
+// Polls an action until it has completed, returning an error if it
+// errored or doesn't complete within actionTimeout.
+func (d DigitalOceanClientV2) waitForAction(action *v2Action) error {
+	interval := d.pollInterval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+
+	timeout := time.After(actionTimeout)
+	for {
+		switch action.Status {
+		case "completed":
+			return nil
+		case "errored":
+			return fmt.Errorf("Action %s (%d) errored", action.Type, action.Id)
+		}
+
+		log.Printf("Waiting for action %s (%d), status: %s", action.Type, action.Id, action.Status)
+		select {
+		case <-timeout:
+			return fmt.Errorf("Timeout while waiting for action %s (%d)", action.Type, action.Id)
+		case <-time.After(interval):
+		}
+
+		var resp struct {
+			Action v2Action `json:"action"`
+		}
+		if err := d.request("GET", fmt.Sprintf("actions/%v", action.Id), nil, &resp); err != nil {
+			return err
+		}
+
+		action = &resp.Action
+	}
+}
+
+// Sends an API request with the given parameters as its JSON body, and
+// decodes the JSON response into result, if it isn't nil. The path is
+// relative to the base URL, unless it is a full URL such as the link to
+// the next page of a listing.
+func (d DigitalOceanClientV2) request(method string, path string, params map[string]interface{}, result interface{}) error {
+	url := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		url = fmt.Sprintf("%s/%s", d.BaseURL, path)
+	}
+
+	var body []byte
+	if params != nil {
+		var err error
+		body, err = json.Marshal(params)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("sending new request to digitalocean: %s %s", method, url)
+
+	var lastErr error
+	for attempts := 1; attempts < 10; attempts++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+
+		req, err := http.NewRequest(method, url, reader)
+		if err != nil {
+			return err
+		}
+
+		req.Header.Set("Authorization", "Bearer "+d.APIToken)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		respBody, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		log.Printf("response from digitalocean: %s", respBody)
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			if result == nil || len(respBody) == 0 {
+				return nil
+			}
+
+			if err := json.Unmarshal(respBody, result); err != nil {
+				return fmt.Errorf("Failed to decode JSON response (HTTP %v) from DigitalOcean: %s",
+					resp.StatusCode, respBody)
+			}
+
+			return nil
+		}
+
+		var errResp struct {
+			Id      string `json:"id"`
+			Message string `json:"message"`
+		}
+		message := fmt.Sprintf("Unknown error. Full response body: %s", respBody)
+		if err := json.Unmarshal(respBody, &errResp); err == nil && errResp.Message != "" {
+			message = errResp.Message
+		}
+
+		lastErr = fmt.Errorf("Received error from DigitalOcean (%d): %s",
+			resp.StatusCode, message)
+		log.Println(lastErr)
+		if strings.Contains(message, "pending event") {
+			// Retry, just like with the v1 API, DigitalOcean refuses
+			// actions on droplets while another one is running.
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		// Some other kind of error. Just return.
+		return lastErr
+	}
+
+	return lastErr
+}
@@ -0,0 +1,246 @@
+package digitalocean
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeV2 is a local stand-in for the v2 API that serves canned JSON
+// responses by method and path, and records the requests made to it.
+type fakeV2 struct {
+	sync.Mutex
+	responses map[string][]string
+	requests  []fakeV2Request
+}
+
+type fakeV2Request struct {
+	Route         string
+	Authorization string
+	Body          map[string]interface{}
+}
+
+func (f *fakeV2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	route := r.Method + " " + r.URL.RequestURI()
+	req := fakeV2Request{
+		Route:         route,
+		Authorization: r.Header.Get("Authorization"),
+	}
+	if body, _ := ioutil.ReadAll(r.Body); len(body) > 0 {
+		json.Unmarshal(body, &req.Body)
+	}
+	f.requests = append(f.requests, req)
+
+	// Responses are served in order, repeating the last one.
+	responses := f.responses[route]
+	if len(responses) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"id": "not_found", "message": "The resource you were accessing could not be found."}`))
+		return
+	}
+
+	if len(responses) > 1 {
+		f.responses[route] = responses[1:]
+	}
+
+	if responses[0] == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(responses[0]))
+}
+
+func testClientV2(t *testing.T, responses map[string][]string) (*DigitalOceanClientV2, *fakeV2, func()) {
+	fake := &fakeV2{responses: responses}
+	server := httptest.NewServer(fake)
+
+	client := DigitalOceanClientV2{}.New("foo", server.URL)
+	client.pollInterval = 10 * time.Millisecond
+	return client, fake, server.Close
+}
+
+func TestDigitalOceanClientV2_Impl(t *testing.T) {
+	var _ DigitalOceanClient = new(DigitalOceanClientV2)
+}
+
+func TestDigitalOceanClientV2_CreateDroplet(t *testing.T) {
+	client, fake, closeFn := testClientV2(t, map[string][]string{
+		"POST /droplets": {`{"droplet": {"id": 100, "status": "new"}}`},
+	})
+	defer closeFn()
+
+	id, err := client.CreateDroplet("foo", "512mb", "ubuntu-14-04-x64", "nyc3", 42, &DropletOptions{
+		PrivateNetworking: true,
+		IPv6:              true,
+		UserData:          "#cloud-config",
+	})
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if id != 100 {
+		t.Fatalf("bad: %d", id)
+	}
+
+	req := fake.requests[0]
+	if req.Authorization != "Bearer foo" {
+		t.Fatalf("bad: %s", req.Authorization)
+	}
+	if req.Body["name"] != "foo" || req.Body["size"] != "512mb" ||
+		req.Body["image"] != "ubuntu-14-04-x64" || req.Body["region"] != "nyc3" {
+		t.Fatalf("bad: %#v", req.Body)
+	}
+
+	if req.Body["private_networking"] != true || req.Body["ipv6"] != true ||
+		req.Body["user_data"] != "#cloud-config" {
+		t.Fatalf("bad: %#v", req.Body)
+	}
+
+	keys := req.Body["ssh_keys"].([]interface{})
+	if len(keys) != 1 || keys[0] != float64(42) {
+		t.Fatalf("bad: %#v", req.Body)
+	}
+
+	// Private images are referred to by ID
+	if _, err := client.CreateDroplet("foo", "512mb", "123", "nyc3", 42, &DropletOptions{}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if fake.requests[1].Body["image"] != float64(123) {
+		t.Fatalf("bad: %#v", fake.requests[1].Body)
+	}
+
+	// User data is left out unless it is set
+	if _, ok := fake.requests[1].Body["user_data"]; ok {
+		t.Fatalf("bad: %#v", fake.requests[1].Body)
+	}
+}
+
+func TestDigitalOceanClientV2_DropletStatus(t *testing.T) {
+	client, _, closeFn := testClientV2(t, map[string][]string{
+		"GET /droplets/100": {`{"droplet": {"id": 100, "status": "active", "networks": {"v4": [
+			{"ip_address": "10.128.0.2", "type": "private"},
+			{"ip_address": "104.131.0.2", "type": "public"}
+		]}}}`},
+	})
+	defer closeFn()
+
+	ip, status, err := client.DropletStatus(100)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if ip != "104.131.0.2" || status != "active" {
+		t.Fatalf("bad: %s %s", ip, status)
+	}
+}
+
+func TestDigitalOceanClientV2_CreateSnapshot(t *testing.T) {
+	client, fake, closeFn := testClientV2(t, map[string][]string{
+		"POST /droplets/100/actions": {`{"action": {"id": 7, "status": "in-progress", "type": "snapshot"}}`},
+		"GET /actions/7": {
+			`{"action": {"id": 7, "status": "in-progress", "type": "snapshot"}}`,
+			`{"action": {"id": 7, "status": "completed", "type": "snapshot"}}`,
+		},
+	})
+	defer closeFn()
+
+	if err := client.CreateSnapshot(100, "packer-foo"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(fake.requests) != 3 {
+		t.Fatalf("bad: %#v", fake.requests)
+	}
+	if fake.requests[0].Body["type"] != "snapshot" || fake.requests[0].Body["name"] != "packer-foo" {
+		t.Fatalf("bad: %#v", fake.requests[0].Body)
+	}
+}
+
+func TestDigitalOceanClientV2_CreateSnapshot_errored(t *testing.T) {
+	client, _, closeFn := testClientV2(t, map[string][]string{
+		"POST /droplets/100/actions": {`{"action": {"id": 7, "status": "in-progress", "type": "snapshot"}}`},
+		"GET /actions/7":             {`{"action": {"id": 7, "status": "errored", "type": "snapshot"}}`},
+	})
+	defer closeFn()
+
+	if err := client.CreateSnapshot(100, "packer-foo"); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestDigitalOceanClientV2_TransferImage(t *testing.T) {
+	client, fake, closeFn := testClientV2(t, map[string][]string{
+		"POST /images/42/actions": {`{"action": {"id": 8, "status": "in-progress", "type": "transfer"}}`},
+		"GET /actions/8":          {`{"action": {"id": 8, "status": "completed", "type": "transfer"}}`},
+	})
+	defer closeFn()
+
+	if err := client.TransferImage(42, "sfo1"); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(fake.requests) != 2 {
+		t.Fatalf("bad: %#v", fake.requests)
+	}
+	if fake.requests[0].Body["type"] != "transfer" || fake.requests[0].Body["region"] != "sfo1" {
+		t.Fatalf("bad: %#v", fake.requests[0].Body)
+	}
+}
+
+func TestDigitalOceanClientV2_Images(t *testing.T) {
+	client, fake, closeFn := testClientV2(t, map[string][]string{})
+	defer closeFn()
+
+	// The link to the next page is a full URL
+	fake.responses["GET /images?per_page=200"] = []string{
+		`{"images": [{"id": 1, "name": "packer-1", "slug": null}],
+		"links": {"pages": {"next": "` + client.BaseURL + `/images?page=2&per_page=200"}}}`,
+	}
+	fake.responses["GET /images?page=2&per_page=200"] = []string{
+		`{"images": [{"id": 2, "name": "Ubuntu", "slug": "ubuntu-14-04-x64"}], "links": {}}`,
+	}
+
+	images, err := client.Images()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(images) != 2 || images[0].Name != "packer-1" || images[1].Slug != "ubuntu-14-04-x64" {
+		t.Fatalf("bad: %#v", images)
+	}
+}
+
+func TestDigitalOceanClientV2_DestroyDroplet(t *testing.T) {
+	client, fake, closeFn := testClientV2(t, map[string][]string{
+		"DELETE /droplets/100": {""},
+	})
+	defer closeFn()
+
+	if err := client.DestroyDroplet(100); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(fake.requests) != 1 {
+		t.Fatalf("bad: %#v", fake.requests)
+	}
+}
+
+func TestDigitalOceanClientV2_error(t *testing.T) {
+	client, _, closeFn := testClientV2(t, map[string][]string{})
+	defer closeFn()
+
+	err := client.DestroyKey(42)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	expected := "Received error from DigitalOcean (404): The resource you were accessing could not be found."
+	if err.Error() != expected {
+		t.Fatalf("bad: %s", err)
+	}
+}
@@ -3,6 +3,8 @@ package digitalocean
 import (
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 )
 
 type Artifact struct {
@@ -12,14 +14,15 @@ type Artifact struct {
 	// The ID of the image
 	snapshotId uint
 
-	// The name of the region
-	regionName string
+	// The slug of the region the snapshot was created in
+	region string
 
-	// The ID of the region
-	regionId uint
+	// A map of the slugs of the regions the snapshot is available in to
+	// the name of the snapshot there
+	snapshots map[string]string
 
 	// The client for making API calls
-	client *DigitalOceanClient
+	client DigitalOceanClient
 }
 
 func (*Artifact) BuilderId() string {
@@ -32,15 +35,47 @@ func (*Artifact) Files() []string {
 }
 
 func (a *Artifact) Id() string {
-	// mimicing the aws builder
-	return fmt.Sprintf("%s:%s", a.regionName, a.snapshotName)
+	// mimicing the aws builder, with the region the snapshot was created
+	// in first
+	parts := make([]string, 0, len(a.snapshots))
+	for _, region := range a.regions() {
+		parts = append(parts, fmt.Sprintf("%s:%s", region, a.snapshots[region]))
+	}
+
+	return strings.Join(parts, ",")
 }
 
 func (a *Artifact) String() string {
-	return fmt.Sprintf("A snapshot was created: '%v' in region '%v'", a.snapshotName, a.regionName)
+	regions := a.regions()
+	if len(regions) == 1 {
+		return fmt.Sprintf("A snapshot was created: '%v' in region '%v'", a.snapshotName, regions[0])
+	}
+
+	return fmt.Sprintf("A snapshot was created: '%v' in regions '%v'",
+		a.snapshotName, strings.Join(regions, "', '"))
 }
 
 func (a *Artifact) Destroy() error {
+	// The image has the same ID in all regions, and destroying it
+	// destroys it everywhere.
 	log.Printf("Destroying image: %d (%s)", a.snapshotId, a.snapshotName)
 	return a.client.DestroyImage(a.snapshotId)
 }
+
+// regions returns the slugs of the regions the snapshot is available in,
+// starting with the one it was created in and then sorted.
+func (a *Artifact) regions() []string {
+	others := make([]string, 0, len(a.snapshots))
+	for region := range a.snapshots {
+		if region != a.region {
+			others = append(others, region)
+		}
+	}
+	sort.Strings(others)
+
+	if _, ok := a.snapshots[a.region]; !ok {
+		return others
+	}
+
+	return append([]string{a.region}, others...)
+}
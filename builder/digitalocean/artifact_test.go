@@ -13,9 +13,30 @@ func TestArtifact_Impl(t *testing.T) {
 	}
 }
 
+func TestArtifactId(t *testing.T) {
+	snapshots := map[string]string{
+		"sfo1": "packer-foobar",
+		"ams2": "packer-foobar",
+		"nyc2": "packer-foobar",
+	}
+	a := &Artifact{"packer-foobar", 42, "nyc2", snapshots, nil}
+	expected := "nyc2:packer-foobar,ams2:packer-foobar,sfo1:packer-foobar"
+
+	if a.Id() != expected {
+		t.Fatalf("artifact ID should match: %v", a.Id())
+	}
+}
+
 func TestArtifactString(t *testing.T) {
-	a := &Artifact{"packer-foobar", 42, "San Francisco", 3, nil}
-	expected := "A snapshot was created: 'packer-foobar' in region 'San Francisco'"
+	a := &Artifact{"packer-foobar", 42, "sfo1", map[string]string{"sfo1": "packer-foobar"}, nil}
+	expected := "A snapshot was created: 'packer-foobar' in region 'sfo1'"
+
+	if a.String() != expected {
+		t.Fatalf("artifact string should match: %v", expected)
+	}
+
+	a.snapshots["nyc2"] = "packer-foobar"
+	expected = "A snapshot was created: 'packer-foobar' in regions 'sfo1', 'nyc2'"
 
 	if a.String() != expected {
 		t.Fatalf("artifact string should match: %v", expected)
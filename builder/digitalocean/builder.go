@@ -18,6 +18,13 @@ import (
 // The unique id for the builder
 const BuilderId = "pearkes.digitalocean"
 
+// The defaults for the region, size and image slugs.
+const (
+	DefaultRegion = "nyc1"
+	DefaultSize   = "512mb"
+	DefaultImage  = "ubuntu-12-04-x64"
+)
+
 // Configuration tells the builder the credentials
 // to use while communicating with DO and describes the image
 // you are creating
@@ -26,10 +33,21 @@ type config struct {
 
 	ClientID string `mapstructure:"client_id"`
 	APIKey   string `mapstructure:"api_key"`
+	APIToken string `mapstructure:"api_token"`
+	APIURL   string `mapstructure:"api_url"`
 	RegionID uint   `mapstructure:"region_id"`
 	SizeID   uint   `mapstructure:"size_id"`
 	ImageID  uint   `mapstructure:"image_id"`
 
+	Region string `mapstructure:"region"`
+	Size   string `mapstructure:"size"`
+	Image  string `mapstructure:"image"`
+
+	PrivateNetworking bool     `mapstructure:"private_networking"`
+	IPv6              bool     `mapstructure:"ipv6"`
+	UserData          string   `mapstructure:"user_data"`
+	SnapshotRegions   []string `mapstructure:"snapshot_regions"`
+
 	SnapshotName string `mapstructure:"snapshot_name"`
 	DropletName  string `mapstructure:"droplet_name"`
 	SSHUsername  string `mapstructure:"ssh_username"`
@@ -77,19 +95,59 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		b.config.ClientID = os.Getenv("DIGITALOCEAN_CLIENT_ID")
 	}
 
-	if b.config.RegionID == 0 {
-		// Default to Region "New York"
-		b.config.RegionID = 1
+	if b.config.APIToken == "" {
+		// Default to environment variable for api_token, if it exists
+		b.config.APIToken = os.Getenv("DIGITALOCEAN_API_TOKEN")
+	}
+
+	if b.config.APIURL == "" {
+		// Default to environment variable for api_url, if it exists.
+		// The client picks the URL of the API version otherwise.
+		b.config.APIURL = os.Getenv("DIGITALOCEAN_API_URL")
+	}
+
+	// The v1 API refers to regions, sizes and images by ID, while v2 uses
+	// slugs. The slugs work with both, so the IDs are only defaulted when
+	// using v1 and no slug is set, for backwards compatibility.
+	if b.config.APIToken == "" {
+		if b.config.RegionID == 0 && b.config.Region == "" {
+			// Default to Region "New York"
+			b.config.RegionID = 1
+		}
+
+		if b.config.SizeID == 0 && b.config.Size == "" {
+			// Default to 512mb, the smallest droplet size
+			b.config.SizeID = 66
+		}
+
+		if b.config.ImageID == 0 && b.config.Image == "" {
+			// Default to base image "Ubuntu 12.04 x64 Server (id: 284203)"
+			b.config.ImageID = 284203
+		}
+	}
+
+	if b.config.Region == "" {
+		if b.config.RegionID != 0 {
+			b.config.Region = fmt.Sprintf("%v", b.config.RegionID)
+		} else {
+			b.config.Region = DefaultRegion
+		}
 	}
 
-	if b.config.SizeID == 0 {
-		// Default to 512mb, the smallest droplet size
-		b.config.SizeID = 66
+	if b.config.Size == "" {
+		if b.config.SizeID != 0 {
+			b.config.Size = fmt.Sprintf("%v", b.config.SizeID)
+		} else {
+			b.config.Size = DefaultSize
+		}
 	}
 
-	if b.config.ImageID == 0 {
-		// Default to base image "Ubuntu 12.04 x64 Server (id: 284203)"
-		b.config.ImageID = 284203
+	if b.config.Image == "" {
+		if b.config.ImageID != 0 {
+			b.config.Image = fmt.Sprintf("%v", b.config.ImageID)
+		} else {
+			b.config.Image = DefaultImage
+		}
 	}
 
 	if b.config.SnapshotName == "" {
@@ -127,6 +185,12 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	templates := map[string]*string{
 		"client_id":     &b.config.ClientID,
 		"api_key":       &b.config.APIKey,
+		"api_token":     &b.config.APIToken,
+		"api_url":       &b.config.APIURL,
+		"region":        &b.config.Region,
+		"size":          &b.config.Size,
+		"image":         &b.config.Image,
+		"user_data":     &b.config.UserData,
 		"snapshot_name": &b.config.SnapshotName,
 		"droplet_name":  &b.config.DropletName,
 		"ssh_username":  &b.config.SSHUsername,
@@ -143,15 +207,37 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		}
 	}
 
-	// Required configurations that will display errors if not set
-	if b.config.ClientID == "" {
+	for i, region := range b.config.SnapshotRegions {
+		var err error
+		b.config.SnapshotRegions[i], err = b.config.tpl.Process(region, nil)
+		if err != nil {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("Error processing snapshot_regions[%d]: %s", i, err))
+		}
+	}
+
+	// Required configurations that will display errors if not set. An
+	// api_token selects the v2 API, otherwise the v1 API is used with the
+	// client_id and api_key.
+	if b.config.APIToken == "" {
+		if b.config.ClientID == "" {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("a client_id must be specified"))
+		}
+
+		if b.config.APIKey == "" {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("an api_key must be specified"))
+		}
+	} else if b.config.RegionID != 0 || b.config.SizeID != 0 || b.config.ImageID != 0 {
 		errs = packer.MultiErrorAppend(
-			errs, errors.New("a client_id must be specified"))
+			errs, errors.New("region_id, size_id and image_id are only supported "+
+				"with client_id and api_key, use region, size and image with api_token"))
 	}
 
-	if b.config.APIKey == "" {
+	if b.config.APIToken == "" && (b.config.IPv6 || b.config.UserData != "") {
 		errs = packer.MultiErrorAppend(
-			errs, errors.New("an api_key must be specified"))
+			errs, errors.New("ipv6 and user_data require an api_token"))
 	}
 
 	sshTimeout, err := time.ParseDuration(b.config.RawSSHTimeout)
@@ -172,13 +258,18 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		return nil, errs
 	}
 
-	common.ScrubConfig(b.config, b.config.ClientID, b.config.APIKey)
+	common.ScrubConfig(b.config, b.config.ClientID, b.config.APIKey, b.config.APIToken)
 	return nil, nil
 }
 
 func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
 	// Initialize the DO API client
-	client := DigitalOceanClient{}.New(b.config.ClientID, b.config.APIKey)
+	var client DigitalOceanClient
+	if b.config.APIToken != "" {
+		client = DigitalOceanClientV2{}.New(b.config.APIToken, b.config.APIURL)
+	} else {
+		client = DigitalOceanClientV1{}.New(b.config.ClientID, b.config.APIKey, b.config.APIURL)
+	}
 
 	// Set up the state
 	state := new(multistep.BasicStateBag)
@@ -189,6 +280,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 
 	// Build the steps
 	steps := []multistep.Step{
+		new(stepResolveRegions),
 		new(stepCreateSSHKey),
 		new(stepCreateDroplet),
 		new(stepDropletInfo),
@@ -201,6 +293,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		new(stepShutdown),
 		new(stepPowerOff),
 		new(stepSnapshot),
+		new(stepTransferSnapshot),
 	}
 
 	// Run the steps
@@ -225,18 +318,18 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		return nil, nil
 	}
 
-	region_id := state.Get("region_id").(uint)
-
-	regionName, err := client.RegionName(region_id)
-	if err != nil {
-		return nil, err
+	snapshotName := state.Get("snapshot_name").(string)
+	regions := state.Get("snapshot_regions").([]string)
+	snapshots := make(map[string]string)
+	for _, region := range regions {
+		snapshots[region] = snapshotName
 	}
 
 	artifact := &Artifact{
-		snapshotName: state.Get("snapshot_name").(string),
+		snapshotName: snapshotName,
 		snapshotId:   state.Get("snapshot_image_id").(uint),
-		regionId:     region_id,
-		regionName:   regionName,
+		region:       regions[0],
+		snapshots:    snapshots,
 		client:       client,
 	}
 
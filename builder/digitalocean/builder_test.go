@@ -3,6 +3,7 @@ package digitalocean
 import (
 	"github.com/mitchellh/packer/packer"
 	"os"
+	"reflect"
 	"strconv"
 	"testing"
 )
@@ -11,6 +12,8 @@ func init() {
 	// Clear out the credential env vars
 	os.Setenv("DIGITALOCEAN_API_KEY", "")
 	os.Setenv("DIGITALOCEAN_CLIENT_ID", "")
+	os.Setenv("DIGITALOCEAN_API_TOKEN", "")
+	os.Setenv("DIGITALOCEAN_API_URL", "")
 }
 
 func testConfig() map[string]interface{} {
@@ -127,6 +130,182 @@ func TestBuilderPrepare_ClientID(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_APIToken(t *testing.T) {
+	var b Builder
+	config := map[string]interface{}{
+		"api_token": "foo",
+	}
+
+	// Test good, without client_id or api_key
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.APIToken != "foo" {
+		t.Errorf("invalid: %s", b.config.APIToken)
+	}
+
+	// The v2 API uses slugs, so the IDs aren't defaulted
+	if b.config.Region != DefaultRegion || b.config.RegionID != 0 {
+		t.Errorf("invalid: %s %d", b.config.Region, b.config.RegionID)
+	}
+	if b.config.Size != DefaultSize || b.config.SizeID != 0 {
+		t.Errorf("invalid: %s %d", b.config.Size, b.config.SizeID)
+	}
+	if b.config.Image != DefaultImage || b.config.ImageID != 0 {
+		t.Errorf("invalid: %s %d", b.config.Image, b.config.ImageID)
+	}
+
+	// Test bad, with IDs
+	config["region_id"] = 2
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test env variable
+	delete(config, "api_token")
+	delete(config, "region_id")
+	os.Setenv("DIGITALOCEAN_API_TOKEN", "foo")
+	defer os.Setenv("DIGITALOCEAN_API_TOKEN", "")
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+}
+
+func TestBuilderPrepare_Region(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default, from the region ID with the v1 API
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.Region != "1" {
+		t.Errorf("invalid: %s", b.config.Region)
+	}
+
+	// Test set, which takes precedence over the default ID
+	config["region"] = "sfo1"
+	config["size"] = "1gb"
+	config["image"] = "ubuntu-14-04-x64"
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if b.config.Region != "sfo1" || b.config.RegionID != 0 {
+		t.Errorf("invalid: %s %d", b.config.Region, b.config.RegionID)
+	}
+	if b.config.Size != "1gb" || b.config.SizeID != 0 {
+		t.Errorf("invalid: %s %d", b.config.Size, b.config.SizeID)
+	}
+	if b.config.Image != "ubuntu-14-04-x64" || b.config.ImageID != 0 {
+		t.Errorf("invalid: %s %d", b.config.Image, b.config.ImageID)
+	}
+}
+
+func TestBuilderPrepare_V2Options(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test bad, with the v1 API
+	for _, k := range []string{"ipv6", "user_data"} {
+		config := testConfig()
+		if k == "ipv6" {
+			config[k] = true
+		} else {
+			config[k] = "#cloud-config"
+		}
+
+		b = Builder{}
+		warnings, err := b.Prepare(config)
+		if len(warnings) > 0 {
+			t.Fatalf("bad: %#v", warnings)
+		}
+		if err == nil {
+			t.Fatalf("%s: should have error", k)
+		}
+	}
+
+	// Test good, with the v2 API
+	delete(config, "client_id")
+	delete(config, "api_key")
+	config["api_token"] = "foo"
+	config["ipv6"] = true
+	config["private_networking"] = true
+	config["user_data"] = "#cloud-config"
+	b = Builder{}
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if !b.config.IPv6 || !b.config.PrivateNetworking || b.config.UserData != "#cloud-config" {
+		t.Errorf("invalid: %#v", b.config)
+	}
+}
+
+func TestBuilderPrepare_SnapshotRegions(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Test default
+	warnings, err := b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	if len(b.config.SnapshotRegions) != 0 {
+		t.Errorf("invalid: %#v", b.config.SnapshotRegions)
+	}
+
+	// Test set
+	config["snapshot_regions"] = []string{"nyc2", "{{user `region`}}"}
+	config["packer_user_variables"] = map[string]string{"region": "sfo1"}
+	b = Builder{}
+	warnings, err = b.Prepare(config)
+	if len(warnings) > 0 {
+		t.Fatalf("bad: %#v", warnings)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	expected := []string{"nyc2", "sfo1"}
+	if !reflect.DeepEqual(b.config.SnapshotRegions, expected) {
+		t.Errorf("invalid: %#v", b.config.SnapshotRegions)
+	}
+}
+
 func TestBuilderPrepare_InvalidKey(t *testing.T) {
 	var b Builder
 	config := testConfig()
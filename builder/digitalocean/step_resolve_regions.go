@@ -0,0 +1,59 @@
+package digitalocean
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+)
+
+// stepResolveRegions looks up the slugs of the build region and the
+// regions the snapshot is transferred to. Regions can be configured by
+// name or ID too. This runs before anything is created, so an unknown
+// region can't leave an orphaned snapshot behind.
+//
+// Produces:
+//   region_slug string - The slug of the build region
+//   transfer_regions []string - The slugs of the other regions to
+//     transfer the snapshot to, without duplicates
+type stepResolveRegions struct{}
+
+func (s *stepResolveRegions) Run(state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(DigitalOceanClient)
+	ui := state.Get("ui").(packer.Ui)
+	c := state.Get("config").(config)
+
+	region, err := client.Region(c.Region)
+	if err != nil {
+		err := fmt.Errorf("Error looking up region %s: %s", c.Region, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	seen := map[string]bool{region.Slug: true}
+	transfers := make([]string, 0, len(c.SnapshotRegions))
+	for _, r := range c.SnapshotRegions {
+		region, err := client.Region(r)
+		if err != nil {
+			err := fmt.Errorf("Error looking up snapshot region %s: %s", r, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		if seen[region.Slug] {
+			continue
+		}
+
+		seen[region.Slug] = true
+		transfers = append(transfers, region.Slug)
+	}
+
+	state.Put("region_slug", region.Slug)
+	state.Put("transfer_regions", transfers)
+	return multistep.ActionContinue
+}
+
+func (s *stepResolveRegions) Cleanup(state multistep.StateBag) {
+	// no cleanup
+}
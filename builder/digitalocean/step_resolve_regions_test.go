@@ -0,0 +1,86 @@
+package digitalocean
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"reflect"
+	"testing"
+)
+
+// testRegionClient is a DigitalOceanClient that only knows regions.
+type testRegionClient struct {
+	DigitalOceanClient
+}
+
+func (testRegionClient) Region(region string) (Region, error) {
+	regions := []Region{
+		{Id: 1, Name: "New York 1", Slug: "nyc1"},
+		{Id: 2, Name: "Amsterdam 1", Slug: "ams1"},
+		{Id: 3, Name: "San Francisco 1", Slug: "sfo1"},
+	}
+
+	for _, r := range regions {
+		if fmt.Sprintf("%v", r.Id) == region || r.Slug == region || r.Name == region {
+			return r, nil
+		}
+	}
+
+	return Region{}, fmt.Errorf("Unknown region: %s", region)
+}
+
+func testStepResolveRegionsState(c config) multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("config", c)
+	state.Put("client", testRegionClient{})
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	return state
+}
+
+func TestStepResolveRegions_impl(t *testing.T) {
+	var _ multistep.Step = new(stepResolveRegions)
+}
+
+func TestStepResolveRegions(t *testing.T) {
+	state := testStepResolveRegionsState(config{
+		Region:          "1",
+		SnapshotRegions: []string{"Amsterdam 1", "nyc1", "sfo1", "ams1"},
+	})
+
+	step := new(stepResolveRegions)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v %#v", action, state.Get("error"))
+	}
+
+	if state.Get("region_slug").(string) != "nyc1" {
+		t.Fatalf("bad: %#v", state.Get("region_slug"))
+	}
+
+	expected := []string{"ams1", "sfo1"}
+	if transfers := state.Get("transfer_regions").([]string); !reflect.DeepEqual(transfers, expected) {
+		t.Fatalf("bad: %#v", transfers)
+	}
+}
+
+func TestStepResolveRegions_unknown(t *testing.T) {
+	state := testStepResolveRegionsState(config{
+		Region:          "nyc1",
+		SnapshotRegions: []string{"ams1", "lon1"},
+	})
+
+	step := new(stepResolveRegions)
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+	if _, ok := state.GetOk("transfer_regions"); ok {
+		t.Fatal("should not have transfer regions")
+	}
+}
@@ -0,0 +1,37 @@
+package digitalocean
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+)
+
+// stepTransferSnapshot transfers the snapshot to the other regions it
+// should be available in, and adds them to the snapshot regions.
+type stepTransferSnapshot struct{}
+
+func (s *stepTransferSnapshot) Run(state multistep.StateBag) multistep.StepAction {
+	client := state.Get("client").(DigitalOceanClient)
+	ui := state.Get("ui").(packer.Ui)
+	imageId := state.Get("snapshot_image_id").(uint)
+	regions := state.Get("snapshot_regions").([]string)
+
+	for _, region := range state.Get("transfer_regions").([]string) {
+		ui.Say(fmt.Sprintf("Transferring snapshot to region: %s", region))
+		if err := client.TransferImage(imageId, region); err != nil {
+			err := fmt.Errorf("Error transferring snapshot to region %s: %s", region, err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		regions = append(regions, region)
+	}
+
+	state.Put("snapshot_regions", regions)
+	return multistep.ActionContinue
+}
+
+func (s *stepTransferSnapshot) Cleanup(state multistep.StateBag) {
+	// no cleanup
+}
@@ -11,6 +11,10 @@ type Driver interface {
 	// Export exports the container with the given ID to the given writer.
 	Export(id string, dst io.Writer) error
 
+	// Import imports the exported container at the given path as an
+	// image with the given repository name.
+	Import(path string, repo string) error
+
 	// Pull should pull down the given image.
 	Pull(image string) error
 
@@ -6,6 +6,7 @@ import (
 	"github.com/mitchellh/packer/packer"
 	"io"
 	"log"
+	"os"
 	"os/exec"
 	"strings"
 )
@@ -34,6 +35,28 @@ func (d *DockerDriver) Export(id string, dst io.Writer) error {
 	return nil
 }
 
+func (d *DockerDriver) Import(path string, repo string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("docker", "import", "-", repo)
+	cmd.Stdin = f
+	cmd.Stderr = &stderr
+
+	log.Printf("Importing container: %s => %s", path, repo)
+	if err := cmd.Run(); err != nil {
+		err = fmt.Errorf("Error importing: %s\nStderr: %s",
+			err, stderr.String())
+		return err
+	}
+
+	return nil
+}
+
 func (d *DockerDriver) Pull(image string) error {
 	cmd := exec.Command("docker", "pull", image)
 	return runAndStream(cmd, d.Ui)
@@ -8,6 +8,7 @@ import (
 type MockDriver struct {
 	ExportReader io.Reader
 	ExportError  error
+	ImportError  error
 	PullError    error
 	StartID      string
 	StartError   error
@@ -16,6 +17,9 @@ type MockDriver struct {
 
 	ExportCalled bool
 	ExportID     string
+	ImportCalled bool
+	ImportPath   string
+	ImportRepo   string
 	PullCalled   bool
 	PullImage    string
 	StartCalled  bool
@@ -39,6 +43,13 @@ func (d *MockDriver) Export(id string, dst io.Writer) error {
 	return d.ExportError
 }
 
+func (d *MockDriver) Import(path string, repo string) error {
+	d.ImportCalled = true
+	d.ImportPath = path
+	d.ImportRepo = repo
+	return d.ImportError
+}
+
 func (d *MockDriver) Pull(image string) error {
 	d.PullCalled = true
 	d.PullImage = image
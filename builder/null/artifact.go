@@ -0,0 +1,31 @@
+package null
+
+import (
+	"fmt"
+)
+
+// NullArtifact is the artifact of the null builder. Nothing is created
+// by the build, so there is nothing to destroy.
+type NullArtifact struct {
+	host string
+}
+
+func (*NullArtifact) BuilderId() string {
+	return BuilderId
+}
+
+func (*NullArtifact) Files() []string {
+	return nil
+}
+
+func (a *NullArtifact) Id() string {
+	return a.host
+}
+
+func (a *NullArtifact) String() string {
+	return fmt.Sprintf("Did not export anything. Provisioned: %s", a.host)
+}
+
+func (*NullArtifact) Destroy() error {
+	return nil
+}
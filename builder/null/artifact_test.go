@@ -0,0 +1,10 @@
+package null
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"testing"
+)
+
+func TestNullArtifact_impl(t *testing.T) {
+	var _ packer.Artifact = new(NullArtifact)
+}
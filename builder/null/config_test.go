@@ -0,0 +1,112 @@
+package null
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"host":         "foo",
+		"ssh_username": "bar",
+		"ssh_password": "baz",
+	}
+}
+
+func testConfigErr(t *testing.T, warns []string, err error) {
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err == nil {
+		t.Fatal("should error")
+	}
+}
+
+func testConfigOk(t *testing.T, warns []string, err error) {
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestConfigPrepare_port(t *testing.T) {
+	raw := testConfig()
+
+	// default port should be 22
+	delete(raw, "port")
+	c, warns, errs := NewConfig(raw)
+	testConfigOk(t, warns, errs)
+	if c.Port != 22 {
+		t.Fatalf("bad: port should default to 22, not %d", c.Port)
+	}
+}
+
+func TestConfigPrepare_host(t *testing.T) {
+	raw := testConfig()
+
+	// No host
+	delete(raw, "host")
+	_, warns, errs := NewConfig(raw)
+	testConfigErr(t, warns, errs)
+
+	// Good host
+	raw["host"] = "good"
+	_, warns, errs = NewConfig(raw)
+	testConfigOk(t, warns, errs)
+}
+
+func TestConfigPrepare_sshUsername(t *testing.T) {
+	raw := testConfig()
+
+	// No ssh_username
+	delete(raw, "ssh_username")
+	_, warns, errs := NewConfig(raw)
+	testConfigErr(t, warns, errs)
+
+	// Good ssh_username
+	raw["ssh_username"] = "good"
+	_, warns, errs = NewConfig(raw)
+	testConfigOk(t, warns, errs)
+}
+
+func TestConfigPrepare_sshCredential(t *testing.T) {
+	raw := testConfig()
+
+	// no ssh_password and no ssh_private_key_file
+	delete(raw, "ssh_password")
+	delete(raw, "ssh_private_key_file")
+	_, warns, errs := NewConfig(raw)
+	testConfigErr(t, warns, errs)
+
+	// only ssh_password
+	raw["ssh_password"] = "good"
+	_, warns, errs = NewConfig(raw)
+	testConfigOk(t, warns, errs)
+
+	// a ssh_private_key_file that doesn't exist
+	delete(raw, "ssh_password")
+	raw["ssh_private_key_file"] = "i-hope-i-dont-exist"
+	_, warns, errs = NewConfig(raw)
+	testConfigErr(t, warns, errs)
+
+	// a ssh_private_key_file that isn't a key
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+	tf.Write([]byte("not a key"))
+	tf.Close()
+
+	raw["ssh_private_key_file"] = tf.Name()
+	_, warns, errs = NewConfig(raw)
+	testConfigErr(t, warns, errs)
+
+	// both ssh_password and ssh_private_key_file set
+	raw["ssh_password"] = "bad"
+	_, warns, errs = NewConfig(raw)
+	testConfigErr(t, warns, errs)
+}
@@ -0,0 +1,55 @@
+package null
+
+import (
+	gossh "code.google.com/p/go.crypto/ssh"
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/communicator/ssh"
+	"io/ioutil"
+)
+
+func sshAddress(state multistep.StateBag) (string, error) {
+	config := state.Get("config").(*Config)
+	return fmt.Sprintf("%s:%d", config.Host, config.Port), nil
+}
+
+func sshConfig(state multistep.StateBag) (*gossh.ClientConfig, error) {
+	config := state.Get("config").(*Config)
+
+	var auth []gossh.ClientAuth
+	if config.SSHPrivateKeyFile != "" {
+		keyring, err := sshKeyToKeyring(config.SSHPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error setting up SSH config: %s", err)
+		}
+
+		auth = []gossh.ClientAuth{
+			gossh.ClientAuthKeyring(keyring),
+		}
+	} else {
+		auth = []gossh.ClientAuth{
+			gossh.ClientAuthPassword(ssh.Password(config.SSHPassword)),
+			gossh.ClientAuthKeyboardInteractive(
+				ssh.PasswordKeyboardInteractive(config.SSHPassword)),
+		}
+	}
+
+	return &gossh.ClientConfig{
+		User: config.SSHUsername,
+		Auth: auth,
+	}, nil
+}
+
+func sshKeyToKeyring(path string) (gossh.ClientKeyring, error) {
+	keyBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	keyring := new(ssh.SimpleKeychain)
+	if err := keyring.AddPEMKey(string(keyBytes)); err != nil {
+		return nil, err
+	}
+
+	return keyring, nil
+}
@@ -1,53 +1,86 @@
 package openstack
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"github.com/mitchellh/packer/packer"
 	"github.com/rackspace/gophercloud"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
 )
 
 // AccessConfig is for common configuration related to openstack access
 type AccessConfig struct {
-	Username  string `mapstructure:"username"`
-	Password  string `mapstructure:"password"`
-	Project   string `mapstructure:"project"`
-	Provider  string `mapstructure:"provider"`
-	RawRegion string `mapstructure:"region"`
+	Username   string `mapstructure:"username"`
+	Password   string `mapstructure:"password"`
+	ApiKey     string `mapstructure:"api_key"`
+	Project    string `mapstructure:"project"`
+	TenantId   string `mapstructure:"tenant_id"`
+	DomainName string `mapstructure:"domain_name"`
+	Provider   string `mapstructure:"provider"`
+	RawRegion  string `mapstructure:"region"`
+	Insecure   bool   `mapstructure:"insecure"`
+	CACertFile string `mapstructure:"cacert"`
+
+	// The HTTP client used for the API when TLS is customized with
+	// insecure or cacert. If nil, gophercloud's default client is used.
+	client *http.Client
 }
 
 // Auth returns a valid Auth object for access to openstack services, or
 // an error if the authentication couldn't be resolved.
 func (c *AccessConfig) Auth() (gophercloud.AccessProvider, error) {
-	username := c.Username
-	password := c.Password
-	project := c.Project
-	provider := c.Provider
+	if c.keystoneV3() {
+		access, err := authenticateV3(c)
+		if err != nil {
+			return nil, err
+		}
 
-	if username == "" {
-		username = os.Getenv("SDK_USERNAME")
+		return access, nil
 	}
-	if password == "" {
-		password = os.Getenv("SDK_PASSWORD")
+
+	authoptions := gophercloud.AuthOptions{
+		Username:    c.Username,
+		Password:    c.Password,
+		ApiKey:      c.ApiKey,
+		TenantId:    c.TenantId,
+		TenantName:  c.Project,
+		AllowReauth: true,
 	}
-	if project == "" {
-		project = os.Getenv("SDK_PROJECT")
+
+	if c.client != nil {
+		return gophercloud.TestContext().UseCustomClient(c.client).Authenticate(c.Provider, authoptions)
 	}
-	if provider == "" {
-		provider = os.Getenv("SDK_PROVIDER")
+
+	return gophercloud.Authenticate(c.Provider, authoptions)
+}
+
+// ServersApi returns the compute API of the configured region, using the
+// same HTTP client as Auth.
+func (c *AccessConfig) ServersApi(auth gophercloud.AccessProvider) (gophercloud.CloudServersProvider, error) {
+	api := gophercloud.ApiCriteria{
+		Name:      "cloudServersOpenStack",
+		Region:    c.Region(),
+		VersionId: "2",
+		UrlChoice: gophercloud.PublicURL,
 	}
 
-	authoptions := gophercloud.AuthOptions{
-		Username:    username,
-		Password:    password,
-		AllowReauth: true,
+	// The service names in a Keystone v3 catalog vary between
+	// installations, so the compute service is looked up by its type.
+	if c.keystoneV3() {
+		api.Name = ""
+		api.Type = "compute"
 	}
 
-	if project != "" {
-		authoptions.TenantName = project
+	if c.client != nil {
+		return gophercloud.TestContext().UseCustomClient(c.client).ServersApi(auth, api)
 	}
 
-	return gophercloud.Authenticate(provider, authoptions)
+	return gophercloud.ServersApi(auth, api)
 }
 
 func (c *AccessConfig) Region() string {
@@ -64,9 +97,15 @@ func (c *AccessConfig) Prepare(t *packer.ConfigTemplate) []error {
 	}
 
 	templates := map[string]*string{
-		"username": &c.Username,
-		"password": &c.Password,
-		"provider": &c.Provider,
+		"username":    &c.Username,
+		"password":    &c.Password,
+		"api_key":     &c.ApiKey,
+		"project":     &c.Project,
+		"tenant_id":   &c.TenantId,
+		"domain_name": &c.DomainName,
+		"provider":    &c.Provider,
+		"region":      &c.RawRegion,
+		"cacert":      &c.CACertFile,
 	}
 
 	errs := make([]error, 0)
@@ -79,13 +118,90 @@ func (c *AccessConfig) Prepare(t *packer.ConfigTemplate) []error {
 		}
 	}
 
+	// Anything that isn't configured is read from the standard OpenStack
+	// environment variables, falling back to the SDK_ variables of older
+	// versions of Packer.
+	envDefault(&c.Username, "OS_USERNAME", "SDK_USERNAME")
+	envDefault(&c.Password, "OS_PASSWORD", "SDK_PASSWORD")
+	envDefault(&c.ApiKey, "OS_API_KEY")
+	envDefault(&c.Project, "OS_TENANT_NAME", "OS_PROJECT_NAME", "SDK_PROJECT")
+	envDefault(&c.TenantId, "OS_TENANT_ID", "OS_PROJECT_ID")
+	envDefault(&c.DomainName, "OS_DOMAIN_NAME", "OS_USER_DOMAIN_NAME")
+	envDefault(&c.Provider, "OS_AUTH_URL", "SDK_PROVIDER")
+	envDefault(&c.RawRegion, "OS_REGION_NAME")
+	envDefault(&c.CACertFile, "OS_CACERT")
+
+	if !c.Insecure && os.Getenv("OS_INSECURE") != "" {
+		insecure, err := strconv.ParseBool(os.Getenv("OS_INSECURE"))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Error parsing OS_INSECURE: %s", err))
+		}
+		c.Insecure = insecure
+	}
+
 	if c.RawRegion == "" {
 		errs = append(errs, fmt.Errorf("region must be specified"))
 	}
 
+	if c.ApiKey != "" && c.keystoneV3() {
+		errs = append(errs, fmt.Errorf("api_key can't be used with Keystone v3"))
+	}
+
+	if c.Insecure || c.CACertFile != "" {
+		tlsConfig := &tls.Config{InsecureSkipVerify: c.Insecure}
+		if c.CACertFile != "" {
+			pool, err := loadCACert(c.CACertFile)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		c.client = &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		}
+	}
+
 	if len(errs) > 0 {
 		return errs
 	}
 
 	return nil
 }
+
+// keystoneV3 returns true if the identity service is Keystone v3, which
+// is the case if the provider is a v3 URL or a domain is given.
+func (c *AccessConfig) keystoneV3() bool {
+	return c.DomainName != "" || strings.HasSuffix(strings.TrimRight(c.Provider, "/"), "/v3")
+}
+
+// envDefault sets the value to the first non-empty environment variable
+// of the given keys, unless it is already set.
+func envDefault(value *string, keys ...string) {
+	for _, k := range keys {
+		if *value != "" {
+			return
+		}
+
+		*value = os.Getenv(k)
+	}
+}
+
+// loadCACert reads a PEM bundle of CA certificates to verify the
+// identity and compute APIs with.
+func loadCACert(path string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading cacert: %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("No certificates found in cacert: %s", path)
+	}
+
+	return pool, nil
+}
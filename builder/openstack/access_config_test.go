@@ -1,6 +1,13 @@
 package openstack
 
 import (
+	"encoding/json"
+	"encoding/pem"
+	"github.com/rackspace/gophercloud"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
 )
 
@@ -8,6 +15,33 @@ func testAccessConfig() *AccessConfig {
 	return &AccessConfig{}
 }
 
+// testKeystoneV3 returns a TLS server acting as the Keystone v3 tokens
+// API, which records the auth request it receives.
+func testKeystoneV3(t *testing.T, request *map[string]interface{}) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v3/auth/tokens" {
+			t.Errorf("bad request: %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			t.Errorf("err: %s", err)
+		}
+
+		w.Header().Set("X-Subject-Token", "token")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"token": {"catalog": [
+  {"type": "identity", "name": "keystone", "endpoints": [
+    {"interface": "public", "region": "RegionOne", "url": "https://keystone/v3"}]},
+  {"type": "compute", "name": "nova", "endpoints": [
+    {"interface": "internal", "region": "RegionOne", "url": "https://nova-internal/v2/1234"},
+    {"interface": "public", "region": "RegionTwo", "url": "https://nova-two/v2/1234"},
+    {"interface": "public", "region": "RegionOne", "url": "https://nova/v2/1234"}]}
+]}}`))
+	}))
+}
+
 func TestAccessConfigPrepare_NoRegion(t *testing.T) {
 	c := testAccessConfig()
 	if err := c.Prepare(nil); err == nil {
@@ -26,3 +60,188 @@ func TestAccessConfigPrepare_Region(t *testing.T) {
 		t.Fatalf("Regions do not match: %s %s", dfw, c.Region())
 	}
 }
+
+func TestAccessConfigPrepare_Env(t *testing.T) {
+	env := map[string]string{
+		"OS_USERNAME":     "user",
+		"OS_PASSWORD":     "secret",
+		"OS_PROJECT_NAME": "project",
+		"SDK_PROJECT":     "old",
+		"OS_AUTH_URL":     "https://keystone:5000/v2.0",
+		"OS_REGION_NAME":  "RegionOne",
+		"OS_INSECURE":     "true",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Setenv(k, "")
+	}
+
+	c := testAccessConfig()
+	c.Username = "config"
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if c.Username != "config" {
+		t.Fatalf("bad: %s", c.Username)
+	}
+	if c.Password != "secret" {
+		t.Fatalf("bad: %s", c.Password)
+	}
+	if c.Project != "project" {
+		t.Fatalf("bad: %s", c.Project)
+	}
+	if c.Provider != "https://keystone:5000/v2.0" {
+		t.Fatalf("bad: %s", c.Provider)
+	}
+	if c.Region() != "RegionOne" {
+		t.Fatalf("bad: %s", c.Region())
+	}
+	if !c.Insecure || c.client == nil {
+		t.Fatal("should be insecure")
+	}
+}
+
+func TestAccessConfigPrepare_ApiKeyV3(t *testing.T) {
+	c := testAccessConfig()
+	c.RawRegion = "RegionOne"
+	c.ApiKey = "key"
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.DomainName = "example"
+	if err := c.Prepare(nil); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestAccessConfigPrepare_CACert(t *testing.T) {
+	c := testAccessConfig()
+	c.RawRegion = "RegionOne"
+	c.CACertFile = "/i/dont/exist"
+	if err := c.Prepare(nil); err == nil {
+		t.Fatal("should have error")
+	}
+
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+	tf.Write([]byte("not a certificate"))
+	tf.Close()
+
+	c.CACertFile = tf.Name()
+	if err := c.Prepare(nil); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestAccessConfigAuth_V3(t *testing.T) {
+	var request map[string]interface{}
+	server := testKeystoneV3(t, &request)
+	defer server.Close()
+
+	c := testAccessConfig()
+	c.Username = "user"
+	c.Password = "secret"
+	c.Project = "project"
+	c.DomainName = "example"
+	c.Provider = server.URL
+	c.RawRegion = "RegionOne"
+	c.Insecure = true
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	auth, err := c.Auth()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if auth.AuthToken() != "token" {
+		t.Fatalf("bad: %s", auth.AuthToken())
+	}
+
+	expected := `{"auth":{"identity":{"methods":["password"],"password":{"user":{"domain":{"name":"example"},"name":"user","password":"secret"}}},"scope":{"project":{"domain":{"name":"example"},"name":"project"}}}}`
+	actual, _ := json.Marshal(request)
+	if string(actual) != expected {
+		t.Fatalf("bad: %s", actual)
+	}
+
+	url := auth.FirstEndpointUrlByCriteria(gophercloud.ApiCriteria{
+		Type:      "compute",
+		Region:    "RegionOne",
+		UrlChoice: gophercloud.PublicURL,
+	})
+	if url != "https://nova/v2/1234" {
+		t.Fatalf("bad: %s", url)
+	}
+}
+
+func TestAccessConfigAuth_V3DomainScope(t *testing.T) {
+	var request map[string]interface{}
+	server := testKeystoneV3(t, &request)
+	defer server.Close()
+
+	c := testAccessConfig()
+	c.Username = "user"
+	c.Password = "secret"
+	c.DomainName = "example"
+	c.Provider = server.URL + "/v3/"
+	c.RawRegion = "RegionOne"
+	c.Insecure = true
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := c.Auth(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	scope, _ := json.Marshal(request["auth"].(map[string]interface{})["scope"])
+	if string(scope) != `{"domain":{"name":"example"}}` {
+		t.Fatalf("bad: %s", scope)
+	}
+}
+
+func TestAccessConfigAuth_CACert(t *testing.T) {
+	var request map[string]interface{}
+	server := testKeystoneV3(t, &request)
+	defer server.Close()
+
+	c := testAccessConfig()
+	c.Username = "user"
+	c.Password = "secret"
+	c.Provider = server.URL + "/v3"
+	c.RawRegion = "RegionOne"
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The certificate of the test server isn't trusted by default
+	if _, err := c.Auth(); err == nil {
+		t.Fatal("should have error")
+	}
+
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+	pem.Encode(tf, &pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: server.TLS.Certificates[0].Certificate[0],
+	})
+	tf.Close()
+
+	c.CACertFile = tf.Name()
+	if err := c.Prepare(nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := c.Auth(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"github.com/mitchellh/multistep"
 	"github.com/mitchellh/packer/common"
 	"github.com/mitchellh/packer/packer"
-	"github.com/rackspace/gophercloud"
 	"log"
 )
 
@@ -51,7 +50,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		return nil, errs
 	}
 
-	log.Println(common.ScrubConfig(b.config, b.config.Password))
+	log.Println(common.ScrubConfig(b.config, b.config.Password, b.config.ApiKey))
 	return nil, nil
 }
 
@@ -60,13 +59,7 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	if err != nil {
 		return nil, err
 	}
-	api := &gophercloud.ApiCriteria{
-		Name:      "cloudServersOpenStack",
-		Region:    b.config.AccessConfig.Region(),
-		VersionId: "2",
-		UrlChoice: gophercloud.PublicURL,
-	}
-	csp, err := gophercloud.ServersApi(auth, *api)
+	csp, err := b.config.AccessConfig.ServersApi(auth)
 	if err != nil {
 		log.Printf("Region: %s", b.config.AccessConfig.Region())
 		return nil, err
@@ -83,9 +76,14 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	steps := []multistep.Step{
 		&StepKeyPair{},
 		&StepRunSourceServer{
-			Name:        b.config.ImageName,
-			Flavor:      b.config.Flavor,
-			SourceImage: b.config.SourceImage,
+			Name:           b.config.ImageName,
+			Flavor:         b.config.Flavor,
+			SourceImage:    b.config.SourceImage,
+			Networks:       b.config.Networks,
+			SecurityGroups: b.config.SecurityGroups,
+		},
+		&StepAllocateIp{
+			FloatingIpPool: b.config.FloatingIpPool,
 		},
 		&common.StepConnectSSH{
 			SSHAddress:     SSHAddress(csp, b.config.SSHPort),
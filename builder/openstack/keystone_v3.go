@@ -0,0 +1,190 @@
+package openstack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/rackspace/gophercloud"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// v3Access is a gophercloud.AccessProvider for a token of the Keystone v3
+// identity API, which gophercloud itself only knows the v2 API of.
+type v3Access struct {
+	config  *AccessConfig
+	token   string
+	catalog []v3Service
+}
+
+type v3Service struct {
+	Type      string       `json:"type"`
+	Name      string       `json:"name"`
+	Endpoints []v3Endpoint `json:"endpoints"`
+}
+
+type v3Endpoint struct {
+	Interface string `json:"interface"`
+	Region    string `json:"region"`
+	RegionId  string `json:"region_id"`
+	URL       string `json:"url"`
+}
+
+// authenticateV3 requests a token with the username and password of the
+// config, scoped to its project, or to its domain if there is no project.
+func authenticateV3(c *AccessConfig) (*v3Access, error) {
+	access := &v3Access{config: c}
+	if err := access.Reauthenticate(); err != nil {
+		return nil, err
+	}
+
+	return access, nil
+}
+
+func (a *v3Access) FirstEndpointUrlByCriteria(criteria gophercloud.ApiCriteria) string {
+	iface := "public"
+	if criteria.UrlChoice == gophercloud.InternalURL {
+		iface = "internal"
+	}
+
+	for _, s := range a.catalog {
+		if criteria.Type != "" && criteria.Type != s.Type {
+			continue
+		}
+		if criteria.Name != "" && criteria.Name != s.Name {
+			continue
+		}
+
+		for _, e := range s.Endpoints {
+			if e.Interface != iface {
+				continue
+			}
+			if criteria.Region != "" && criteria.Region != e.Region && criteria.Region != e.RegionId {
+				continue
+			}
+
+			return e.URL
+		}
+	}
+
+	return ""
+}
+
+func (a *v3Access) AuthToken() string {
+	return a.token
+}
+
+func (a *v3Access) Revoke(token string) error {
+	req, err := http.NewRequest("DELETE", a.tokensURL(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", a.token)
+	req.Header.Set("X-Subject-Token", token)
+
+	resp, err := a.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Error revoking token (HTTP %d)", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (a *v3Access) Reauthenticate() error {
+	c := a.config
+
+	var domain map[string]string
+	if c.DomainName != "" {
+		domain = map[string]string{"name": c.DomainName}
+	} else {
+		domain = map[string]string{"id": "default"}
+	}
+
+	auth := map[string]interface{}{
+		"identity": map[string]interface{}{
+			"methods": []string{"password"},
+			"password": map[string]interface{}{
+				"user": map[string]interface{}{
+					"name":     c.Username,
+					"password": c.Password,
+					"domain":   domain,
+				},
+			},
+		},
+	}
+
+	switch {
+	case c.TenantId != "":
+		auth["scope"] = map[string]interface{}{
+			"project": map[string]interface{}{"id": c.TenantId},
+		}
+	case c.Project != "":
+		auth["scope"] = map[string]interface{}{
+			"project": map[string]interface{}{"name": c.Project, "domain": domain},
+		}
+	case c.DomainName != "":
+		auth["scope"] = map[string]interface{}{"domain": domain}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"auth": auth})
+	if err != nil {
+		return err
+	}
+
+	url := a.tokensURL()
+	log.Printf("Authenticating with Keystone v3: %s", url)
+	resp, err := a.httpClient().Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("Error authenticating with Keystone v3 (HTTP %d): %s",
+			resp.StatusCode, respBody)
+	}
+
+	var result struct {
+		Token struct {
+			Catalog []v3Service `json:"catalog"`
+		} `json:"token"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("Error decoding Keystone v3 token: %s", err)
+	}
+
+	a.token = resp.Header.Get("X-Subject-Token")
+	a.catalog = result.Token.Catalog
+	return nil
+}
+
+// tokensURL returns the URL of the tokens resource of the identity API.
+// The provider may be given with or without the trailing v3.
+func (a *v3Access) tokensURL() string {
+	url := strings.TrimRight(a.config.Provider, "/")
+	if !strings.HasSuffix(url, "/v3") {
+		url += "/v3"
+	}
+
+	return url + "/auth/tokens"
+}
+
+func (a *v3Access) httpClient() *http.Client {
+	if a.config.client != nil {
+		return a.config.client
+	}
+
+	return http.DefaultClient
+}
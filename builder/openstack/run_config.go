@@ -16,6 +16,10 @@ type RunConfig struct {
 	SSHUsername   string `mapstructure:"ssh_username"`
 	SSHPort       int    `mapstructure:"ssh_port"`
 
+	Networks       []string `mapstructure:"networks"`
+	SecurityGroups []string `mapstructure:"security_groups"`
+	FloatingIpPool string   `mapstructure:"floating_ip_pool"`
+
 	// Unexported fields that are calculated from others
 	sshTimeout time.Duration
 }
@@ -58,10 +62,11 @@ func (c *RunConfig) Prepare(t *packer.ConfigTemplate) []error {
 	}
 
 	templates := map[string]*string{
-		"flavlor":      &c.Flavor,
-		"ssh_timeout":  &c.RawSSHTimeout,
-		"ssh_username": &c.SSHUsername,
-		"source_image": &c.SourceImage,
+		"flavlor":          &c.Flavor,
+		"ssh_timeout":      &c.RawSSHTimeout,
+		"ssh_username":     &c.SSHUsername,
+		"source_image":     &c.SourceImage,
+		"floating_ip_pool": &c.FloatingIpPool,
 	}
 
 	for n, ptr := range templates {
@@ -73,6 +78,22 @@ func (c *RunConfig) Prepare(t *packer.ConfigTemplate) []error {
 		}
 	}
 
+	sliceTemplates := map[string][]string{
+		"networks":        c.Networks,
+		"security_groups": c.SecurityGroups,
+	}
+
+	for n, slice := range sliceTemplates {
+		for i, elem := range slice {
+			var err error
+			slice[i], err = t.Process(elem, nil)
+			if err != nil {
+				errs = append(
+					errs, fmt.Errorf("Error processing %s[%d]: %s", n, i, err))
+			}
+		}
+	}
+
 	c.sshTimeout, err = time.ParseDuration(c.RawSSHTimeout)
 	if err != nil {
 		errs = append(errs, fmt.Errorf("Failed parsing ssh_timeout: %s", err))
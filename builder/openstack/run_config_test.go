@@ -1,16 +1,24 @@
 package openstack
 
 import (
+	"github.com/mitchellh/packer/packer"
 	"os"
+	"reflect"
 	"testing"
 )
 
 func init() {
 	// Clear out the openstack env vars so they don't
 	// affect our tests.
-	os.Setenv("SDK_USERNAME", "")
-	os.Setenv("SDK_PASSWORD", "")
-	os.Setenv("SDK_PROVIDER", "")
+	for _, k := range []string{
+		"SDK_USERNAME", "SDK_PASSWORD", "SDK_PROJECT", "SDK_PROVIDER",
+		"OS_USERNAME", "OS_PASSWORD", "OS_API_KEY", "OS_TENANT_NAME",
+		"OS_PROJECT_NAME", "OS_TENANT_ID", "OS_PROJECT_ID", "OS_DOMAIN_NAME",
+		"OS_USER_DOMAIN_NAME", "OS_AUTH_URL", "OS_REGION_NAME", "OS_CACERT",
+		"OS_INSECURE",
+	} {
+		os.Setenv(k, "")
+	}
 }
 
 func testRunConfig() *RunConfig {
@@ -86,3 +94,34 @@ func TestRunConfigPrepare_SSHUsername(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 }
+
+func TestRunConfigPrepare_Networks(t *testing.T) {
+	c := testRunConfig()
+	c.Networks = []string{"{{user `network`}}", "net-2"}
+	c.SecurityGroups = []string{"{{user `group`}}"}
+	c.FloatingIpPool = "{{user `pool`}}"
+
+	tpl, err := packer.NewConfigTemplate()
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tpl.UserVars = map[string]string{
+		"network": "net-1",
+		"group":   "ssh",
+		"pool":    "public",
+	}
+
+	if err := c.Prepare(tpl); len(err) != 0 {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !reflect.DeepEqual(c.Networks, []string{"net-1", "net-2"}) {
+		t.Fatalf("bad: %#v", c.Networks)
+	}
+	if !reflect.DeepEqual(c.SecurityGroups, []string{"ssh"}) {
+		t.Fatalf("bad: %#v", c.SecurityGroups)
+	}
+	if c.FloatingIpPool != "public" {
+		t.Fatalf("bad: %s", c.FloatingIpPool)
+	}
+}
@@ -7,13 +7,20 @@ import (
 	"github.com/mitchellh/multistep"
 	"github.com/mitchellh/packer/communicator/ssh"
 	"github.com/rackspace/gophercloud"
+	"sort"
 	"time"
 )
 
 // SSHAddress returns a function that can be given to the SSH communicator
-// for determining the SSH address based on the server AccessIPv4 setting..
+// for determining the SSH address of the server. This is the floating IP
+// allocated for it if there is one, and otherwise the AccessIPv4 or
+// AccessIPv6 setting of the server, or else its first IPv4 address.
 func SSHAddress(csp gophercloud.CloudServersProvider, port int) func(multistep.StateBag) (string, error) {
 	return func(state multistep.StateBag) (string, error) {
+		if ip, ok := state.GetOk("access_ip"); ok {
+			return fmt.Sprintf("%s:%d", ip.(gophercloud.FloatingIp).Ip, port), nil
+		}
+
 		for j := 0; j < 2; j++ {
 			s := state.Get("server").(*gophercloud.Server)
 			if s.AccessIPv4 != "" {
@@ -22,6 +29,28 @@ func SSHAddress(csp gophercloud.CloudServersProvider, port int) func(multistep.S
 			if s.AccessIPv6 != "" {
 				return fmt.Sprintf("[%s]:%d", s.AccessIPv6, port), nil
 			}
+
+			pools, err := s.AllAddressPools()
+			if err != nil {
+				return "", err
+			}
+
+			// Go through the pools in order, so the same address is
+			// picked every time.
+			names := make([]string, 0, len(pools))
+			for name := range pools {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			for _, name := range names {
+				for _, address := range pools[name] {
+					if address.Addr != "" && address.Version == 4 {
+						return fmt.Sprintf("%s:%d", address.Addr, port), nil
+					}
+				}
+			}
+
 			serverState, err := csp.ServerById(s.Id)
 
 			if err != nil {
@@ -0,0 +1,76 @@
+package openstack
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"github.com/rackspace/gophercloud"
+)
+
+// StepAllocateIp allocates a floating IP address from FloatingIpPool and
+// associates it with the server, so that it can be reached over SSH. The
+// address is released again on cleanup. It does nothing if no pool is
+// set.
+//
+// Uses:
+//   csp    gophercloud.CloudServersProvider
+//   server *gophercloud.Server
+//   ui     packer.Ui
+//
+// Produces:
+//   access_ip gophercloud.FloatingIp - The floating IP address, if one
+//     was allocated.
+type StepAllocateIp struct {
+	FloatingIpPool string
+
+	floatingIp *gophercloud.FloatingIp
+}
+
+func (s *StepAllocateIp) Run(state multistep.StateBag) multistep.StepAction {
+	if s.FloatingIpPool == "" {
+		return multistep.ActionContinue
+	}
+
+	csp := state.Get("csp").(gophercloud.CloudServersProvider)
+	server := state.Get("server").(*gophercloud.Server)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say(fmt.Sprintf("Allocating floating IP from pool: %s", s.FloatingIpPool))
+	floatingIp, err := csp.CreateFloatingIp(s.FloatingIpPool)
+	if err != nil {
+		err := fmt.Errorf("Error allocating floating IP from pool %s: %s", s.FloatingIpPool, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Set the address so that it is released on cleanup
+	s.floatingIp = &floatingIp
+
+	ui.Say(fmt.Sprintf("Associating floating IP %s with the server...", floatingIp.Ip))
+	if err := csp.AssociateFloatingIp(server.Id, floatingIp); err != nil {
+		err := fmt.Errorf("Error associating floating IP %s with the server: %s", floatingIp.Ip, err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("access_ip", floatingIp)
+
+	return multistep.ActionContinue
+}
+
+func (s *StepAllocateIp) Cleanup(state multistep.StateBag) {
+	if s.floatingIp == nil {
+		return
+	}
+
+	csp := state.Get("csp").(gophercloud.CloudServersProvider)
+	ui := state.Get("ui").(packer.Ui)
+
+	ui.Say(fmt.Sprintf("Releasing floating IP %s...", s.floatingIp.Ip))
+	if err := csp.DeleteFloatingIp(*s.floatingIp); err != nil {
+		ui.Error(fmt.Sprintf(
+			"Error releasing floating IP %s, may still be around: %s", s.floatingIp.Ip, err))
+	}
+}
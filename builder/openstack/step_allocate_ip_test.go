@@ -0,0 +1,105 @@
+package openstack
+
+import (
+	"github.com/mitchellh/multistep"
+	"github.com/rackspace/gophercloud"
+	"strings"
+	"testing"
+)
+
+func TestStepAllocateIp_impl(t *testing.T) {
+	var _ multistep.Step = new(StepAllocateIp)
+}
+
+func TestStepAllocateIp(t *testing.T) {
+	csp, fake, closeFn := testNova(t, map[string]string{
+		"POST /os-floating-ips":      `{"floating_ip": {"id": 1, "ip": "203.0.113.10", "pool": "public"}}`,
+		"POST /servers/srv-1/action": "",
+		"DELETE /os-floating-ips/1":  "",
+	})
+	defer closeFn()
+
+	state := testState(t, csp)
+	state.Put("server", &gophercloud.Server{Id: "srv-1"})
+
+	step := &StepAllocateIp{FloatingIpPool: "public"}
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", state.Get("error"))
+	}
+
+	ip := state.Get("access_ip").(gophercloud.FloatingIp)
+	if ip.Ip != "203.0.113.10" {
+		t.Fatalf("bad: %#v", ip)
+	}
+
+	if pool := fake.requests[0].Body["pool"]; pool != "public" {
+		t.Fatalf("bad: %#v", fake.requests[0].Body)
+	}
+
+	associate := fake.requests[1].Body["addFloatingIp"].(map[string]interface{})
+	if associate["address"] != "203.0.113.10" {
+		t.Fatalf("bad: %#v", fake.requests[1].Body)
+	}
+
+	// The SSH address is the floating IP
+	addr, err := SSHAddress(csp, 22)(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if addr != "203.0.113.10:22" {
+		t.Fatalf("bad: %s", addr)
+	}
+
+	// The address is released on cleanup
+	step.Cleanup(state)
+
+	expected := "POST /os-floating-ips,POST /servers/srv-1/action,DELETE /os-floating-ips/1"
+	if routes := strings.Join(fake.routes(), ","); routes != expected {
+		t.Fatalf("bad: %s", routes)
+	}
+}
+
+func TestStepAllocateIp_noPool(t *testing.T) {
+	csp, fake, closeFn := testNova(t, map[string]string{})
+	defer closeFn()
+
+	state := testState(t, csp)
+	state.Put("server", &gophercloud.Server{Id: "srv-1"})
+
+	step := new(StepAllocateIp)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", state.Get("error"))
+	}
+	step.Cleanup(state)
+
+	if _, ok := state.GetOk("access_ip"); ok {
+		t.Fatal("should not have an access IP")
+	}
+	if len(fake.requests) != 0 {
+		t.Fatalf("bad: %#v", fake.requests)
+	}
+}
+
+func TestStepAllocateIp_associateFails(t *testing.T) {
+	csp, fake, closeFn := testNova(t, map[string]string{
+		"POST /os-floating-ips":     `{"floating_ip": {"id": 1, "ip": "203.0.113.10", "pool": "public"}}`,
+		"DELETE /os-floating-ips/1": "",
+	})
+	defer closeFn()
+
+	state := testState(t, csp)
+	state.Put("server", &gophercloud.Server{Id: "srv-1"})
+
+	step := &StepAllocateIp{FloatingIpPool: "public"}
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatal("should halt")
+	}
+
+	// The address was allocated, so it is still released
+	step.Cleanup(state)
+
+	routes := fake.routes()
+	if routes[len(routes)-1] != "DELETE /os-floating-ips/1" {
+		t.Fatalf("bad: %#v", routes)
+	}
+}
@@ -9,9 +9,11 @@ import (
 )
 
 type StepRunSourceServer struct {
-	Flavor      string
-	Name        string
-	SourceImage string
+	Flavor         string
+	Name           string
+	SourceImage    string
+	Networks       []string
+	SecurityGroups []string
 
 	server *gophercloud.Server
 }
@@ -23,11 +25,23 @@ func (s *StepRunSourceServer) Run(state multistep.StateBag) multistep.StepAction
 
 	// XXX - validate image and flavor is available
 
+	networks := make([]gophercloud.NetworkConfig, len(s.Networks))
+	for i, networkUuid := range s.Networks {
+		networks[i].Uuid = networkUuid
+	}
+
+	securityGroups := make([]map[string]interface{}, len(s.SecurityGroups))
+	for i, groupName := range s.SecurityGroups {
+		securityGroups[i] = map[string]interface{}{"name": groupName}
+	}
+
 	server := gophercloud.NewServer{
-		Name:        s.Name,
-		ImageRef:    s.SourceImage,
-		FlavorRef:   s.Flavor,
-		KeyPairName: keyName,
+		Name:          s.Name,
+		ImageRef:      s.SourceImage,
+		FlavorRef:     s.Flavor,
+		KeyPairName:   keyName,
+		Networks:      networks,
+		SecurityGroup: securityGroups,
 	}
 
 	serverResp, err := csp.CreateServer(server)
@@ -0,0 +1,190 @@
+package openstack
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"github.com/rackspace/gophercloud"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakeAccess is an AccessProvider that points at a fake Nova endpoint.
+type fakeAccess struct {
+	endpoint string
+}
+
+func (a *fakeAccess) FirstEndpointUrlByCriteria(gophercloud.ApiCriteria) string {
+	return a.endpoint
+}
+
+func (*fakeAccess) AuthToken() string     { return "token" }
+func (*fakeAccess) Revoke(string) error   { return nil }
+func (*fakeAccess) Reauthenticate() error { return nil }
+
+// fakeNova is a local stand-in for the Nova API that serves canned JSON
+// responses by method and path, and records the requests made to it.
+type fakeNova struct {
+	sync.Mutex
+	responses map[string]string
+	requests  []fakeNovaRequest
+}
+
+type fakeNovaRequest struct {
+	Route string
+	Body  map[string]interface{}
+}
+
+func (f *fakeNova) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	route := r.Method + " " + r.URL.Path
+	req := fakeNovaRequest{Route: route}
+	if body, _ := ioutil.ReadAll(r.Body); len(body) > 0 {
+		json.Unmarshal(body, &req.Body)
+	}
+	f.requests = append(f.requests, req)
+
+	resp, ok := f.responses[route]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if resp == "" {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(resp))
+}
+
+func (f *fakeNova) routes() []string {
+	f.Lock()
+	defer f.Unlock()
+
+	result := make([]string, len(f.requests))
+	for i, r := range f.requests {
+		result[i] = r.Route
+	}
+
+	return result
+}
+
+func testNova(t *testing.T, responses map[string]string) (gophercloud.CloudServersProvider, *fakeNova, func()) {
+	fake := &fakeNova{responses: responses}
+	server := httptest.NewServer(fake)
+
+	csp, err := gophercloud.ServersApi(&fakeAccess{endpoint: server.URL}, gophercloud.ApiCriteria{})
+	if err != nil {
+		server.Close()
+		t.Fatalf("err: %s", err)
+	}
+
+	return csp, fake, server.Close
+}
+
+func testState(t *testing.T, csp gophercloud.CloudServersProvider) multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("csp", csp)
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	return state
+}
+
+func TestStepRunSourceServer_impl(t *testing.T) {
+	var _ multistep.Step = new(StepRunSourceServer)
+}
+
+func TestStepRunSourceServer(t *testing.T) {
+	csp, fake, closeFn := testNova(t, map[string]string{
+		"POST /servers": `{"server": {"id": "srv-1"}}`,
+		"GET /servers/srv-1": `{"server": {"id": "srv-1", "status": "ACTIVE",
+			"addresses": {"private": [{"addr": "10.0.0.5", "version": 4}]}}}`,
+	})
+	defer closeFn()
+
+	state := testState(t, csp)
+	state.Put("keyPair", "packer-key")
+
+	step := &StepRunSourceServer{
+		Name:           "packer-test",
+		Flavor:         "2",
+		SourceImage:    "abcd",
+		Networks:       []string{"net-1", "net-2"},
+		SecurityGroups: []string{"default", "ssh"},
+	}
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", state.Get("error"))
+	}
+
+	if server := state.Get("server").(*gophercloud.Server); server.Id != "srv-1" {
+		t.Fatalf("bad: %#v", server)
+	}
+
+	// Without a floating IP, the SSH address is the first IPv4 address
+	addr, err := SSHAddress(csp, 22)(state)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if addr != "10.0.0.5:22" {
+		t.Fatalf("bad: %s", addr)
+	}
+
+	body := fake.requests[0].Body["server"].(map[string]interface{})
+	if body["name"] != "packer-test" || body["flavorRef"] != "2" ||
+		body["imageRef"] != "abcd" || body["key_name"] != "packer-key" {
+		t.Fatalf("bad: %#v", body)
+	}
+
+	networks := []interface{}{
+		map[string]interface{}{"uuid": "net-1"},
+		map[string]interface{}{"uuid": "net-2"},
+	}
+	if !reflect.DeepEqual(body["networks"], networks) {
+		t.Fatalf("bad: %#v", body["networks"])
+	}
+
+	groups := []interface{}{
+		map[string]interface{}{"name": "default"},
+		map[string]interface{}{"name": "ssh"},
+	}
+	if !reflect.DeepEqual(body["security_groups"], groups) {
+		t.Fatalf("bad: %#v", body["security_groups"])
+	}
+}
+
+func TestStepRunSourceServer_noNetworks(t *testing.T) {
+	csp, fake, closeFn := testNova(t, map[string]string{
+		"POST /servers":      `{"server": {"id": "srv-1"}}`,
+		"GET /servers/srv-1": `{"server": {"id": "srv-1", "status": "ACTIVE"}}`,
+	})
+	defer closeFn()
+
+	state := testState(t, csp)
+	state.Put("keyPair", "packer-key")
+
+	step := &StepRunSourceServer{Name: "packer-test", Flavor: "2", SourceImage: "abcd"}
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", state.Get("error"))
+	}
+
+	// Without networks or security groups, the defaults of the cloud
+	// are used.
+	body := fake.requests[0].Body["server"].(map[string]interface{})
+	if _, ok := body["networks"]; ok {
+		t.Fatalf("bad: %#v", body)
+	}
+	if _, ok := body["security_groups"]; ok {
+		t.Fatalf("bad: %#v", body)
+	}
+}
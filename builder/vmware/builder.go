@@ -30,6 +30,7 @@ type config struct {
 	DiskSize          uint              `mapstructure:"disk_size"`
 	DiskTypeId        string            `mapstructure:"disk_type_id"`
 	FloppyFiles       []string          `mapstructure:"floppy_files"`
+	Format            string            `mapstructure:"format"`
 	GuestOSType       string            `mapstructure:"guest_os_type"`
 	ISOChecksum       string            `mapstructure:"iso_checksum"`
 	ISOChecksumType   string            `mapstructure:"iso_checksum_type"`
@@ -42,6 +43,7 @@ type config struct {
 	HTTPPortMax       uint              `mapstructure:"http_port_max"`
 	BootCommand       []string          `mapstructure:"boot_command"`
 	SkipCompaction    bool              `mapstructure:"skip_compaction"`
+	SourcePath        string            `mapstructure:"source_path"`
 	ShutdownCommand   string            `mapstructure:"shutdown_command"`
 	SSHUser           string            `mapstructure:"ssh_username"`
 	SSHKeyPath        string            `mapstructure:"ssh_key_path"`
@@ -165,6 +167,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 	// Errors
 	templates := map[string]*string{
 		"disk_name":           &b.config.DiskName,
+		"format":              &b.config.Format,
 		"guest_os_type":       &b.config.GuestOSType,
 		"http_directory":      &b.config.HTTPDir,
 		"iso_checksum":        &b.config.ISOChecksum,
@@ -172,6 +175,7 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 		"iso_url":             &b.config.RawSingleISOUrl,
 		"output_directory":    &b.config.OutputDir,
 		"shutdown_command":    &b.config.ShutdownCommand,
+		"source_path":         &b.config.SourcePath,
 		"ssh_key_path":        &b.config.SSHKeyPath,
 		"ssh_password":        &b.config.SSHPassword,
 		"ssh_username":        &b.config.SSHUser,
@@ -249,40 +253,54 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 			errs, errors.New("http_port_min must be less than http_port_max"))
 	}
 
-	if b.config.ISOChecksum == "" {
-		errs = packer.MultiErrorAppend(
-			errs, errors.New("Due to large file sizes, an iso_checksum is required"))
-	} else {
-		b.config.ISOChecksum = strings.ToLower(b.config.ISOChecksum)
-	}
+	if b.config.SourcePath != "" {
+		if b.config.RawSingleISOUrl != "" || len(b.config.ISOUrls) > 0 {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("Only one of source_path or iso_url may be specified."))
+		}
 
-	if b.config.ISOChecksumType == "" {
-		errs = packer.MultiErrorAppend(
-			errs, errors.New("The iso_checksum_type must be specified."))
+		if b.config.RemoteType == "" {
+			if _, err := os.Stat(b.config.SourcePath); err != nil {
+				errs = packer.MultiErrorAppend(
+					errs, fmt.Errorf("source_path is invalid: %s", err))
+			}
+		}
 	} else {
-		b.config.ISOChecksumType = strings.ToLower(b.config.ISOChecksumType)
-		if h := common.HashForType(b.config.ISOChecksumType); h == nil {
+		if b.config.ISOChecksum == "" {
 			errs = packer.MultiErrorAppend(
-				errs,
-				fmt.Errorf("Unsupported checksum type: %s", b.config.ISOChecksumType))
+				errs, errors.New("Due to large file sizes, an iso_checksum is required"))
+		} else {
+			b.config.ISOChecksum = strings.ToLower(b.config.ISOChecksum)
 		}
-	}
 
-	if b.config.RawSingleISOUrl == "" && len(b.config.ISOUrls) == 0 {
-		errs = packer.MultiErrorAppend(
-			errs, errors.New("One of iso_url or iso_urls must be specified."))
-	} else if b.config.RawSingleISOUrl != "" && len(b.config.ISOUrls) > 0 {
-		errs = packer.MultiErrorAppend(
-			errs, errors.New("Only one of iso_url or iso_urls may be specified."))
-	} else if b.config.RawSingleISOUrl != "" {
-		b.config.ISOUrls = []string{b.config.RawSingleISOUrl}
-	}
+		if b.config.ISOChecksumType == "" {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("The iso_checksum_type must be specified."))
+		} else {
+			b.config.ISOChecksumType = strings.ToLower(b.config.ISOChecksumType)
+			if h := common.HashForType(b.config.ISOChecksumType); h == nil {
+				errs = packer.MultiErrorAppend(
+					errs,
+					fmt.Errorf("Unsupported checksum type: %s", b.config.ISOChecksumType))
+			}
+		}
 
-	for i, url := range b.config.ISOUrls {
-		b.config.ISOUrls[i], err = common.DownloadableURL(url)
-		if err != nil {
+		if b.config.RawSingleISOUrl == "" && len(b.config.ISOUrls) == 0 {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("One of iso_url or iso_urls must be specified."))
+		} else if b.config.RawSingleISOUrl != "" && len(b.config.ISOUrls) > 0 {
 			errs = packer.MultiErrorAppend(
-				errs, fmt.Errorf("Failed to parse iso_url %d: %s", i+1, err))
+				errs, errors.New("Only one of iso_url or iso_urls may be specified."))
+		} else if b.config.RawSingleISOUrl != "" {
+			b.config.ISOUrls = []string{b.config.RawSingleISOUrl}
+		}
+
+		for i, url := range b.config.ISOUrls {
+			b.config.ISOUrls[i], err = common.DownloadableURL(url)
+			if err != nil {
+				errs = packer.MultiErrorAppend(
+					errs, fmt.Errorf("Failed to parse iso_url %d: %s", i+1, err))
+			}
 		}
 	}
 
@@ -350,6 +368,13 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, error) {
 
 	}
 
+	switch b.config.Format {
+	case "", "ova", "ovf":
+	default:
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("format must be one of 'ova' or 'ovf'"))
+	}
+
 	if b.config.VNCPortMin > b.config.VNCPortMax {
 		errs = packer.MultiErrorAppend(
 			errs, fmt.Errorf("vnc_port_min must be less than vnc_port_max"))
@@ -386,25 +411,42 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 	// Seed the random number generator
 	rand.Seed(time.Now().UTC().UnixNano())
 
-	steps := []multistep.Step{
-		&stepPrepareTools{},
-		&common.StepDownload{
-			Checksum:     b.config.ISOChecksum,
-			ChecksumType: b.config.ISOChecksumType,
-			Description:  "ISO",
-			ResultKey:    "iso_path",
-			Url:          b.config.ISOUrls,
-		},
-		&stepPrepareOutputDir{},
-		&common.StepCreateFloppy{
-			Files: b.config.FloppyFiles,
-		},
-		&stepRemoteUpload{
-			Key:     "iso_path",
-			Message: "Uploading ISO to remote machine...",
-		},
-		&stepCreateDisk{},
-		&stepCreateVMX{},
+	// Build the steps that create the VM. If a source VMX is given,
+	// the VM is cloned from it instead of installed from an ISO.
+	var steps []multistep.Step
+	if b.config.SourcePath != "" {
+		steps = []multistep.Step{
+			&stepPrepareTools{},
+			&stepPrepareOutputDir{},
+			&common.StepCreateFloppy{
+				Files: b.config.FloppyFiles,
+			},
+			&stepCloneVMX{},
+		}
+	} else {
+		steps = []multistep.Step{
+			&stepPrepareTools{},
+			&common.StepDownload{
+				Checksum:     b.config.ISOChecksum,
+				ChecksumType: b.config.ISOChecksumType,
+				Description:  "ISO",
+				ResultKey:    "iso_path",
+				Url:          b.config.ISOUrls,
+			},
+			&stepPrepareOutputDir{},
+			&common.StepCreateFloppy{
+				Files: b.config.FloppyFiles,
+			},
+			&stepRemoteUpload{
+				Key:     "iso_path",
+				Message: "Uploading ISO to remote machine...",
+			},
+			&stepCreateDisk{},
+			&stepCreateVMX{},
+		}
+	}
+
+	steps = append(steps,
 		&stepSuppressMessages{},
 		&stepHTTPServer{},
 		&stepConfigureVNC{},
@@ -422,7 +464,8 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		&stepCleanFiles{},
 		&stepCleanVMX{},
 		&stepCompactDisk{},
-	}
+		&stepExport{},
+	)
 
 	// Setup the state bag
 	state := new(multistep.BasicStateBag)
@@ -458,15 +501,22 @@ func (b *Builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packe
 		return nil, errors.New("Build was halted.")
 	}
 
-	// Compile the artifact list
-	files, err := state.Get("dir").(OutputDir).ListFiles()
-	if err != nil {
-		return nil, err
+	// Compile the artifact list. If the VM was exported, only the export
+	// is, which is always in the local output directory.
+	var files []string
+	if b.config.Format != "" {
+		files = state.Get("export_files").([]string)
+	} else {
+		dir := state.Get("dir").(OutputDir)
+		files, err = dir.ListFiles()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Set the proper builder ID
 	builderId := BuilderId
-	if b.config.RemoteType != "" {
+	if b.config.RemoteType != "" && b.config.Format == "" {
 		builderId = BuilderIdESX
 	}
 
@@ -262,6 +262,34 @@ func TestBuilderPrepare_FloppyFiles(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_Format(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	// Bad
+	config["format"] = "foobar"
+	warns, err := b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Good
+	for _, format := range []string{"ova", "ovf"} {
+		config["format"] = format
+		b = Builder{}
+		warns, err = b.Prepare(config)
+		if len(warns) > 0 {
+			t.Fatalf("bad: %#v", warns)
+		}
+		if err != nil {
+			t.Fatalf("should not have error: %s", err)
+		}
+	}
+}
+
 func TestBuilderPrepare_HTTPPort(t *testing.T) {
 	var b Builder
 	config := testConfig()
@@ -460,6 +488,52 @@ func TestBuilderPrepare_ShutdownTimeout(t *testing.T) {
 	}
 }
 
+func TestBuilderPrepare_SourcePath(t *testing.T) {
+	var b Builder
+	config := testConfig()
+
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	tf.Close()
+	defer os.Remove(tf.Name())
+
+	// Test with both an ISO and a source path
+	config["source_path"] = tf.Name()
+	warns, err := b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Test with only a source path
+	delete(config, "iso_checksum")
+	delete(config, "iso_checksum_type")
+	delete(config, "iso_url")
+	b = Builder{}
+	warns, err = b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err != nil {
+		t.Fatalf("should not have error: %s", err)
+	}
+
+	// Test with a source path that doesn't exist
+	config["source_path"] = "i-hope-i-dont-exist.vmx"
+	b = Builder{}
+	warns, err = b.Prepare(config)
+	if len(warns) > 0 {
+		t.Fatalf("bad: %#v", warns)
+	}
+	if err == nil {
+		t.Fatal("should have error")
+	}
+}
+
 func TestBuilderPrepare_sshKeyPath(t *testing.T) {
 	var b Builder
 	config := testConfig()
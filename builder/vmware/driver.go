@@ -4,14 +4,21 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/mitchellh/multistep"
+	"io"
 	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
 	"strings"
 )
 
 // A driver is able to talk to VMware, control virtual machines, etc.
 type Driver interface {
+	// Clone clones the VMX and the disks referenced by it, given as the
+	// second argument, to the destination VMX path given as the first.
+	Clone(string, string) error
+
 	// CompactDisk compacts a virtual disk.
 	CompactDisk(string) error
 
@@ -125,3 +132,52 @@ func runAndLog(cmd *exec.Cmd) (string, string, error) {
 
 	return returnStdout, returnStderr, err
 }
+
+// cloneLocalVMX copies the VMX at src along with all of the disks it
+// references into the directory of dst, rewriting the disk references
+// to point to the copies. This is used by the drivers that run VMware
+// on the local machine.
+func cloneLocalVMX(dst, src string) error {
+	vmxData, err := ReadVMX(src)
+	if err != nil {
+		return err
+	}
+
+	srcDir := filepath.Dir(src)
+	dstDir := filepath.Dir(dst)
+	copied := make(map[string]bool)
+	for _, k := range VMXDiskKeys(vmxData) {
+		diskPath := vmxData[k]
+		if !filepath.IsAbs(diskPath) {
+			diskPath = filepath.Join(srcDir, diskPath)
+		}
+
+		if err := cloneLocalDisk(dstDir, diskPath, copied); err != nil {
+			return err
+		}
+
+		vmxData[k] = filepath.Base(diskPath)
+	}
+
+	return WriteVMX(dst, vmxData)
+}
+
+func copyFile(dst, src string) error {
+	srcF, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcF.Close()
+
+	dstF, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dstF, srcF); err != nil {
+		dstF.Close()
+		return err
+	}
+
+	return dstF.Close()
+}
@@ -33,6 +33,38 @@ type ESX5Driver struct {
 	outputDir string
 }
 
+func (d *ESX5Driver) Clone(dstLocal string, srcPath string) error {
+	if !strings.HasPrefix(srcPath, "/vmfs/volumes") {
+		srcPath = d.datastorePath(srcPath)
+	}
+
+	contents, err := d.run(nil, "cat", srcPath)
+	if err != nil {
+		return err
+	}
+
+	vmxData := ParseVMX(contents)
+	srcDir := filepath.Dir(srcPath)
+	for _, k := range VMXDiskKeys(vmxData) {
+		diskPath := vmxData[k]
+		if !strings.HasPrefix(diskPath, "/") {
+			diskPath = filepath.Join(srcDir, diskPath)
+		}
+
+		diskName := filepath.Base(diskPath)
+		targetPath := filepath.Join(d.outputDir, diskName)
+		if err := d.sh("vmkfstools", "-i", diskPath, "-d", "thin", targetPath); err != nil {
+			return err
+		}
+
+		vmxData[k] = diskName
+	}
+
+	// The VMX is written locally and uploaded to the output directory
+	// when the VM is registered, just like a freshly created VMX.
+	return WriteVMX(dstLocal, vmxData)
+}
+
 func (d *ESX5Driver) CompactDisk(diskPathLocal string) error {
 	return nil
 }
@@ -16,6 +16,10 @@ type Fusion5Driver struct {
 	AppPath string
 }
 
+func (d *Fusion5Driver) Clone(dst, src string) error {
+	return cloneLocalVMX(dst, src)
+}
+
 func (d *Fusion5Driver) CompactDisk(diskPath string) error {
 	defragCmd := exec.Command(d.vdiskManagerPath(), "-d", diskPath)
 	if _, _, err := runAndLog(defragCmd); err != nil {
@@ -0,0 +1,139 @@
+package vmware
+
+import (
+	"github.com/mitchellh/multistep"
+)
+
+// MockDriver is a driver implementation that can be used for tests.
+type MockDriver struct {
+	CloneError            error
+	CompactDiskError      error
+	CreateDiskError       error
+	IsRunningResult       bool
+	IsRunningError        error
+	SSHAddressResult      string
+	SSHAddressError       error
+	StartError            error
+	StopError             error
+	SuppressMessagesError error
+	ToolsIsoPathResult    string
+	DhcpLeasesPathResult  string
+	VerifyError           error
+
+	CloneCalled  bool
+	CloneDst     string
+	CloneSrc     string
+	StartCalled  bool
+	StartPath    string
+	StopCalled   bool
+	StopPath     string
+	VerifyCalled bool
+}
+
+func (d *MockDriver) Clone(dst string, src string) error {
+	d.CloneCalled = true
+	d.CloneDst = dst
+	d.CloneSrc = src
+	return d.CloneError
+}
+
+func (d *MockDriver) CompactDisk(string) error {
+	return d.CompactDiskError
+}
+
+func (d *MockDriver) CreateDisk(string, string, string) error {
+	return d.CreateDiskError
+}
+
+func (d *MockDriver) IsRunning(string) (bool, error) {
+	return d.IsRunningResult, d.IsRunningError
+}
+
+func (d *MockDriver) SSHAddress(multistep.StateBag) (string, error) {
+	return d.SSHAddressResult, d.SSHAddressError
+}
+
+func (d *MockDriver) Start(path string, headless bool) error {
+	d.StartCalled = true
+	d.StartPath = path
+	return d.StartError
+}
+
+func (d *MockDriver) Stop(path string) error {
+	d.StopCalled = true
+	d.StopPath = path
+	return d.StopError
+}
+
+func (d *MockDriver) SuppressMessages(string) error {
+	return d.SuppressMessagesError
+}
+
+func (d *MockDriver) ToolsIsoPath(string) string {
+	return d.ToolsIsoPathResult
+}
+
+func (d *MockDriver) DhcpLeasesPath(string) string {
+	return d.DhcpLeasesPathResult
+}
+
+func (d *MockDriver) Verify() error {
+	d.VerifyCalled = true
+	return d.VerifyError
+}
+
+// MockRemoteDriver is a remote driver implementation that can be used
+// for tests. Like ESX5Driver, it is also the OutputDir of the build.
+type MockRemoteDriver struct {
+	MockDriver
+
+	UploadISOResult string
+	UploadISOError  error
+	RegisterError   error
+	UnregisterError error
+	RemoveAllError  error
+
+	RegisterCalled   bool
+	UnregisterCalled bool
+	RemoveAllCalled  bool
+	OutputDir        string
+}
+
+func (d *MockRemoteDriver) UploadISO(string) (string, error) {
+	return d.UploadISOResult, d.UploadISOError
+}
+
+func (d *MockRemoteDriver) Register(string) error {
+	d.RegisterCalled = true
+	return d.RegisterError
+}
+
+func (d *MockRemoteDriver) Unregister(string) error {
+	d.UnregisterCalled = true
+	return d.UnregisterError
+}
+
+func (d *MockRemoteDriver) DirExists() (bool, error) {
+	return false, nil
+}
+
+func (d *MockRemoteDriver) ListFiles() ([]string, error) {
+	return nil, nil
+}
+
+func (d *MockRemoteDriver) MkdirAll() error {
+	return nil
+}
+
+func (d *MockRemoteDriver) Remove(string) error {
+	return nil
+}
+
+func (d *MockRemoteDriver) RemoveAll() error {
+	d.RemoveAllCalled = true
+	return d.RemoveAllError
+}
+
+func (d *MockRemoteDriver) SetOutputDir(path string) {
+	d.OutputDir = path
+}
@@ -0,0 +1,9 @@
+package vmware
+
+import "testing"
+
+func TestMockDriver_impl(t *testing.T) {
+	var _ Driver = new(MockDriver)
+	var _ RemoteDriver = new(MockRemoteDriver)
+	var _ OutputDir = new(MockRemoteDriver)
+}
@@ -17,6 +17,10 @@ type Player5LinuxDriver struct {
 	VmrunPath        string
 }
 
+func (d *Player5LinuxDriver) Clone(dst, src string) error {
+	return cloneLocalVMX(dst, src)
+}
+
 func (d *Player5LinuxDriver) CompactDisk(diskPath string) error {
 	if d.QemuImgPath != "" {
 		return d.qemuCompactDisk(diskPath)
@@ -0,0 +1,169 @@
+package vmware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testSparseDisk returns a monolithic sparse disk with the descriptor
+// embedded in its second sector.
+func testSparseDisk(t *testing.T, descriptor string) []byte {
+	var buf bytes.Buffer
+	header := struct {
+		MagicNumber      [4]byte
+		Version          uint32
+		Flags            uint32
+		Capacity         uint64
+		GrainSize        uint64
+		DescriptorOffset uint64
+		DescriptorSize   uint64
+	}{
+		MagicNumber:      [4]byte{'K', 'D', 'M', 'V'},
+		Version:          1,
+		Capacity:         100,
+		GrainSize:        128,
+		DescriptorOffset: 1,
+		DescriptorSize:   1,
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, &header); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data := make([]byte, 1024)
+	copy(data, buf.Bytes())
+	copy(data[512:], descriptor)
+	return data
+}
+
+func testWriteFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+}
+
+func TestCloneLocalVMX(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	srcDir := filepath.Join(td, "src")
+	otherDir := filepath.Join(td, "other")
+	dstDir := filepath.Join(td, "dst")
+	for _, dir := range []string{srcDir, otherDir, dstDir} {
+		os.MkdirAll(dir, 0755)
+	}
+
+	// The VM is running from a snapshot, so the VMX references a delta
+	// disk based on a split disk. There is also a disk in another
+	// directory with an embedded descriptor, and an unrelated disk.
+	testWriteFiles(t, srcDir, map[string]string{
+		"base.vmx": `scsi0:0.fileName = "base-000001.vmdk"
+scsi0:1.fileName = "` + filepath.Join(otherDir, "other.vmdk") + `"
+`,
+		"base.vmdk": `# Disk DescriptorFile
+createType="twoGbMaxExtentSparse"
+RW 4192256 SPARSE "base-s001.vmdk"
+RW 4192256 SPARSE "base-s002.vmdk"
+`,
+		"base-s001.vmdk": "data1",
+		"base-s002.vmdk": "data2",
+		"base-000001.vmdk": `# Disk DescriptorFile
+parentFileNameHint="` + filepath.Join(srcDir, "base.vmdk") + `"
+RW 8384512 SPARSE "base-000001-s001.vmdk"
+`,
+		"base-000001-s001.vmdk": "delta",
+		"base-unrelated.vmdk":   "unrelated",
+	})
+
+	other := testSparseDisk(t, `# Disk DescriptorFile
+createType="monolithicSparse"
+RW 100 SPARSE "other.vmdk"
+`)
+	if err := ioutil.WriteFile(filepath.Join(otherDir, "other.vmdk"), other, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	dst := filepath.Join(dstDir, "foo.vmx")
+	if err := cloneLocalVMX(dst, filepath.Join(srcDir, "base.vmx")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	files, err := localFiles(dstDir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	names := make([]string, len(files))
+	for i, path := range files {
+		names[i] = filepath.Base(path)
+	}
+	sort.Strings(names)
+
+	expected := []string{
+		"base-000001-s001.vmdk",
+		"base-000001.vmdk",
+		"base-s001.vmdk",
+		"base-s002.vmdk",
+		"base.vmdk",
+		"foo.vmx",
+		"other.vmdk",
+	}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("bad: %#v", names)
+	}
+
+	// The parent is referenced by the copy
+	delta, err := ioutil.ReadFile(filepath.Join(dstDir, "base-000001.vmdk"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !strings.Contains(string(delta), `parentFileNameHint="base.vmdk"`) {
+		t.Fatalf("bad: %s", delta)
+	}
+
+	copied, err := ioutil.ReadFile(filepath.Join(dstDir, "other.vmdk"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !bytes.Equal(copied, other) {
+		t.Fatal("embedded descriptor disk should be copied as it is")
+	}
+
+	vmxData, err := ReadVMX(dst)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if vmxData["scsi0:0.filename"] != "base-000001.vmdk" ||
+		vmxData["scsi0:1.filename"] != "other.vmdk" {
+		t.Fatalf("bad: %#v", vmxData)
+	}
+}
+
+func TestCloneLocalVMX_missingExtent(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	testWriteFiles(t, td, map[string]string{
+		"base.vmx":  `scsi0:0.fileName = "base.vmdk"`,
+		"base.vmdk": `RW 8384512 FLAT "base-flat.vmdk" 0`,
+	})
+
+	dstDir := filepath.Join(td, "dst")
+	os.MkdirAll(dstDir, 0755)
+	if err := cloneLocalVMX(filepath.Join(dstDir, "foo.vmx"), filepath.Join(td, "base.vmx")); err == nil {
+		t.Fatal("should have error")
+	}
+}
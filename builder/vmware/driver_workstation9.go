@@ -18,6 +18,10 @@ type Workstation9Driver struct {
 	VmrunPath        string
 }
 
+func (d *Workstation9Driver) Clone(dst, src string) error {
+	return cloneLocalVMX(dst, src)
+}
+
 func (d *Workstation9Driver) CompactDisk(diskPath string) error {
 	defragCmd := exec.Command(d.VdiskManagerPath, "-d", diskPath)
 	if _, _, err := runAndLog(defragCmd); err != nil {
@@ -4,9 +4,7 @@ import (
 	"fmt"
 	"github.com/mitchellh/multistep"
 	"github.com/mitchellh/packer/packer"
-	"io/ioutil"
 	"log"
-	"os"
 	"regexp"
 	"strings"
 )
@@ -15,6 +13,7 @@ import (
 // being ready for use.
 //
 // Uses:
+//   iso_path string (optional)
 //   ui     packer.Ui
 //   vmx_path string
 //
@@ -23,13 +22,13 @@ import (
 type stepCleanVMX struct{}
 
 func (s stepCleanVMX) Run(state multistep.StateBag) multistep.StepAction {
-	isoPath := state.Get("iso_path").(string)
+	isoPath, _ := state.Get("iso_path").(string)
 	ui := state.Get("ui").(packer.Ui)
 	vmxPath := state.Get("vmx_path").(string)
 
 	ui.Say("Cleaning VMX prior to finishing up...")
 
-	vmxData, err := s.readVMX(vmxPath)
+	vmxData, err := ReadVMX(vmxPath)
 	if err != nil {
 		state.Put("error", fmt.Errorf("Error reading VMX: %s", err))
 		return multistep.ActionHalt
@@ -47,20 +46,22 @@ func (s stepCleanVMX) Run(state multistep.StateBag) multistep.StepAction {
 		vmxData["floppy0.present"] = "FALSE"
 	}
 
-	ui.Message("Detaching ISO from CD-ROM device...")
-	devRe := regexp.MustCompile(`^ide\d:\d\.`)
-	for k, _ := range vmxData {
-		match := devRe.FindString(k)
-		if match == "" {
-			continue
-		}
+	if isoPath != "" {
+		ui.Message("Detaching ISO from CD-ROM device...")
+		devRe := regexp.MustCompile(`^ide\d:\d\.`)
+		for k, _ := range vmxData {
+			match := devRe.FindString(k)
+			if match == "" {
+				continue
+			}
 
-		filenameKey := match + ".filename"
-		if filename, ok := vmxData[filenameKey]; ok {
-			if filename == isoPath {
-				// Change the CD-ROM device back to auto-detect to eject
-				vmxData[filenameKey] = "auto detect"
-				vmxData[match+".devicetype"] = "cdrom-raw"
+			filenameKey := match + ".filename"
+			if filename, ok := vmxData[filenameKey]; ok {
+				if filename == isoPath {
+					// Change the CD-ROM device back to auto-detect to eject
+					vmxData[filenameKey] = "auto detect"
+					vmxData[match+".devicetype"] = "cdrom-raw"
+				}
 			}
 		}
 	}
@@ -75,18 +76,3 @@ func (s stepCleanVMX) Run(state multistep.StateBag) multistep.StepAction {
 }
 
 func (stepCleanVMX) Cleanup(multistep.StateBag) {}
-
-func (stepCleanVMX) readVMX(vmxPath string) (map[string]string, error) {
-	vmxF, err := os.Open(vmxPath)
-	if err != nil {
-		return nil, err
-	}
-	defer vmxF.Close()
-
-	vmxBytes, err := ioutil.ReadAll(vmxF)
-	if err != nil {
-		return nil, err
-	}
-
-	return ParseVMX(string(vmxBytes)), nil
-}
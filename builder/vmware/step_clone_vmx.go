@@ -0,0 +1,124 @@
+package vmware
+
+import (
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// This step clones an existing VMX and its disks into the output
+// directory so that it can be used as the VM for the build.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//
+// Produces:
+//   full_disk_path string - The full path to the primary cloned disk.
+//   vmx_path string - The path to the VMX file.
+type stepCloneVMX struct {
+	tempDir string
+}
+
+func (s *stepCloneVMX) Run(state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*config)
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+
+	vmxDir := config.OutputDir
+	if config.RemoteType != "" {
+		// For remote builds, the VMX is written to a temporary directory
+		// and uploaded when the VM is registered.
+		var err error
+		vmxDir, err = ioutil.TempDir("", "packer-vmx")
+		if err != nil {
+			err := fmt.Errorf("Error preparing VMX: %s", err)
+			state.Put("error", err)
+			ui.Error(err.Error())
+			return multistep.ActionHalt
+		}
+
+		// Set the tempDir so we clean it up
+		s.tempDir = vmxDir
+	}
+
+	vmxPath := filepath.Join(vmxDir, config.VMName+".vmx")
+
+	ui.Say("Cloning source VM...")
+	log.Printf("Cloning from: %s", config.SourcePath)
+	log.Printf("Cloning to: %s", vmxPath)
+	if err := driver.Clone(vmxPath, config.SourcePath); err != nil {
+		err := fmt.Errorf("Error cloning source VM: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	vmxData, err := ReadVMX(vmxPath)
+	if err != nil {
+		err := fmt.Errorf("Error reading cloned VMX: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	diskKeys := VMXDiskKeys(vmxData)
+	if len(diskKeys) == 0 {
+		err := fmt.Errorf("Source VMX has no disks: %s", config.SourcePath)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// Clear out identifiers that VMware would otherwise consider
+	// duplicates of the source VM, and give the clone its own name.
+	for _, k := range []string{"ethernet0.generatedaddress", "uuid.bios", "uuid.location", "vc.uuid"} {
+		delete(vmxData, k)
+	}
+	vmxData["displayname"] = config.VMName
+	vmxData["nvram"] = config.VMName + ".nvram"
+	vmxData["extendedconfigfile"] = config.VMName + ".vmxf"
+
+	if config.VMXData != nil {
+		log.Println("Setting custom VMX data...")
+		for k, v := range config.VMXData {
+			log.Printf("Setting VMX: '%s' = '%s'", k, v)
+			k = strings.ToLower(k)
+			vmxData[k] = v
+		}
+	}
+
+	if floppyPathRaw, ok := state.GetOk("floppy_path"); ok {
+		log.Println("Floppy path present, setting in VMX")
+		vmxData["floppy0.present"] = "TRUE"
+		vmxData["floppy0.filetype"] = "file"
+		vmxData["floppy0.filename"] = floppyPathRaw.(string)
+	}
+
+	// Set this so that no dialogs ever appear from Packer.
+	vmxData["msg.autoanswer"] = "true"
+
+	if err := WriteVMX(vmxPath, vmxData); err != nil {
+		err := fmt.Errorf("Error writing VMX file: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	state.Put("full_disk_path", filepath.Join(config.OutputDir, vmxData[diskKeys[0]]))
+	state.Put("vmx_path", vmxPath)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepCloneVMX) Cleanup(multistep.StateBag) {
+	if s.tempDir != "" {
+		os.RemoveAll(s.tempDir)
+	}
+}
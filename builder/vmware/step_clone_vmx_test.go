@@ -0,0 +1,136 @@
+package vmware
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testStepCloneVMXState(t *testing.T, c *config, driver Driver) multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("config", c)
+	state.Put("driver", driver)
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	return state
+}
+
+func TestStepCloneVMX_impl(t *testing.T) {
+	var _ multistep.Step = new(stepCloneVMX)
+}
+
+func TestStepCloneVMX(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	// The mock driver doesn't clone, so the cloned VMX is written here
+	vmxPath := filepath.Join(td, "foo.vmx")
+	err = ioutil.WriteFile(vmxPath, []byte(`displayName = "base"
+scsi0:0.fileName = "disk.vmdk"
+uuid.bios = "56 4d 12 34"
+ethernet0.generatedAddress = "00:0c:29:12:34:56"
+`), 0644)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &config{
+		OutputDir:  td,
+		SourcePath: "base.vmx",
+		VMName:     "foo",
+		VMXData:    map[string]string{"memsize": "1024"},
+	}
+	driver := new(MockDriver)
+	state := testStepCloneVMXState(t, c, driver)
+	state.Put("floppy_path", "floppy.flp")
+
+	step := new(stepCloneVMX)
+	defer step.Cleanup(state)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v %#v", action, state.Get("error"))
+	}
+
+	if !driver.CloneCalled || driver.CloneDst != vmxPath || driver.CloneSrc != "base.vmx" {
+		t.Fatalf("bad: %#v", driver)
+	}
+
+	if state.Get("vmx_path").(string) != vmxPath {
+		t.Fatalf("bad: %#v", state.Get("vmx_path"))
+	}
+	if state.Get("full_disk_path").(string) != filepath.Join(td, "disk.vmdk") {
+		t.Fatalf("bad: %#v", state.Get("full_disk_path"))
+	}
+
+	vmxData, err := ReadVMX(vmxPath)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]string{
+		"displayname":      "foo",
+		"memsize":          "1024",
+		"floppy0.filename": "floppy.flp",
+		"msg.autoanswer":   "true",
+	}
+	for k, v := range expected {
+		if vmxData[k] != v {
+			t.Fatalf("bad %s: %#v", k, vmxData)
+		}
+	}
+
+	for _, k := range []string{"uuid.bios", "ethernet0.generatedaddress"} {
+		if _, ok := vmxData[k]; ok {
+			t.Fatalf("should not have %s: %#v", k, vmxData)
+		}
+	}
+}
+
+func TestStepCloneVMX_noDisks(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+
+	vmxPath := filepath.Join(td, "foo.vmx")
+	if err := ioutil.WriteFile(vmxPath, []byte(`displayName = "base"`), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c := &config{OutputDir: td, SourcePath: "base.vmx", VMName: "foo"}
+	state := testStepCloneVMXState(t, c, new(MockDriver))
+
+	step := new(stepCloneVMX)
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
+
+func TestStepCloneVMX_cloneError(t *testing.T) {
+	c := &config{OutputDir: "output", SourcePath: "base.vmx", VMName: "foo"}
+	driver := &MockDriver{CloneError: errors.New("foo")}
+	state := testStepCloneVMXState(t, c, driver)
+
+	step := new(stepCloneVMX)
+	if action := step.Run(state); action != multistep.ActionHalt {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state.GetOk("error"); !ok {
+		t.Fatal("should have error")
+	}
+}
@@ -0,0 +1,159 @@
+package vmware
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// This step exports the VM to an OVF or OVA using ovftool, if a "format"
+// was configured. Once exported, the files of a local VM are deleted from
+// the output directory, so that only the export is left. The VM on a
+// remote host is deleted by stepPrepareOutputDir, after stepRun has
+// unregistered it.
+//
+// Uses:
+//   config *config
+//   driver Driver
+//   ui     packer.Ui
+//   vmx_path string
+//
+// Produces:
+//   export_path string - The path to the exported OVF or OVA.
+//   export_files []string - The files of the export.
+type stepExport struct{}
+
+func (s *stepExport) Run(state multistep.StateBag) multistep.StepAction {
+	config := state.Get("config").(*config)
+	driver := state.Get("driver").(Driver)
+	ui := state.Get("ui").(packer.Ui)
+	vmxPath := state.Get("vmx_path").(string)
+
+	if config.Format == "" {
+		return multistep.ActionContinue
+	}
+
+	ovftool, err := exec.LookPath("ovftool")
+	if err != nil {
+		err := fmt.Errorf("Error exporting VM: ovftool not found in PATH: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// The export always goes to the local output directory, even for
+	// remote builds, so that the artifact can be used directly.
+	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+		err := fmt.Errorf("Error creating export directory: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	// The remote password is left out of the locator so that it doesn't
+	// show up in the process list. ovftool prompts for it instead, and
+	// it's given on stdin.
+	source := vmxPath
+	var stdin bytes.Buffer
+	_, remote := driver.(RemoteDriver)
+	if remote {
+		u := &url.URL{
+			Scheme: "vi",
+			User:   url.User(config.RemoteUser),
+			Host:   config.RemoteHost,
+			Path:   "/" + config.VMName,
+		}
+		source = u.String()
+		stdin.WriteString(config.RemotePassword + "\n")
+	}
+
+	// Whatever is in the output directory before the export is the VM
+	vmFiles, err := localFiles(config.OutputDir)
+	if err != nil {
+		err := fmt.Errorf("Error exporting VM: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	exportPath := filepath.Join(config.OutputDir, config.VMName+"."+config.Format)
+	args := []string{
+		"--noSSLVerify=true",
+		"--skipManifestCheck",
+		"-tt=" + config.Format,
+		source,
+		exportPath,
+	}
+
+	ui.Say(fmt.Sprintf("Exporting virtual machine to %s...", config.Format))
+
+	var out bytes.Buffer
+	log.Printf("Executing: %s %v", ovftool, args)
+	cmd := exec.Command(ovftool, args...)
+	cmd.Stdin = &stdin
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		err := fmt.Errorf("Error exporting VM: %s\n\n%s", err, out.String())
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	files, err := localFiles(config.OutputDir)
+	if err != nil {
+		err := fmt.Errorf("Error listing the exported files: %s", err)
+		state.Put("error", err)
+		ui.Error(err.Error())
+		return multistep.ActionHalt
+	}
+
+	exportFiles := make([]string, 0, len(files))
+	for _, path := range files {
+		if !containsString(vmFiles, path) {
+			exportFiles = append(exportFiles, path)
+		}
+	}
+
+	if !remote {
+		ui.Message("Deleting the virtual machine files that were exported...")
+		for _, path := range vmFiles {
+			if err := os.Remove(path); err != nil {
+				err := fmt.Errorf("Error deleting exported VM file: %s", err)
+				state.Put("error", err)
+				ui.Error(err.Error())
+				return multistep.ActionHalt
+			}
+		}
+	}
+
+	state.Put("export_path", exportPath)
+	state.Put("export_files", exportFiles)
+
+	return multistep.ActionContinue
+}
+
+func (s *stepExport) Cleanup(multistep.StateBag) {}
+
+// localFiles lists the files in a local directory.
+func localFiles(path string) ([]string, error) {
+	dir := new(localOutputDir)
+	dir.SetOutputDir(path)
+	return dir.ListFiles()
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,182 @@
+package vmware
+
+import (
+	"bytes"
+	"github.com/mitchellh/multistep"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// testOvftool puts a fake ovftool on the PATH that records its arguments
+// and stdin in dir and creates the file it exports to. It returns a
+// function that restores the PATH.
+func testOvftool(t *testing.T, dir string) func() {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake ovftool is a shell script")
+	}
+
+	script := `#!/bin/sh
+echo "$@" > "` + filepath.Join(dir, "args") + `"
+cat > "` + filepath.Join(dir, "stdin") + `"
+for last; do :; done
+touch "$last"
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "ovftool"), []byte(script), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return func() { os.Setenv("PATH", path) }
+}
+
+func testStepExportState(t *testing.T, c *config, driver Driver) multistep.StateBag {
+	state := new(multistep.BasicStateBag)
+	state.Put("config", c)
+	state.Put("driver", driver)
+	state.Put("vmx_path", filepath.Join(c.OutputDir, "foo.vmx"))
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+	return state
+}
+
+func TestStepExport_impl(t *testing.T) {
+	var _ multistep.Step = new(stepExport)
+}
+
+func TestStepExport_local(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	defer testOvftool(t, td)()
+
+	outputDir := filepath.Join(td, "output")
+	os.MkdirAll(outputDir, 0755)
+	for _, name := range []string{"foo.vmx", "disk.vmdk"} {
+		if err := ioutil.WriteFile(filepath.Join(outputDir, name), nil, 0644); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	c := &config{Format: "ova", VMName: "foo", OutputDir: outputDir}
+	state := testStepExportState(t, c, new(MockDriver))
+
+	step := new(stepExport)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v %#v", action, state.Get("error"))
+	}
+
+	exportPath := filepath.Join(outputDir, "foo.ova")
+	if state.Get("export_path").(string) != exportPath {
+		t.Fatalf("bad: %#v", state.Get("export_path"))
+	}
+
+	// Only the export is left
+	files := state.Get("export_files").([]string)
+	if len(files) != 1 || files[0] != exportPath {
+		t.Fatalf("bad: %#v", files)
+	}
+
+	left, err := localFiles(outputDir)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if len(left) != 1 || left[0] != exportPath {
+		t.Fatalf("bad: %#v", left)
+	}
+
+	args, _ := ioutil.ReadFile(filepath.Join(td, "args"))
+	if !strings.Contains(string(args), filepath.Join(outputDir, "foo.vmx")) {
+		t.Fatalf("bad: %s", args)
+	}
+}
+
+func TestStepExport_remote(t *testing.T) {
+	td, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(td)
+	defer testOvftool(t, td)()
+
+	c := &config{
+		Format:         "ovf",
+		VMName:         "foo",
+		OutputDir:      filepath.Join(td, "output"),
+		RemoteHost:     "esx.example.com",
+		RemoteUser:     "root",
+		RemotePassword: "s3cr3t",
+	}
+	state := testStepExportState(t, c, new(MockRemoteDriver))
+
+	step := new(stepExport)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v %#v", action, state.Get("error"))
+	}
+
+	// The password isn't on the command line, but given to the prompt
+	args, _ := ioutil.ReadFile(filepath.Join(td, "args"))
+	if strings.Contains(string(args), "s3cr3t") {
+		t.Fatalf("bad: %s", args)
+	}
+	if !strings.Contains(string(args), "vi://root@esx.example.com/foo") {
+		t.Fatalf("bad: %s", args)
+	}
+
+	stdin, _ := ioutil.ReadFile(filepath.Join(td, "stdin"))
+	if string(stdin) != "s3cr3t\n" {
+		t.Fatalf("bad: %q", stdin)
+	}
+
+	files := state.Get("export_files").([]string)
+	if len(files) != 1 || files[0] != filepath.Join(c.OutputDir, "foo.ovf") {
+		t.Fatalf("bad: %#v", files)
+	}
+}
+
+func TestStepExport_noFormat(t *testing.T) {
+	c := &config{VMName: "foo", OutputDir: "output"}
+	state := testStepExportState(t, c, new(MockDriver))
+
+	step := new(stepExport)
+	if action := step.Run(state); action != multistep.ActionContinue {
+		t.Fatalf("bad action: %#v", action)
+	}
+
+	if _, ok := state.GetOk("export_path"); ok {
+		t.Fatal("should not export")
+	}
+}
+
+func TestStepPrepareOutputDirCleanup_exportedRemote(t *testing.T) {
+	driver := new(MockRemoteDriver)
+	state := new(multistep.BasicStateBag)
+	state.Put("driver", driver)
+	state.Put("ui", &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	})
+
+	// Not exported, the remote VM is kept
+	step := &stepPrepareOutputDir{dir: driver}
+	step.Cleanup(state)
+	if driver.RemoveAllCalled {
+		t.Fatal("should not remove the remote VM")
+	}
+
+	// Exported, the remote VM is deleted
+	state.Put("export_path", "output/foo.ovf")
+	step.Cleanup(state)
+	if !driver.RemoveAllCalled {
+		t.Fatal("should remove the remote VM")
+	}
+}
@@ -51,11 +51,21 @@ func (s *stepPrepareOutputDir) Cleanup(state multistep.StateBag) {
 	_, cancelled := state.GetOk(multistep.StateCancelled)
 	_, halted := state.GetOk(multistep.StateHalted)
 
-	if cancelled || halted {
+	// A remote VM that was exported isn't needed anymore, since the
+	// export is in the local output directory.
+	_, exported := state.GetOk("export_path")
+	_, remote := state.Get("driver").(RemoteDriver)
+
+	if cancelled || halted || (exported && remote) {
 		ui := state.Get("ui").(packer.Ui)
 
 		if s.dir != nil {
-			ui.Say("Deleting output directory...")
+			if cancelled || halted {
+				ui.Say("Deleting output directory...")
+			} else {
+				ui.Say("Deleting the exported virtual machine from the remote host...")
+			}
+
 			for i := 0; i < 5; i++ {
 				err := s.dir.RemoveAll()
 				if err == nil {
@@ -0,0 +1,150 @@
+package vmware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The largest text descriptor that is read. Anything bigger is the data
+// of a disk rather than a descriptor.
+const maxVMDKDescriptorSize = 1024 * 1024
+
+var (
+	vmdkExtentRe = regexp.MustCompile(`^((?:RW|RDONLY|NOACCESS)\s+\d+\s+\S+\s+)"([^"]+)"(.*)$`)
+	vmdkParentRe = regexp.MustCompile(`^(parentFileNameHint\s*=\s*)"([^"]*)"(.*)$`)
+)
+
+// cloneLocalDisk copies the virtual disk whose descriptor is at src into
+// dstDir, along with the extents the descriptor lists and, if the disk is
+// the delta disk of a snapshot, the parent disks it is based on. The
+// extents and parents are looked up relative to the descriptor, and the
+// references to them in copied text descriptors are rewritten to the
+// copies. copied is the set of files that were already copied, so that
+// a parent shared by several disks is only copied once.
+func cloneLocalDisk(dstDir, src string, copied map[string]bool) error {
+	if copied[src] {
+		return nil
+	}
+	copied[src] = true
+
+	desc, embedded, err := readVMDKDescriptor(src)
+	if err != nil {
+		return fmt.Errorf("Error reading disk %s: %s", src, err)
+	}
+
+	srcDir := filepath.Dir(src)
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return path
+		}
+
+		return filepath.Join(srcDir, path)
+	}
+
+	lines := strings.Split(desc, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+
+		if m := vmdkExtentRe.FindStringSubmatch(line); m != nil {
+			// The extents of a disk with an embedded descriptor are the
+			// file itself.
+			if embedded {
+				continue
+			}
+
+			path := resolve(m[2])
+			target := filepath.Join(dstDir, filepath.Base(path))
+			log.Printf("Cloning disk file: %s => %s", path, target)
+			if err := copyFile(target, path); err != nil {
+				return err
+			}
+
+			lines[i] = fmt.Sprintf(`%s"%s"%s`, m[1], filepath.Base(path), m[3])
+		}
+
+		if m := vmdkParentRe.FindStringSubmatch(line); m != nil && m[2] != "" {
+			path := resolve(m[2])
+			if err := cloneLocalDisk(dstDir, path, copied); err != nil {
+				return err
+			}
+
+			lines[i] = fmt.Sprintf(`%s"%s"%s`, m[1], filepath.Base(path), m[3])
+		}
+	}
+
+	target := filepath.Join(dstDir, filepath.Base(src))
+	if embedded {
+		// The descriptor is part of the disk data, so the file is copied
+		// as it is. Its parent must be next to it for the copy to work.
+		log.Printf("Cloning disk file: %s => %s", src, target)
+		return copyFile(target, src)
+	}
+
+	log.Printf("Writing disk descriptor: %s", target)
+	return ioutil.WriteFile(target, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// readVMDKDescriptor reads the descriptor of the virtual disk at path.
+// The descriptor is either the whole file or, for a monolithic sparse
+// disk, embedded in the file, in which case embedded is true. A sparse
+// disk without an embedded descriptor has an empty one.
+func readVMDKDescriptor(path string) (desc string, embedded bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	// The header of a sparse extent, of which only the location of the
+	// descriptor is needed. Offsets and sizes are in sectors.
+	var header struct {
+		MagicNumber      [4]byte
+		Version          uint32
+		Flags            uint32
+		Capacity         uint64
+		GrainSize        uint64
+		DescriptorOffset uint64
+		DescriptorSize   uint64
+	}
+
+	if err := binary.Read(f, binary.LittleEndian, &header); err == nil &&
+		string(header.MagicNumber[:]) == "KDMV" {
+		if header.DescriptorOffset == 0 {
+			return "", true, nil
+		}
+
+		data := make([]byte, header.DescriptorSize*512)
+		if _, err := f.ReadAt(data, int64(header.DescriptorOffset*512)); err != nil && err != io.EOF {
+			return "", true, err
+		}
+
+		return string(bytes.TrimRight(data, "\x00")), true, nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", false, err
+	}
+	if info.Size() > maxVMDKDescriptorSize {
+		return "", false, fmt.Errorf("not a VMDK descriptor: %s", path)
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", false, err
+	}
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", false, err
+	}
+
+	return string(data), false, nil
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"regexp"
@@ -68,3 +69,35 @@ func WriteVMX(path string, data map[string]string) (err error) {
 
 	return
 }
+
+// ReadVMX takes a path to a VMX file and reads it into a k/v mapping.
+func ReadVMX(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseVMX(string(data)), nil
+}
+
+// VMXDiskKeys returns the sorted keys of the VMX data that reference
+// a virtual disk file, such as "scsi0:0.filename".
+func VMXDiskKeys(contents map[string]string) []string {
+	diskRe := regexp.MustCompile(`^(ide|sata|scsi)\d+:\d+\.filename$`)
+
+	keys := make([]string, 0, 1)
+	for k, v := range contents {
+		if !diskRe.MatchString(k) {
+			continue
+		}
+
+		if !strings.HasSuffix(strings.ToLower(v), ".vmdk") {
+			continue
+		}
+
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
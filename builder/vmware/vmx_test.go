@@ -1,6 +1,9 @@
 package vmware
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestParseVMX(t *testing.T) {
 	contents := `
@@ -37,3 +40,19 @@ config.version = "8"
 		t.Errorf("invalid results: %s", result)
 	}
 }
+
+func TestVMXDiskKeys(t *testing.T) {
+	contents := map[string]string{
+		"ide1:0.filename":  "foo.iso",
+		"scsi0:1.filename": "data.vmdk",
+		"scsi0:0.filename": "disk.vmdk",
+		"scsi0:0.present":  "TRUE",
+		"sata0:0.filename": "other.VMDK",
+	}
+
+	expected := []string{"sata0:0.filename", "scsi0:0.filename", "scsi0:1.filename"}
+	result := VMXDiskKeys(contents)
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("bad: %#v", result)
+	}
+}
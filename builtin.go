@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"github.com/mitchellh/packer/builder/amazon/chroot"
+	"github.com/mitchellh/packer/builder/amazon/ebs"
+	"github.com/mitchellh/packer/builder/amazon/ebsvolume"
+	"github.com/mitchellh/packer/builder/amazon/instance"
+	"github.com/mitchellh/packer/builder/digitalocean"
+	"github.com/mitchellh/packer/builder/docker"
+	"github.com/mitchellh/packer/builder/null"
+	"github.com/mitchellh/packer/builder/openstack"
+	"github.com/mitchellh/packer/builder/qemu"
+	"github.com/mitchellh/packer/builder/virtualbox"
+	"github.com/mitchellh/packer/builder/vmware"
+	"github.com/mitchellh/packer/command/build"
+	"github.com/mitchellh/packer/command/fix"
+	"github.com/mitchellh/packer/command/inspect"
+	"github.com/mitchellh/packer/command/validate"
+	"github.com/mitchellh/packer/packer"
+	"github.com/mitchellh/packer/post-processor/amazon-import"
+	"github.com/mitchellh/packer/post-processor/vagrant"
+	"github.com/mitchellh/packer/post-processor/vagrant-catalog"
+	"github.com/mitchellh/packer/post-processor/vsphere"
+	"github.com/mitchellh/packer/provisioner/ansible-local"
+	"github.com/mitchellh/packer/provisioner/chef-solo"
+	"github.com/mitchellh/packer/provisioner/file"
+	"github.com/mitchellh/packer/provisioner/puppet-masterless"
+	"github.com/mitchellh/packer/provisioner/salt-masterless"
+	"github.com/mitchellh/packer/provisioner/shell"
+	"log"
+	"sync"
+)
+
+// These are the components that are compiled into Packer. They run
+// in-process rather than as plugins, unless a plugin with the same name
+// is configured or discovered, in which case the plugin is used.
+var builtinBuilders = map[string]func() packer.Builder{
+	"amazon-chroot":    func() packer.Builder { return new(chroot.Builder) },
+	"amazon-ebs":       func() packer.Builder { return new(ebs.Builder) },
+	"amazon-ebsvolume": func() packer.Builder { return new(ebsvolume.Builder) },
+	"amazon-instance":  func() packer.Builder { return new(instance.Builder) },
+	"digitalocean":     func() packer.Builder { return new(digitalocean.Builder) },
+	"docker":           func() packer.Builder { return new(docker.Builder) },
+	"null":             func() packer.Builder { return new(null.Builder) },
+	"openstack":        func() packer.Builder { return new(openstack.Builder) },
+	"qemu":             func() packer.Builder { return new(qemu.Builder) },
+	"virtualbox":       func() packer.Builder { return new(virtualbox.Builder) },
+	"vmware":           func() packer.Builder { return new(vmware.Builder) },
+}
+
+var builtinCommands = map[string]func() packer.Command{
+	"build":    func() packer.Command { return new(build.Command) },
+	"fix":      func() packer.Command { return new(fix.Command) },
+	"inspect":  func() packer.Command { return new(inspect.Command) },
+	"validate": func() packer.Command { return new(validate.Command) },
+}
+
+var builtinPostProcessors = map[string]func() packer.PostProcessor{
+	"amazon-import":   func() packer.PostProcessor { return new(amazonimport.PostProcessor) },
+	"vagrant":         func() packer.PostProcessor { return new(vagrant.PostProcessor) },
+	"vagrant-catalog": func() packer.PostProcessor { return new(vagrantcatalog.PostProcessor) },
+	"vsphere":         func() packer.PostProcessor { return new(vsphere.PostProcessor) },
+}
+
+var builtinProvisioners = map[string]func() packer.Provisioner{
+	"ansible-local":     func() packer.Provisioner { return new(ansiblelocal.Provisioner) },
+	"chef-solo":         func() packer.Provisioner { return new(chefsolo.Provisioner) },
+	"file":              func() packer.Provisioner { return new(file.Provisioner) },
+	"puppet-masterless": func() packer.Provisioner { return new(puppetmasterless.Provisioner) },
+	"salt-masterless":   func() packer.Provisioner { return new(saltmasterless.Provisioner) },
+	"shell":             func() packer.Provisioner { return new(shell.Provisioner) },
+}
+
+// isBuiltin tells whether a component of the given plugin type
+// ("builder", "command", etc.) and name is compiled into Packer.
+func isBuiltin(pluginType, name string) (ok bool) {
+	switch pluginType {
+	case "builder":
+		_, ok = builtinBuilders[name]
+	case "command":
+		_, ok = builtinCommands[name]
+	case "post-processor":
+		_, ok = builtinPostProcessors[name]
+	case "provisioner":
+		_, ok = builtinProvisioners[name]
+	}
+
+	return
+}
+
+// inProcessProvisioner wraps a built-in provisioner so that it can be
+// cancelled safely in-process. Provisioners running as plugins cancel
+// by exiting their process, which would take down all of Packer here,
+// so instead Provision returns as soon as it is cancelled and whatever
+// was running is left to finish in the background.
+//
+// That means a cancelled provisioner leaks: its goroutine keeps running,
+// and may keep using the UI and communicator, until the provisioner
+// returns on its own, usually once the communicator fails because the
+// machine is gone. Its result is only logged.
+type inProcessProvisioner struct {
+	packer.Provisioner
+
+	l        sync.Mutex
+	cancelCh chan struct{}
+}
+
+func (p *inProcessProvisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
+	cancelCh := make(chan struct{})
+	p.l.Lock()
+	p.cancelCh = cancelCh
+	p.l.Unlock()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.Provisioner.Provision(ui, comm)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-cancelCh:
+		go func() {
+			log.Printf("Cancelled provisioner finished: %v", <-errCh)
+		}()
+
+		return errors.New("Provisioning was cancelled.")
+	}
+}
+
+func (p *inProcessProvisioner) Cancel() {
+	p.l.Lock()
+	defer p.l.Unlock()
+
+	if p.cancelCh != nil {
+		close(p.cancelCh)
+		p.cancelCh = nil
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testBlockingProvisioner struct {
+	doneCh chan struct{}
+	err    error
+}
+
+func (p *testBlockingProvisioner) Prepare(...interface{}) error {
+	return nil
+}
+
+func (p *testBlockingProvisioner) Provision(packer.Ui, packer.Communicator) error {
+	<-p.doneCh
+	return p.err
+}
+
+func (p *testBlockingProvisioner) Cancel() {
+	panic("in-process provisioners should not be cancelled directly")
+}
+
+func TestInProcessProvisioner_Implements(t *testing.T) {
+	var _ packer.Provisioner = new(inProcessProvisioner)
+}
+
+func TestInProcessProvisioner(t *testing.T) {
+	inner := &testBlockingProvisioner{doneCh: make(chan struct{})}
+	close(inner.doneCh)
+
+	p := &inProcessProvisioner{Provisioner: inner}
+	if err := p.Provision(nil, nil); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Cancelling after provisioning is done does nothing
+	p.Cancel()
+}
+
+func TestInProcessProvisioner_cancel(t *testing.T) {
+	inner := &testBlockingProvisioner{doneCh: make(chan struct{})}
+	defer close(inner.doneCh)
+
+	p := &inProcessProvisioner{Provisioner: inner}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.Provision(nil, nil)
+	}()
+
+	// Wait for provisioning to start
+	for {
+		p.l.Lock()
+		started := p.cancelCh != nil
+		p.l.Unlock()
+		if started {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	p.Cancel()
+	p.Cancel()
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("should error when cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("provision should return when cancelled")
+	}
+}
+
+func TestInProcessProvisioner_cancelLogsResult(t *testing.T) {
+	var buf syncBuffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	inner := &testBlockingProvisioner{
+		doneCh: make(chan struct{}),
+		err:    errors.New("connection lost"),
+	}
+
+	p := &inProcessProvisioner{Provisioner: inner}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.Provision(nil, nil)
+	}()
+
+	for {
+		p.l.Lock()
+		started := p.cancelCh != nil
+		p.l.Unlock()
+		if started {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	p.Cancel()
+	<-errCh
+
+	// The result of the provisioner is logged once it finishes
+	close(inner.doneCh)
+	timeout := time.After(5 * time.Second)
+	for !strings.Contains(buf.String(), "connection lost") {
+		select {
+		case <-timeout:
+			t.Fatalf("result should be logged: %s", buf.String())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be written to and read from
+// concurrently.
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
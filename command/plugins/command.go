@@ -0,0 +1,95 @@
+package plugins
+
+import (
+	"flag"
+	"fmt"
+	"github.com/mitchellh/packer/packer"
+	"sort"
+	"strings"
+)
+
+// Plugin is a single plugin that was discovered.
+type Plugin struct {
+	// Type is the type of component: builder, command, post-processor
+	// or provisioner.
+	Type string
+
+	// Name is the name the plugin is used by in templates or on the
+	// command line.
+	Name string
+
+	// Path is the path to the plugin binary.
+	Path string
+}
+
+// Command lists the discovered plugins. Since the plugins are discovered
+// by the packer executable itself, this command runs in that process
+// and is given the plugins directly.
+type Command struct {
+	Plugins []Plugin
+}
+
+func (Command) Help() string {
+	return strings.TrimSpace(helpText)
+}
+
+func (c Command) Synopsis() string {
+	return "list the plugins that were discovered"
+}
+
+func (c Command) Run(env packer.Environment, args []string) int {
+	flags := flag.NewFlagSet("plugins", flag.ContinueOnError)
+	flags.Usage = func() { env.Ui().Say(c.Help()) }
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+
+	if len(flags.Args()) != 0 {
+		flags.Usage()
+		return 1
+	}
+
+	ui := env.Ui()
+	if len(c.Plugins) == 0 {
+		ui.Say("No plugins were discovered.")
+		return 0
+	}
+
+	plugins := make([]Plugin, len(c.Plugins))
+	copy(plugins, c.Plugins)
+	sort.Sort(byTypeAndName(plugins))
+
+	maxType, maxName := 0, 0
+	for _, p := range plugins {
+		if len(p.Type) > maxType {
+			maxType = len(p.Type)
+		}
+
+		if len(p.Name) > maxName {
+			maxName = len(p.Name)
+		}
+	}
+
+	for _, p := range plugins {
+		ui.Machine("plugin", p.Type, p.Name, p.Path)
+		ui.Say(fmt.Sprintf(
+			"%s%s  %s%s  %s",
+			p.Type, strings.Repeat(" ", maxType-len(p.Type)),
+			p.Name, strings.Repeat(" ", maxName-len(p.Name)),
+			p.Path))
+	}
+
+	return 0
+}
+
+type byTypeAndName []Plugin
+
+func (p byTypeAndName) Len() int      { return len(p) }
+func (p byTypeAndName) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byTypeAndName) Less(i, j int) bool {
+	if p[i].Type != p[j].Type {
+		return p[i].Type < p[j].Type
+	}
+
+	return p[i].Name < p[j].Name
+}
@@ -0,0 +1,78 @@
+package plugins
+
+import (
+	"bytes"
+	"github.com/mitchellh/packer/packer"
+	"strings"
+	"testing"
+)
+
+func testEnvironment(out *bytes.Buffer) packer.Environment {
+	config := packer.DefaultEnvironmentConfig()
+	config.Ui = &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: out,
+	}
+
+	env, err := packer.NewEnvironment(config)
+	if err != nil {
+		panic(err)
+	}
+
+	return env
+}
+
+func TestCommand_Implements(t *testing.T) {
+	var _ packer.Command = new(Command)
+}
+
+func TestCommand_Run(t *testing.T) {
+	out := new(bytes.Buffer)
+	command := &Command{
+		Plugins: []Plugin{
+			{"provisioner", "foo", "/plugins/packer-provisioner-foo"},
+			{"builder", "foo", "/plugins/packer-builder-foo"},
+			{"builder", "bar", "/bin/packer-builder-bar"},
+		},
+	}
+
+	if result := command.Run(testEnvironment(out), nil); result != 0 {
+		t.Fatalf("bad: %d", result)
+	}
+
+	expected := []string{
+		"builder      bar  /bin/packer-builder-bar",
+		"builder      foo  /plugins/packer-builder-foo",
+		"provisioner  foo  /plugins/packer-provisioner-foo",
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("bad: %s", out.String())
+	}
+
+	for i, line := range lines {
+		if line != expected[i] {
+			t.Fatalf("bad line %d: %q", i, line)
+		}
+	}
+}
+
+func TestCommand_Run_NoPlugins(t *testing.T) {
+	out := new(bytes.Buffer)
+	command := new(Command)
+	if result := command.Run(testEnvironment(out), nil); result != 0 {
+		t.Fatalf("bad: %d", result)
+	}
+
+	if !strings.Contains(out.String(), "No plugins") {
+		t.Fatalf("bad: %s", out.String())
+	}
+}
+
+func TestCommand_Run_Args(t *testing.T) {
+	command := new(Command)
+	if result := command.Run(testEnvironment(new(bytes.Buffer)), []string{"foo"}); result != 1 {
+		t.Fatalf("bad: %d", result)
+	}
+}
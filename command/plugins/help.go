@@ -0,0 +1,15 @@
+package plugins
+
+const helpText = `
+Usage: packer plugins
+
+  Lists the plugins that Packer discovered automatically, along with
+  their type and the path they were found at.
+
+  Plugins are discovered by looking for binaries named
+  "packer-TYPE-NAME" in the directory of the packer executable, in
+  ~/.packer.d/plugins, and in the current directory, in that order.
+  A plugin found in a later directory overrides one with the same
+  name found earlier, and plugins set in the configuration file
+  override discovered plugins.
+`
@@ -3,53 +3,30 @@ package main
 import (
 	"encoding/json"
 	"github.com/mitchellh/osext"
+	"github.com/mitchellh/packer/command/plugins"
 	"github.com/mitchellh/packer/packer"
 	"github.com/mitchellh/packer/packer/plugin"
 	"io"
 	"log"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 )
 
 // This is the default, built-in configuration that ships with
-// Packer.
+// Packer. The components that ship with Packer are compiled in, see
+// builtin.go, so only plugins need to be listed in the configuration.
 const defaultConfig = `
 {
 	"plugin_min_port": 10000,
 	"plugin_max_port": 25000,
 
-	"builders": {
-		"amazon-ebs": "packer-builder-amazon-ebs",
-		"amazon-chroot": "packer-builder-amazon-chroot",
-		"amazon-instance": "packer-builder-amazon-instance",
-		"digitalocean": "packer-builder-digitalocean",
-		"docker": "packer-builder-docker",
-		"openstack": "packer-builder-openstack",
-		"qemu": "packer-builder-qemu",
-		"virtualbox": "packer-builder-virtualbox",
-		"vmware": "packer-builder-vmware"
-	},
-
-	"commands": {
-		"build": "packer-command-build",
-		"fix": "packer-command-fix",
-		"inspect": "packer-command-inspect",
-		"validate": "packer-command-validate"
-	},
-
-	"post-processors": {
-		"vagrant": "packer-post-processor-vagrant",
-		"vsphere": "packer-post-processor-vsphere"
-	},
-
-	"provisioners": {
-		"ansible-local": "packer-provisioner-ansible-local",
-		"chef-solo": "packer-provisioner-chef-solo",
-		"file": "packer-provisioner-file",
-		"puppet-masterless": "packer-provisioner-puppet-masterless",
-		"shell": "packer-provisioner-shell",
-		"salt-masterless": "packer-provisioner-salt-masterless"
-	}
+	"builders": {},
+	"commands": {},
+	"post-processors": {},
+	"provisioners": {}
 }
 `
 
@@ -61,6 +38,10 @@ type config struct {
 	Commands       map[string]string
 	PostProcessors map[string]string `json:"post-processors"`
 	Provisioners   map[string]string
+
+	// The plugins that were found by Discover, for listing with the
+	// "plugins" command.
+	discovered []plugins.Plugin
 }
 
 // Decodes configuration in JSON format from the given io.Reader into
@@ -70,12 +51,95 @@ func decodeConfig(r io.Reader, c *config) error {
 	return decoder.Decode(c)
 }
 
+// Discover finds plugins in the directory of the packer executable, the
+// plugins directory in the user's home directory and the current working
+// directory, in that order, and registers them. Plugins found later take
+// precedence over plugins with the same name found earlier.
+func (c *config) Discover() error {
+	// Plugins next to the executable that have the same name as a
+	// built-in component are left over from older versions of Packer,
+	// which shipped every component as a plugin, so they are skipped.
+	exePath, err := osext.Executable()
+	if err != nil {
+		log.Printf("Couldn't get current exe path for discovery: %s", err)
+	} else {
+		if err := c.discover(filepath.Dir(exePath), true); err != nil {
+			return err
+		}
+	}
+
+	pluginDir, err := pluginDir()
+	if err != nil {
+		log.Printf("Couldn't get plugin directory for discovery: %s", err)
+	} else {
+		if err := c.discover(pluginDir, false); err != nil {
+			return err
+		}
+	}
+
+	return c.discover(".", false)
+}
+
+// skipBuiltinPlugins drops the plugins set in the configuration that
+// are the plugin binaries of built-in components, such as "amazon-ebs" set
+// to "packer-builder-amazon-ebs". Configuration files written for older
+// versions of Packer, which shipped every component as a plugin, list
+// them, but the binaries are no longer built. A plugin discovered with
+// the same name is used instead if there is one, like the built-in
+// component otherwise.
+func (c *config) skipBuiltinPlugins() {
+	types := []struct {
+		Type string
+		Map  map[string]string
+	}{
+		{"builder", c.Builders},
+		{"command", c.Commands},
+		{"post-processor", c.PostProcessors},
+		{"provisioner", c.Provisioners},
+	}
+
+	for _, t := range types {
+		for name, bin := range t.Map {
+			if !isBuiltin(t.Type, name) {
+				continue
+			}
+
+			base := strings.TrimSuffix(bin, ".exe")
+			if base != "packer-"+t.Type+"-"+name {
+				continue
+			}
+
+			log.Printf(
+				"[WARN] Ignoring configured %s plugin %s = %s: it is built in",
+				t.Type, name, bin)
+			delete(t.Map, name)
+
+			for _, p := range c.discovered {
+				if p.Type == t.Type && p.Name == name {
+					t.Map[name] = p.Path
+				}
+			}
+		}
+	}
+}
+
 // Returns an array of defined command names.
 func (c *config) CommandNames() (result []string) {
-	result = make([]string, 0, len(c.Commands))
+	result = make([]string, 0, len(c.Commands)+len(builtinCommands)+1)
 	for name := range c.Commands {
 		result = append(result, name)
 	}
+
+	for name := range builtinCommands {
+		if _, ok := c.Commands[name]; !ok {
+			result = append(result, name)
+		}
+	}
+
+	if _, ok := c.Commands["plugins"]; !ok {
+		result = append(result, "plugins")
+	}
+
 	return
 }
 
@@ -85,6 +149,10 @@ func (c *config) LoadBuilder(name string) (packer.Builder, error) {
 	log.Printf("Loading builder: %s\n", name)
 	bin, ok := c.Builders[name]
 	if !ok {
+		if f, ok := builtinBuilders[name]; ok {
+			return f(), nil
+		}
+
 		log.Printf("Builder not found: %s\n", name)
 		return nil, nil
 	}
@@ -98,6 +166,16 @@ func (c *config) LoadCommand(name string) (packer.Command, error) {
 	log.Printf("Loading command: %s\n", name)
 	bin, ok := c.Commands[name]
 	if !ok {
+		// The plugins command lists what we discovered, so it runs
+		// here rather than as a plugin.
+		if name == "plugins" {
+			return &plugins.Command{Plugins: c.discovered}, nil
+		}
+
+		if f, ok := builtinCommands[name]; ok {
+			return f(), nil
+		}
+
 		log.Printf("Command not found: %s\n", name)
 		return nil, nil
 	}
@@ -118,6 +196,10 @@ func (c *config) LoadPostProcessor(name string) (packer.PostProcessor, error) {
 	log.Printf("Loading post-processor: %s", name)
 	bin, ok := c.PostProcessors[name]
 	if !ok {
+		if f, ok := builtinPostProcessors[name]; ok {
+			return f(), nil
+		}
+
 		log.Printf("Post-processor not found: %s", name)
 		return nil, nil
 	}
@@ -131,6 +213,10 @@ func (c *config) LoadProvisioner(name string) (packer.Provisioner, error) {
 	log.Printf("Loading provisioner: %s\n", name)
 	bin, ok := c.Provisioners[name]
 	if !ok {
+		if f, ok := builtinProvisioners[name]; ok {
+			return &inProcessProvisioner{Provisioner: f()}, nil
+		}
+
 		log.Printf("Provisioner not found: %s\n", name)
 		return nil, nil
 	}
@@ -170,3 +256,96 @@ func (c *config) pluginClient(path string) *plugin.Client {
 	config.MaxPort = c.PluginMaxPort
 	return plugin.NewClient(&config)
 }
+
+// discover registers all the plugins in a single directory. If skipBuiltin
+// is true, plugins with the same name as a built-in component are ignored.
+func (c *config) discover(dir string, skipBuiltin bool) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	types := []struct {
+		Type string
+		Map  *map[string]string
+	}{
+		{"builder", &c.Builders},
+		{"command", &c.Commands},
+		{"post-processor", &c.PostProcessors},
+		{"provisioner", &c.Provisioners},
+	}
+
+	for _, t := range types {
+		if err := c.discoverSingle(dir, t.Type, t.Map, skipBuiltin); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// discoverSingle registers the plugins of a single type in a directory,
+// which are binaries named "packer-TYPE-NAME".
+func (c *config) discoverSingle(dir, pluginType string, m *map[string]string, skipBuiltin bool) error {
+	prefix := "packer-" + pluginType + "-"
+	matches, err := filepath.Glob(filepath.Join(dir, prefix+"*"))
+	if err != nil {
+		return err
+	}
+
+	if *m == nil {
+		*m = make(map[string]string)
+	}
+
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		// On Windows executables have an extension, everywhere else they
+		// have to actually be executable.
+		name := strings.TrimPrefix(filepath.Base(match), prefix)
+		if runtime.GOOS == "windows" {
+			if filepath.Ext(name) != ".exe" {
+				continue
+			}
+
+			name = strings.TrimSuffix(name, ".exe")
+		} else if info.Mode()&0111 == 0 {
+			continue
+		}
+
+		if name == "" {
+			continue
+		}
+
+		if skipBuiltin && isBuiltin(pluginType, name) {
+			log.Printf("Ignoring %s plugin that is built in: %s", pluginType, match)
+			continue
+		}
+
+		log.Printf("Discovered %s plugin: %s = %s", pluginType, name, match)
+		(*m)[name] = match
+		c.addDiscovered(plugins.Plugin{
+			Type: pluginType,
+			Name: name,
+			Path: match,
+		})
+	}
+
+	return nil
+}
+
+// addDiscovered records a discovered plugin, replacing any plugin of the
+// same type and name discovered before it.
+func (c *config) addDiscovered(p plugins.Plugin) {
+	for i, existing := range c.discovered {
+		if existing.Type == p.Type && existing.Name == p.Name {
+			c.discovered[i] = p
+			return
+		}
+	}
+
+	c.discovered = append(c.discovered, p)
+}
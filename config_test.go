@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func testPluginDir(t *testing.T, names ...string) string {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, name := range names {
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	return dir
+}
+
+func TestConfigDiscover(t *testing.T) {
+	dir := testPluginDir(t,
+		"packer-builder-foo",
+		"packer-command-bar",
+		"packer-post-processor-baz",
+		"packer-provisioner-foo",
+		"packer-unknown-foo")
+	defer os.RemoveAll(dir)
+
+	// Directories are ignored
+	if err := os.Mkdir(filepath.Join(dir, "packer-builder-dir"), 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var c config
+	if err := c.discover(dir, false); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	expected := map[string]map[string]string{
+		"builder":        {"foo": "packer-builder-foo"},
+		"command":        {"bar": "packer-command-bar"},
+		"post-processor": {"baz": "packer-post-processor-baz"},
+		"provisioner":    {"foo": "packer-provisioner-foo"},
+	}
+
+	actual := map[string]map[string]string{
+		"builder":        c.Builders,
+		"command":        c.Commands,
+		"post-processor": c.PostProcessors,
+		"provisioner":    c.Provisioners,
+	}
+
+	for pluginType, plugins := range expected {
+		if len(actual[pluginType]) != len(plugins) {
+			t.Fatalf("bad %s: %#v", pluginType, actual[pluginType])
+		}
+
+		for name, file := range plugins {
+			path := actual[pluginType][name]
+			if filepath.Dir(path) != dir {
+				t.Fatalf("bad %s %s: %s", pluginType, name, path)
+			}
+
+			if filepath.Base(path) != file && filepath.Base(path) != file+".exe" {
+				t.Fatalf("bad %s %s: %s", pluginType, name, path)
+			}
+		}
+	}
+
+	if len(c.discovered) != 4 {
+		t.Fatalf("bad: %#v", c.discovered)
+	}
+}
+
+func TestConfigDiscover_override(t *testing.T) {
+	dir1 := testPluginDir(t, "packer-builder-foo")
+	defer os.RemoveAll(dir1)
+	dir2 := testPluginDir(t, "packer-builder-foo")
+	defer os.RemoveAll(dir2)
+
+	var c config
+	if err := c.discover(dir1, false); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := c.discover(dir2, false); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if filepath.Dir(c.Builders["foo"]) != dir2 {
+		t.Fatalf("bad: %s", c.Builders["foo"])
+	}
+
+	if len(c.discovered) != 1 || filepath.Dir(c.discovered[0].Path) != dir2 {
+		t.Fatalf("bad: %#v", c.discovered)
+	}
+}
+
+func TestConfigDiscover_notExecutable(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("executable bits aren't used on Windows")
+	}
+
+	dir := testPluginDir(t)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "packer-builder-foo")
+	if err := ioutil.WriteFile(path, []byte("foo"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var c config
+	if err := c.discover(dir, false); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if len(c.Builders) != 0 {
+		t.Fatalf("bad: %#v", c.Builders)
+	}
+}
+
+func TestConfigCommandNames_plugins(t *testing.T) {
+	c := &config{Commands: map[string]string{"build": "packer-command-build"}}
+
+	found := false
+	for _, name := range c.CommandNames() {
+		if name == "plugins" {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Fatal("plugins command should be listed")
+	}
+
+	command, err := c.LoadCommand("plugins")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if command == nil {
+		t.Fatal("should load the plugins command")
+	}
+}
+
+func TestConfigDiscover_skipBuiltin(t *testing.T) {
+	dir := testPluginDir(t, "packer-builder-amazon-ebs", "packer-builder-foo")
+	defer os.RemoveAll(dir)
+
+	var c config
+	if err := c.discover(dir, true); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, ok := c.Builders["amazon-ebs"]; ok {
+		t.Fatal("built-in builder should be skipped")
+	}
+
+	if _, ok := c.Builders["foo"]; !ok {
+		t.Fatal("foo builder should be discovered")
+	}
+}
+
+func TestConfigSkipBuiltinPlugins(t *testing.T) {
+	dir := testPluginDir(t, "packer-provisioner-shell")
+	defer os.RemoveAll(dir)
+
+	var c config
+	if err := c.discover(dir, false); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A configuration file from an older version of Packer
+	err := decodeConfig(bytes.NewBufferString(`{
+		"builders": {
+			"amazon-ebs": "packer-builder-amazon-ebs",
+			"virtualbox": "/opt/packer/my-virtualbox",
+			"foo": "packer-builder-foo"
+		},
+		"provisioners": {
+			"shell": "packer-provisioner-shell"
+		}
+	}`), &c)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c.skipBuiltinPlugins()
+
+	if _, ok := c.Builders["amazon-ebs"]; ok {
+		t.Fatal("built-in builder binary should be skipped")
+	}
+
+	// Plugins that replace built-in components on purpose are kept
+	if c.Builders["virtualbox"] != "/opt/packer/my-virtualbox" {
+		t.Fatalf("bad: %#v", c.Builders)
+	}
+
+	if c.Builders["foo"] != "packer-builder-foo" {
+		t.Fatalf("bad: %#v", c.Builders)
+	}
+
+	// The discovered plugin is used rather than the configured binary
+	expected := filepath.Join(dir, "packer-provisioner-shell")
+	if runtime.GOOS == "windows" {
+		expected += ".exe"
+	}
+	if c.Provisioners["shell"] != expected {
+		t.Fatalf("bad: %#v", c.Provisioners)
+	}
+
+	b, err := c.LoadBuilder("amazon-ebs")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if b == nil {
+		t.Fatal("builder should be built in")
+	}
+}
+
+func TestConfigLoadBuilder_builtin(t *testing.T) {
+	var c config
+	if err := decodeConfig(bytes.NewBufferString(defaultConfig), &c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for name := range builtinBuilders {
+		b, err := c.LoadBuilder(name)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		if b == nil {
+			t.Fatalf("builder should be built in: %s", name)
+		}
+	}
+
+	b, err := c.LoadBuilder("unknown")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if b != nil {
+		t.Fatal("unknown builder should not be found")
+	}
+}
+
+func TestConfigLoadProvisioner_builtin(t *testing.T) {
+	var c config
+	p, err := c.LoadProvisioner("shell")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, ok := p.(*inProcessProvisioner); !ok {
+		t.Fatalf("bad: %#v", p)
+	}
+}
+
+func TestConfigCommandNames_builtin(t *testing.T) {
+	var c config
+	names := make(map[string]bool)
+	for _, name := range c.CommandNames() {
+		if names[name] {
+			t.Fatalf("duplicate command: %s", name)
+		}
+
+		names[name] = true
+	}
+
+	for name := range builtinCommands {
+		if !names[name] {
+			t.Fatalf("command should be listed: %s", name)
+		}
+	}
+}
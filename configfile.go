@@ -7,3 +7,12 @@ package main
 func ConfigFile() (string, error) {
 	return configFile()
 }
+
+// PluginDir returns the directory that plugins are automatically
+// discovered in, along with the directory of the packer executable and
+// the current directory. On Unix-like systems this is ".packer.d/plugins"
+// in the home directory. On Windows, this is "packer.d/plugins" in the
+// application data directory.
+func PluginDir() (string, error) {
+	return pluginDir()
+}
@@ -21,6 +21,15 @@ func configFile() (string, error) {
 	return filepath.Join(dir, ".packerconfig"), nil
 }
 
+func pluginDir() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, ".packer.d", "plugins"), nil
+}
+
 func configDir() (string, error) {
 	// First prefer the HOME environmental variable
 	if home := os.Getenv("HOME"); home != "" {
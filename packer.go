@@ -175,6 +175,12 @@ func loadConfig() (*config, error) {
 		return nil, err
 	}
 
+	// Discover plugins before reading the config file so that plugins set
+	// explicitly in the config file take precedence.
+	if err := config.Discover(); err != nil {
+		return nil, err
+	}
+
 	mustExist := true
 	configFilePath := os.Getenv("PACKER_CONFIG")
 	if configFilePath == "" {
@@ -211,6 +217,7 @@ func loadConfig() (*config, error) {
 		return nil, err
 	}
 
+	config.skipBuiltinPlugins()
 	return &config, nil
 }
 
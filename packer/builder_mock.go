@@ -11,6 +11,7 @@ type MockBuilder struct {
 	ArtifactId      string
 	PrepareWarnings []string
 	RunErrResult    bool
+	RunFunc         func() error
 	RunNilResult    bool
 
 	PrepareCalled bool
@@ -34,6 +35,12 @@ func (tb *MockBuilder) Run(ui Ui, h Hook, c Cache) (Artifact, error) {
 	tb.RunUi = ui
 	tb.RunCache = c
 
+	if tb.RunFunc != nil {
+		if err := tb.RunFunc(); err != nil {
+			return nil, err
+		}
+	}
+
 	if tb.RunErrResult {
 		return nil, errors.New("foo")
 	}
@@ -10,7 +10,6 @@ import (
 	"io/ioutil"
 	"log"
 	"net"
-	"net/rpc"
 	"os"
 	"os/exec"
 	"strings"
@@ -35,7 +34,9 @@ type Client struct {
 	exited      bool
 	doneLogging chan struct{}
 	l           sync.Mutex
-	address     string
+	address     net.Addr
+	apiVersion  string
+	client      *packrpc.Client
 }
 
 // ClientConfig is the configuration used to initialize a new
@@ -53,7 +54,8 @@ type ClientConfig struct {
 	Managed bool
 
 	// The minimum and maximum port to use for communicating with
-	// the subprocess. If not set, this defaults to 10,000 and 25,000
+	// the subprocess on platforms where it listens over TCP rather than
+	// a Unix domain socket. If not set, this defaults to 10,000 and 25,000
 	// respectively.
 	MinPort, MaxPort uint
 
@@ -202,16 +204,17 @@ func (c *Client) Kill() {
 }
 
 // Starts the underlying subprocess, communicating with it to negotiate
-// a port for RPC connections, and returning the address to connect via RPC.
+// an address for the RPC connection, and returning the address to connect
+// via RPC.
 //
 // This method is safe to call multiple times. Subsequent calls have no effect.
 // Once a client has been started once, it cannot be started again, even if
 // it was killed.
-func (c *Client) Start() (address string, err error) {
+func (c *Client) Start() (addr net.Addr, err error) {
 	c.l.Lock()
 	defer c.l.Unlock()
 
-	if c.address != "" {
+	if c.address != nil {
 		return c.address, nil
 	}
 
@@ -219,6 +222,7 @@ func (c *Client) Start() (address string, err error) {
 
 	env := []string{
 		fmt.Sprintf("%s=%s", MagicCookieKey, MagicCookieValue),
+		fmt.Sprintf("%s=%s", APIVersionKey, APIVersion),
 		fmt.Sprintf("PACKER_PLUGIN_MIN_PORT=%d", c.config.MinPort),
 		fmt.Sprintf("PACKER_PLUGIN_MAX_PORT=%d", c.config.MaxPort),
 	}
@@ -321,26 +325,62 @@ func (c *Client) Start() (address string, err error) {
 		// Trim the line and split by "|" in order to get the parts of
 		// the output.
 		line := strings.TrimSpace(string(lineBytes))
-		parts := strings.SplitN(line, "|", 2)
+		parts := strings.SplitN(line, "|", 3)
 		if len(parts) < 2 {
-			err = fmt.Errorf("Unrecognized remote plugin message: %s", line)
+			err = fmt.Errorf(
+				"Unrecognized handshake from plugin %s: %q\n\n"+
+					"This is usually because the binary isn't a Packer plugin.",
+				cmd.Path, line)
 			return
 		}
 
-		// Test the API version
+		// Test the API version first, since the format of the rest of
+		// the line depends on it. Plugins built against an older Packer
+		// would otherwise fail later with obscure RPC errors.
 		if parts[0] != APIVersion {
-			err = fmt.Errorf("Incompatible API version with plugin. "+
-				"Plugin version: %s, Ours: %s", parts[0], APIVersion)
+			err = fmt.Errorf(
+				"Incompatible API version with plugin %s. "+
+					"Plugin version: %s, Packer version: %s\n\n"+
+					"The plugin must be rebuilt against a version of Packer "+
+					"that speaks API version %s.",
+				cmd.Path, parts[0], APIVersion, APIVersion)
 			return
 		}
 
-		c.address = parts[1]
-		address = c.address
+		if len(parts) < 3 {
+			err = fmt.Errorf(
+				"Unrecognized handshake from plugin %s: %q", cmd.Path, line)
+			return
+		}
+
+		switch parts[1] {
+		case "tcp":
+			addr, err = net.ResolveTCPAddr("tcp", parts[2])
+		case "unix":
+			addr, err = net.ResolveUnixAddr("unix", parts[2])
+		default:
+			err = fmt.Errorf("Unknown address type: %s", parts[1])
+		}
+
+		if err != nil {
+			return
+		}
+
+		c.address = addr
+		c.apiVersion = parts[0]
 	}
 
 	return
 }
 
+// APIVersion returns the plugin API version negotiated with the plugin
+// during the handshake. This is empty until the client has been started.
+func (c *Client) APIVersion() string {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.apiVersion
+}
+
 func (c *Client) logStderr(r io.Reader) {
 	bufR := bufio.NewReader(r)
 	for {
@@ -361,20 +401,36 @@ func (c *Client) logStderr(r io.Reader) {
 	close(c.doneLogging)
 }
 
-func (c *Client) rpcClient() (*rpc.Client, error) {
-	address, err := c.Start()
+func (c *Client) rpcClient() (*packrpc.Client, error) {
+	addr, err := c.Start()
 	if err != nil {
 		return nil, err
 	}
 
-	conn, err := net.Dial("tcp", address)
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	// The plugin only accepts a single connection, so all of the
+	// components retrieved from it share the same RPC client.
+	if c.client != nil {
+		return c.client, nil
+	}
+
+	conn, err := net.Dial(addr.Network(), addr.String())
 	if err != nil {
 		return nil, err
 	}
 
-	// Make sure to set keep alive so that the connection doesn't die
-	tcpConn := conn.(*net.TCPConn)
-	tcpConn.SetKeepAlive(true)
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		// Make sure to set keep alive so that the connection doesn't die
+		tcpConn.SetKeepAlive(true)
+	}
+
+	c.client, err = packrpc.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
 
-	return rpc.NewClient(tcpConn), nil
+	return c.client, nil
 }
@@ -20,10 +20,14 @@ func TestClient(t *testing.T) {
 		t.Fatalf("err should be nil, got %s", err)
 	}
 
-	if addr != ":1234" {
+	if addr.String() != ":1234" {
 		t.Fatalf("incorrect addr %s", addr)
 	}
 
+	if c.APIVersion() != APIVersion {
+		t.Fatalf("bad: %s", c.APIVersion())
+	}
+
 	// Test that it exits properly if killed
 	c.Kill()
 
@@ -50,6 +54,52 @@ func TestClientStart_badVersion(t *testing.T) {
 	if err == nil {
 		t.Fatal("err should not be nil")
 	}
+
+	// The error should name the plugin and both versions
+	for _, v := range []string{config.Cmd.Path, APIVersion + "1", "Packer version: " + APIVersion} {
+		if !strings.Contains(err.Error(), v) {
+			t.Fatalf("error should contain %q: %s", v, err)
+		}
+	}
+
+	if c.APIVersion() != "" {
+		t.Fatalf("bad: %s", c.APIVersion())
+	}
+}
+
+func TestClientStart_oldHandshake(t *testing.T) {
+	config := &ClientConfig{
+		Cmd:          helperProcess("old-handshake"),
+		StartTimeout: 50 * time.Millisecond,
+	}
+
+	c := NewClient(config)
+	defer c.Kill()
+
+	_, err := c.Start()
+	if err == nil {
+		t.Fatal("err should not be nil")
+	}
+
+	if !strings.Contains(err.Error(), "Plugin version: 1,") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestClientStart_apiVersionEnv(t *testing.T) {
+	process := helperProcess("api-version")
+	c := NewClient(&ClientConfig{Cmd: process})
+	defer c.Kill()
+
+	// The helper echoes the version we tell it we speak, so this only
+	// succeeds if the version was passed to the plugin.
+	if _, err := c.Start(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if c.APIVersion() != APIVersion {
+		t.Fatalf("bad: %s", c.APIVersion())
+	}
 }
 
 func TestClient_Start_Timeout(t *testing.T) {
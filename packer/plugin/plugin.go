@@ -12,9 +12,9 @@ import (
 	"fmt"
 	"github.com/mitchellh/packer/packer"
 	packrpc "github.com/mitchellh/packer/packer/rpc"
+	"io/ioutil"
 	"log"
 	"net"
-	"net/rpc"
 	"os"
 	"os/signal"
 	"runtime"
@@ -33,15 +33,25 @@ const MagicCookieValue = "d602bf8f470bc67ca7faa0386276bbdd4330efaf76d1a219cb4d69
 // The APIVersion is outputted along with the RPC address. The plugin
 // client validates this API version and will show an error if it doesn't
 // know how to speak it.
-const APIVersion = "1"
+const APIVersion = "2"
 
-// This serves a single RPC connection on the given RPC server on
-// a random port.
-func serve(server *rpc.Server) (err error) {
+// APIVersionKey is the environment variable the plugin client uses to
+// tell the plugin which API version it speaks.
+const APIVersionKey = "PACKER_PLUGIN_API_VERSION"
+
+// The API version negotiated with the Packer process that started this
+// plugin. This is set once the plugin starts serving.
+var negotiatedAPIVersion string
+
+// This waits for the Packer client to connect and returns an RPC server
+// to serve on that single connection. All RPC traffic with the plugin,
+// including streams such as remote command output, is multiplexed over
+// this connection.
+func serve() (*packrpc.Server, error) {
 	log.Printf("Plugin build against Packer '%s'", packer.GitCommit)
 
 	if os.Getenv(MagicCookieKey) != MagicCookieValue {
-		return errors.New("Please do not execute plugins directly. Packer will execute these for you.")
+		return nil, errors.New("Please do not execute plugins directly. Packer will execute these for you.")
 	}
 
 	// If there is no explicit number of Go threads to use, then set it
@@ -49,54 +59,95 @@ func serve(server *rpc.Server) (err error) {
 		runtime.GOMAXPROCS(runtime.NumCPU())
 	}
 
+	// Check the version Packer speaks. If it doesn't match, we still
+	// output our version below so that Packer can show a proper error
+	// naming both versions.
+	clientVersion := os.Getenv(APIVersionKey)
+	if clientVersion == APIVersion {
+		negotiatedAPIVersion = APIVersion
+	} else if clientVersion != "" {
+		log.Printf("Incompatible API version with Packer. "+
+			"Packer version: %s, Plugin version: %s", clientVersion, APIVersion)
+	}
+
+	listener, err := serverListener()
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	// Output the address to stdout
+	log.Printf("Plugin address: %s %s\n",
+		listener.Addr().Network(), listener.Addr().String())
+	fmt.Printf("%s|%s|%s\n",
+		APIVersion,
+		listener.Addr().Network(),
+		listener.Addr().String())
+	os.Stdout.Sync()
+
+	// Accept a connection
+	log.Println("Waiting for connection...")
+	conn, err := listener.Accept()
+	if err != nil {
+		log.Printf("Error accepting connection: %s\n", err.Error())
+		return nil, err
+	}
+
+	// Serve a single connection
+	log.Println("Serving a plugin connection...")
+	return packrpc.NewServer(conn), nil
+}
+
+func serverListener() (net.Listener, error) {
+	if runtime.GOOS == "windows" {
+		return serverListenerTCP()
+	}
+
+	return serverListenerUnix()
+}
+
+func serverListenerTCP() (net.Listener, error) {
 	minPort, err := strconv.ParseInt(os.Getenv("PACKER_PLUGIN_MIN_PORT"), 10, 32)
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	maxPort, err := strconv.ParseInt(os.Getenv("PACKER_PLUGIN_MAX_PORT"), 10, 32)
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	log.Printf("Plugin minimum port: %d\n", minPort)
 	log.Printf("Plugin maximum port: %d\n", maxPort)
 
-	// Set the RPC port range
-	packrpc.PortRange(int(minPort), int(maxPort))
-
-	var address string
-	var listener net.Listener
 	for port := minPort; port <= maxPort; port++ {
-		address = fmt.Sprintf("127.0.0.1:%d", port)
-		listener, err = net.Listen("tcp", address)
-		if err != nil {
-			err = nil
-			continue
+		address := fmt.Sprintf("127.0.0.1:%d", port)
+		listener, err := net.Listen("tcp", address)
+		if err == nil {
+			return listener, nil
 		}
-
-		break
 	}
 
-	defer listener.Close()
-
-	// Output the address to stdout
-	log.Printf("Plugin address: %s\n", address)
-	fmt.Printf("%s|%s\n", APIVersion, address)
-	os.Stdout.Sync()
+	return nil, errors.New("Couldn't bind plugin TCP listener")
+}
 
-	// Accept a connection
-	log.Println("Waiting for connection...")
-	conn, err := listener.Accept()
+func serverListenerUnix() (net.Listener, error) {
+	tf, err := ioutil.TempFile("", "packer-plugin")
 	if err != nil {
-		log.Printf("Error accepting connection: %s\n", err.Error())
-		return
+		return nil, err
 	}
+	path := tf.Name()
 
-	// Serve a single connection
-	log.Println("Serving a plugin connection...")
-	server.ServeConn(conn)
-	return
+	// Close the file and remove it because it has to not exist for
+	// the domain socket.
+	if err := tf.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+
+	return net.Listen("unix", path)
 }
 
 // Registers a signal handler to swallow and count interrupts so that the
@@ -119,70 +170,87 @@ func countInterrupts() {
 func ServeBuilder(builder packer.Builder) {
 	log.Println("Preparing to serve a builder plugin...")
 
-	server := rpc.NewServer()
-	packrpc.RegisterBuilder(server, builder)
-
 	countInterrupts()
-	if err := serve(server); err != nil {
+	server, err := serve()
+	if err != nil {
 		log.Printf("ERROR: %s", err)
 		os.Exit(1)
 	}
+	defer server.Close()
+
+	packrpc.RegisterBuilder(server, builder)
+	server.Serve()
 }
 
 // Serves a command from a plugin.
 func ServeCommand(command packer.Command) {
 	log.Println("Preparing to serve a command plugin...")
 
-	server := rpc.NewServer()
-	packrpc.RegisterCommand(server, command)
-
 	countInterrupts()
-	if err := serve(server); err != nil {
+	server, err := serve()
+	if err != nil {
 		log.Printf("ERROR: %s", err)
 		os.Exit(1)
 	}
+	defer server.Close()
+
+	packrpc.RegisterCommand(server, command)
+	server.Serve()
 }
 
 // Serves a hook from a plugin.
 func ServeHook(hook packer.Hook) {
 	log.Println("Preparing to serve a hook plugin...")
 
-	server := rpc.NewServer()
-	packrpc.RegisterHook(server, hook)
-
 	countInterrupts()
-	if err := serve(server); err != nil {
+	server, err := serve()
+	if err != nil {
 		log.Printf("ERROR: %s", err)
 		os.Exit(1)
 	}
+	defer server.Close()
+
+	packrpc.RegisterHook(server, hook)
+	server.Serve()
 }
 
 // Serves a post-processor from a plugin.
 func ServePostProcessor(p packer.PostProcessor) {
 	log.Println("Preparing to serve a post-processor plugin...")
 
-	server := rpc.NewServer()
-	packrpc.RegisterPostProcessor(server, p)
-
 	countInterrupts()
-	if err := serve(server); err != nil {
+	server, err := serve()
+	if err != nil {
 		log.Printf("ERROR: %s", err)
 		os.Exit(1)
 	}
+	defer server.Close()
+
+	packrpc.RegisterPostProcessor(server, p)
+	server.Serve()
 }
 
 // Serves a provisioner from a plugin.
 func ServeProvisioner(p packer.Provisioner) {
 	log.Println("Preparing to serve a provisioner plugin...")
 
-	server := rpc.NewServer()
-	packrpc.RegisterProvisioner(server, p)
-
 	countInterrupts()
-	if err := serve(server); err != nil {
+	server, err := serve()
+	if err != nil {
 		log.Printf("ERROR: %s", err)
 		os.Exit(1)
 	}
+	defer server.Close()
+
+	packrpc.RegisterProvisioner(server, p)
+	server.Serve()
+}
+
+// NegotiatedAPIVersion returns the plugin API version that this plugin and
+// the Packer process that started it agreed on. This is empty if the
+// plugin isn't being served or Packer speaks an incompatible version.
+func NegotiatedAPIVersion() string {
+	return negotiatedAPIVersion
 }
 
 // Tests whether or not the plugin was interrupted or not.
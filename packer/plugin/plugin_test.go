@@ -50,8 +50,12 @@ func TestHelperProcess(*testing.T) {
 
 	cmd, args := args[0], args[1:]
 	switch cmd {
+	case "api-version":
+		// Echo back the version Packer told us it speaks
+		fmt.Printf("%s|tcp|:1234\n", os.Getenv(APIVersionKey))
+		<-make(chan int)
 	case "bad-version":
-		fmt.Printf("%s1|:1234\n", APIVersion)
+		fmt.Printf("%s1|tcp|:1234\n", APIVersion)
 		<-make(chan int)
 	case "builder":
 		ServeBuilder(new(packer.MockBuilder))
@@ -61,8 +65,11 @@ func TestHelperProcess(*testing.T) {
 		ServeHook(new(packer.MockHook))
 	case "invalid-rpc-address":
 		fmt.Println("lolinvalid")
+	case "old-handshake":
+		fmt.Println("1|127.0.0.1:1234")
+		<-make(chan int)
 	case "mock":
-		fmt.Printf("%s|:1234\n", APIVersion)
+		fmt.Printf("%s|tcp|:1234\n", APIVersion)
 		<-make(chan int)
 	case "post-processor":
 		ServePostProcessor(new(helperPostProcessor))
@@ -72,11 +79,11 @@ func TestHelperProcess(*testing.T) {
 		time.Sleep(1 * time.Minute)
 		os.Exit(1)
 	case "stderr":
-		fmt.Printf("%s|:1234\n", APIVersion)
+		fmt.Printf("%s|tcp|:1234\n", APIVersion)
 		log.Println("HELLO")
 		log.Println("WORLD")
 	case "stdin":
-		fmt.Printf("%s|:1234\n", APIVersion)
+		fmt.Printf("%s|tcp|:1234\n", APIVersion)
 		data := make([]byte, 5)
 		if _, err := os.Stdin.Read(data); err != nil {
 			log.Printf("stdin read error: %s", err)
@@ -1,14 +1,11 @@
 package rpc
 
-import (
-	"github.com/mitchellh/packer/packer"
-	"net/rpc"
-)
+import "github.com/mitchellh/packer/packer"
 
 // An implementation of packer.Artifact where the artifact is actually
 // available over an RPC connection.
 type artifact struct {
-	client *rpc.Client
+	client *Client
 }
 
 // ArtifactServer wraps a packer.Artifact implementation and makes it
@@ -17,7 +14,7 @@ type ArtifactServer struct {
 	artifact packer.Artifact
 }
 
-func Artifact(client *rpc.Client) *artifact {
+func Artifact(client *Client) *artifact {
 	return &artifact{client}
 }
 
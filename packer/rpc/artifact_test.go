@@ -2,7 +2,6 @@ package rpc
 
 import (
 	"github.com/mitchellh/packer/packer"
-	"net/rpc"
 	"reflect"
 	"testing"
 )
@@ -33,16 +32,8 @@ func TestArtifactRPC(t *testing.T) {
 	// Create the interface to test
 	a := new(testArtifact)
 
-	// Start the server
-	server := rpc.NewServer()
+	client, server := testClientServer(t)
 	RegisterArtifact(server, a)
-	address := serveSingleConn(server)
-
-	// Create the client over RPC and run some methods to verify it works
-	client, err := rpc.Dial("tcp", address)
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
 	aClient := Artifact(client)
 
 	// Test
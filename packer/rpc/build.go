@@ -1,24 +1,22 @@
 package rpc
 
-import (
-	"github.com/mitchellh/packer/packer"
-	"net/rpc"
-)
+import "github.com/mitchellh/packer/packer"
 
 // An implementation of packer.Build where the build is actually executed
 // over an RPC connection.
 type build struct {
-	client *rpc.Client
+	client *Client
 }
 
 // BuildServer wraps a packer.Build implementation and makes it exportable
 // as part of a Golang RPC server.
 type BuildServer struct {
 	build packer.Build
+	mux   *MuxConn
 }
 
 type BuildRunArgs struct {
-	UiRPCAddress string
+	UiStreamId uint32
 }
 
 type BuildPrepareResponse struct {
@@ -26,7 +24,7 @@ type BuildPrepareResponse struct {
 	Error    error
 }
 
-func Build(client *rpc.Client) *build {
+func Build(client *Client) *build {
 	return &build{client}
 }
 
@@ -46,19 +44,21 @@ func (b *build) Prepare(v map[string]string) ([]string, error) {
 
 func (b *build) Run(ui packer.Ui, cache packer.Cache) ([]packer.Artifact, error) {
 	// Create and start the server for the UI
-	server := rpc.NewServer()
+	streamId := b.client.mux.NextId()
+	server := newServerWithMux(b.client.mux, streamId)
 	RegisterCache(server, cache)
 	RegisterUi(server, ui)
-	args := &BuildRunArgs{serveSingleConn(server)}
+	go server.Serve()
+	defer server.Close()
 
-	var result []string
-	if err := b.client.Call("Build.Run", args, &result); err != nil {
+	var result []uint32
+	if err := b.client.Call("Build.Run", &BuildRunArgs{streamId}, &result); err != nil {
 		return nil, err
 	}
 
 	artifacts := make([]packer.Artifact, len(result))
-	for i, addr := range result {
-		client, err := rpcDial(addr)
+	for i, streamId := range result {
+		client, err := newClientWithMux(b.client.mux, streamId)
 		if err != nil {
 			return nil, err
 		}
@@ -101,22 +101,26 @@ func (b *BuildServer) Prepare(v map[string]string, resp *BuildPrepareResponse) e
 	return nil
 }
 
-func (b *BuildServer) Run(args *BuildRunArgs, reply *[]string) error {
-	client, err := rpcDial(args.UiRPCAddress)
+func (b *BuildServer) Run(args *BuildRunArgs, reply *[]uint32) error {
+	client, err := newClientWithMux(b.mux, args.UiStreamId)
 	if err != nil {
-		return err
+		return NewBasicError(err)
 	}
+	defer client.Close()
 
 	artifacts, err := b.build.Run(&Ui{client}, Cache(client))
 	if err != nil {
 		return NewBasicError(err)
 	}
 
-	*reply = make([]string, len(artifacts))
+	*reply = make([]uint32, len(artifacts))
 	for i, artifact := range artifacts {
-		server := rpc.NewServer()
+		streamId := b.mux.NextId()
+		server := newServerWithMux(b.mux, streamId)
 		RegisterArtifact(server, artifact)
-		(*reply)[i] = serveSingleConn(server)
+		go server.Serve()
+
+		(*reply)[i] = streamId
 	}
 
 	return nil
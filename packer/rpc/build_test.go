@@ -3,7 +3,6 @@ package rpc
 import (
 	"errors"
 	"github.com/mitchellh/packer/packer"
-	"net/rpc"
 	"reflect"
 	"testing"
 )
@@ -41,6 +40,10 @@ func (b *testBuild) Run(ui packer.Ui, cache packer.Cache) ([]packer.Artifact, er
 	b.runCache = cache
 	b.runUi = ui
 
+	// The cache and UI are only usable during the run
+	cache.Lock("foo")
+	ui.Say("format")
+
 	if b.errRunResult {
 		return nil, errors.New("foo")
 	} else {
@@ -64,16 +67,8 @@ func buildRPCClient(t *testing.T) (*testBuild, packer.Build) {
 	// Create the interface to test
 	b := new(testBuild)
 
-	// Start the server
-	server := rpc.NewServer()
+	client, server := testClientServer(t)
 	RegisterBuild(server, b)
-	address := serveSingleConn(server)
-
-	// Create the client over RPC and run some methods to verify it works
-	client, err := rpc.Dial("tcp", address)
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
 	return b, Build(client)
 }
 
@@ -122,12 +117,10 @@ func TestBuild(t *testing.T) {
 
 	// Test the UI given to run, which should be fully functional
 	if b.runCalled {
-		b.runCache.Lock("foo")
 		if !cache.lockCalled {
 			t.Fatal("lock shuld be called")
 		}
 
-		b.runUi.Say("format")
 		if !ui.sayCalled {
 			t.Fatal("say should be called")
 		}
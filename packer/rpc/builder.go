@@ -1,23 +1,21 @@
 package rpc
 
 import (
-	"encoding/gob"
-	"fmt"
 	"github.com/mitchellh/packer/packer"
 	"log"
-	"net/rpc"
 )
 
 // An implementation of packer.Builder where the builder is actually executed
 // over an RPC connection.
 type builder struct {
-	client *rpc.Client
+	client *Client
 }
 
 // BuilderServer wraps a packer.Builder implementation and makes it exportable
 // as part of a Golang RPC server.
 type BuilderServer struct {
 	builder packer.Builder
+	mux     *MuxConn
 }
 
 type BuilderPrepareArgs struct {
@@ -25,8 +23,7 @@ type BuilderPrepareArgs struct {
 }
 
 type BuilderRunArgs struct {
-	RPCAddress      string
-	ResponseAddress string
+	StreamId uint32
 }
 
 type BuilderPrepareResponse struct {
@@ -35,11 +32,11 @@ type BuilderPrepareResponse struct {
 }
 
 type BuilderRunResponse struct {
-	Err        error
-	RPCAddress string
+	Err      error
+	StreamId uint32
 }
 
-func Builder(client *rpc.Client) *builder {
+func Builder(client *Client) *builder {
 	return &builder{client}
 }
 
@@ -55,52 +52,28 @@ func (b *builder) Prepare(config ...interface{}) ([]string, error) {
 
 func (b *builder) Run(ui packer.Ui, hook packer.Hook, cache packer.Cache) (packer.Artifact, error) {
 	// Create and start the server for the Build and UI
-	server := rpc.NewServer()
+	streamId := b.client.mux.NextId()
+	server := newServerWithMux(b.client.mux, streamId)
 	RegisterCache(server, cache)
 	RegisterHook(server, hook)
 	RegisterUi(server, ui)
+	go server.Serve()
+	defer server.Close()
 
-	// Create a server for the response
-	responseL := netListenerInRange(portRangeMin, portRangeMax)
-	runResponseCh := make(chan *BuilderRunResponse)
-	go func() {
-		defer responseL.Close()
-
-		var response BuilderRunResponse
-		defer func() { runResponseCh <- &response }()
-
-		conn, err := responseL.Accept()
-		if err != nil {
-			response.Err = err
-			return
-		}
-		defer conn.Close()
-
-		decoder := gob.NewDecoder(conn)
-		if err := decoder.Decode(&response); err != nil {
-			response.Err = fmt.Errorf("Error waiting for Run: %s", err)
-		}
-	}()
-
-	args := &BuilderRunArgs{
-		serveSingleConn(server),
-		responseL.Addr().String(),
-	}
-
-	if err := b.client.Call("Builder.Run", args, new(interface{})); err != nil {
+	var response BuilderRunResponse
+	if err := b.client.Call("Builder.Run", &BuilderRunArgs{streamId}, &response); err != nil {
 		return nil, err
 	}
 
-	response := <-runResponseCh
 	if response.Err != nil {
 		return nil, response.Err
 	}
 
-	if response.RPCAddress == "" {
+	if response.StreamId == 0 {
 		return nil, nil
 	}
 
-	client, err := rpcDial(response.RPCAddress)
+	client, err := newClientWithMux(b.client.mux, response.StreamId)
 	if err != nil {
 		return nil, err
 	}
@@ -127,46 +100,28 @@ func (b *BuilderServer) Prepare(args *BuilderPrepareArgs, reply *BuilderPrepareR
 	return nil
 }
 
-func (b *BuilderServer) Run(args *BuilderRunArgs, reply *interface{}) error {
-	client, err := rpcDial(args.RPCAddress)
+func (b *BuilderServer) Run(args *BuilderRunArgs, reply *BuilderRunResponse) error {
+	client, err := newClientWithMux(b.mux, args.StreamId)
 	if err != nil {
-		return err
+		return NewBasicError(err)
 	}
+	defer client.Close()
 
-	responseC, err := tcpDial(args.ResponseAddress)
-	if err != nil {
-		return err
+	artifact, responseErr := b.builder.Run(&Ui{client}, Hook(client), Cache(client))
+	if responseErr != nil {
+		responseErr = NewBasicError(responseErr)
 	}
 
-	responseWriter := gob.NewEncoder(responseC)
-
-	// Run the build in a goroutine so we don't block the RPC connection
-	go func() {
-		defer responseC.Close()
-
-		cache := Cache(client)
-		hook := Hook(client)
-		ui := &Ui{client}
-		artifact, responseErr := b.builder.Run(ui, hook, cache)
-		responseAddress := ""
-
-		if responseErr == nil && artifact != nil {
-			// Wrap the artifact
-			server := rpc.NewServer()
-			RegisterArtifact(server, artifact)
-			responseAddress = serveSingleConn(server)
-		}
-
-		if responseErr != nil {
-			responseErr = NewBasicError(responseErr)
-		}
-
-		err := responseWriter.Encode(&BuilderRunResponse{responseErr, responseAddress})
-		if err != nil {
-			log.Printf("BuildServer.Run error: %s", err)
-		}
-	}()
+	var streamId uint32
+	if responseErr == nil && artifact != nil {
+		// Wrap the artifact
+		streamId = b.mux.NextId()
+		server := newServerWithMux(b.mux, streamId)
+		RegisterArtifact(server, artifact)
+		go server.Serve()
+	}
 
+	*reply = BuilderRunResponse{responseErr, streamId}
 	return nil
 }
 
@@ -2,7 +2,6 @@ package rpc
 
 import (
 	"github.com/mitchellh/packer/packer"
-	"net/rpc"
 	"reflect"
 	"testing"
 )
@@ -12,16 +11,8 @@ var testBuilderArtifact = &testArtifact{}
 func builderRPCClient(t *testing.T) (*packer.MockBuilder, packer.Builder) {
 	b := new(packer.MockBuilder)
 
-	// Start the server
-	server := rpc.NewServer()
+	client, server := testClientServer(t)
 	RegisterBuilder(server, b)
-	address := serveSingleConn(server)
-
-	// Create the client over RPC and run some methods to verify it works
-	client, err := rpc.Dial("tcp", address)
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
 	return b, Builder(client)
 }
 
@@ -66,6 +57,14 @@ func TestBuilderPrepare_Warnings(t *testing.T) {
 func TestBuilderRun(t *testing.T) {
 	b, bClient := builderRPCClient(t)
 
+	// The cache, hook, and UI only work while the builder runs
+	b.RunFunc = func() error {
+		b.RunCache.Lock("foo")
+		b.RunHook.Run("foo", nil, nil, nil)
+		b.RunUi.Say("format")
+		return nil
+	}
+
 	// Test Run
 	cache := new(testCache)
 	hook := &packer.MockHook{}
@@ -79,17 +78,14 @@ func TestBuilderRun(t *testing.T) {
 		t.Fatal("run should be called")
 	}
 
-	b.RunCache.Lock("foo")
 	if !cache.lockCalled {
 		t.Fatal("should be called")
 	}
 
-	b.RunHook.Run("foo", nil, nil, nil)
 	if !hook.RunCalled {
 		t.Fatal("should be called")
 	}
 
-	b.RunUi.Say("format")
 	if !ui.sayCalled {
 		t.Fatal("say should be called")
 	}
@@ -98,6 +94,12 @@ func TestBuilderRun(t *testing.T) {
 		t.Fatalf("bad: %s", ui.sayMessage)
 	}
 
+	// The UI is closed once the run is over
+	b.RunUi.Say("late")
+	if ui.sayMessage != "format" {
+		t.Fatalf("bad: %s", ui.sayMessage)
+	}
+
 	if artifact.Id() != testBuilderArtifact.Id() {
 		t.Fatalf("bad: %s", artifact.Id())
 	}
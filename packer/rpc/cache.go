@@ -1,14 +1,11 @@
 package rpc
 
-import (
-	"github.com/mitchellh/packer/packer"
-	"net/rpc"
-)
+import "github.com/mitchellh/packer/packer"
 
 // An implementation of packer.Cache where the cache is actually executed
 // over an RPC connection.
 type cache struct {
-	client *rpc.Client
+	client *Client
 }
 
 // CacheServer wraps a packer.Cache implementation and makes it exportable
@@ -17,7 +14,7 @@ type CacheServer struct {
 	cache packer.Cache
 }
 
-func Cache(client *rpc.Client) *cache {
+func Cache(client *Client) *cache {
 	return &cache{client}
 }
 
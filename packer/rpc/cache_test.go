@@ -2,7 +2,6 @@ package rpc
 
 import (
 	"github.com/mitchellh/packer/packer"
-	"net/rpc"
 	"testing"
 )
 
@@ -51,16 +50,8 @@ func TestCacheRPC(t *testing.T) {
 	// Create the interface to test
 	c := new(testCache)
 
-	// Start the server
-	server := rpc.NewServer()
+	rpcClient, server := testClientServer(t)
 	RegisterCache(server, c)
-	address := serveSingleConn(server)
-
-	// Create the client over RPC and run some methods to verify it works
-	rpcClient, err := rpc.Dial("tcp", address)
-	if err != nil {
-		t.Fatalf("bad: %s", err)
-	}
 	client := Cache(rpcClient)
 
 	// Test Lock
@@ -0,0 +1,58 @@
+package rpc
+
+import (
+	"io"
+	"net/rpc"
+)
+
+// Client is the client end of a Packer RPC connection, paired with a
+// Server on the other end. It is used to construct the RPC implementations
+// of the Packer interfaces, such as Builder or Ui.
+type Client struct {
+	mux      *MuxConn
+	client   *rpc.Client
+	closeMux bool
+}
+
+// NewClient returns a new Packer RPC client that talks to a Server over
+// the given connection.
+func NewClient(conn io.ReadWriteCloser) (*Client, error) {
+	mux := NewMuxConnClient(conn)
+	result, err := newClientWithMux(mux, 0)
+	if err != nil {
+		mux.Close()
+		return nil, err
+	}
+
+	result.closeMux = true
+	return result, nil
+}
+
+func newClientWithMux(mux *MuxConn, streamId uint32) (*Client, error) {
+	stream, err := mux.Dial(streamId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		mux:    mux,
+		client: rpc.NewClient(stream),
+	}, nil
+}
+
+// Call invokes the named function on the server, waits for it to
+// complete, and returns its error status.
+func (c *Client) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	return c.client.Call(serviceMethod, args, reply)
+}
+
+// Close closes the client. If the client was created with NewClient,
+// this also closes the underlying connection.
+func (c *Client) Close() error {
+	err := c.client.Close()
+	if c.closeMux {
+		c.mux.Close()
+	}
+
+	return err
+}
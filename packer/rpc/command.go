@@ -1,30 +1,28 @@
 package rpc
 
-import (
-	"github.com/mitchellh/packer/packer"
-	"net/rpc"
-)
+import "github.com/mitchellh/packer/packer"
 
 // A Command is an implementation of the packer.Command interface where the
 // command is actually executed over an RPC connection.
 type command struct {
-	client *rpc.Client
+	client *Client
 }
 
 // A CommandServer wraps a packer.Command and makes it exportable as part
 // of a Golang RPC server.
 type CommandServer struct {
 	command packer.Command
+	mux     *MuxConn
 }
 
 type CommandRunArgs struct {
-	RPCAddress string
-	Args       []string
+	StreamId uint32
+	Args     []string
 }
 
 type CommandSynopsisArgs byte
 
-func Command(client *rpc.Client) *command {
+func Command(client *Client) *command {
 	return &command{client}
 }
 
@@ -39,10 +37,12 @@ func (c *command) Help() (result string) {
 
 func (c *command) Run(env packer.Environment, args []string) (result int) {
 	// Create and start the server for the Environment
-	server := rpc.NewServer()
+	streamId := c.client.mux.NextId()
+	server := newServerWithMux(c.client.mux, streamId)
 	RegisterEnvironment(server, env)
+	go server.Serve()
 
-	rpcArgs := &CommandRunArgs{serveSingleConn(server), args}
+	rpcArgs := &CommandRunArgs{streamId, args}
 	err := c.client.Call("Command.Run", rpcArgs, &result)
 	if err != nil {
 		panic(err)
@@ -66,9 +66,9 @@ func (c *CommandServer) Help(args *interface{}, reply *string) error {
 }
 
 func (c *CommandServer) Run(args *CommandRunArgs, reply *int) error {
-	client, err := rpcDial(args.RPCAddress)
+	client, err := newClientWithMux(c.mux, args.StreamId)
 	if err != nil {
-		return err
+		return NewBasicError(err)
 	}
 
 	env := &Environment{client}
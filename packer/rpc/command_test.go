@@ -2,7 +2,6 @@ package rpc
 
 import (
 	"github.com/mitchellh/packer/packer"
-	"net/rpc"
 	"reflect"
 	"testing"
 )
@@ -32,17 +31,8 @@ func TestRPCCommand(t *testing.T) {
 	// Create the command
 	command := new(TestCommand)
 
-	// Start the server
-	server := rpc.NewServer()
+	client, server := testClientServer(t)
 	RegisterCommand(server, command)
-	address := serveSingleConn(server)
-
-	// Create the command client over RPC and run some methods to verify
-	// we get the proper behavior.
-	client, err := rpc.Dial("tcp", address)
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
 
 	clientComm := Command(client)
 
@@ -2,24 +2,22 @@ package rpc
 
 import (
 	"encoding/gob"
-	"errors"
 	"github.com/mitchellh/packer/packer"
 	"io"
 	"log"
-	"net"
-	"net/rpc"
 )
 
 // An implementation of packer.Communicator where the communicator is actually
 // executed over an RPC connection.
 type communicator struct {
-	client *rpc.Client
+	client *Client
 }
 
 // CommunicatorServer wraps a packer.Communicator implementation and makes
 // it exportable as part of a Golang RPC server.
 type CommunicatorServer struct {
-	c packer.Communicator
+	c   packer.Communicator
+	mux *MuxConn
 }
 
 type CommandFinished struct {
@@ -27,21 +25,21 @@ type CommandFinished struct {
 }
 
 type CommunicatorStartArgs struct {
-	Command         string
-	StdinAddress    string
-	StdoutAddress   string
-	StderrAddress   string
-	ResponseAddress string
+	Command          string
+	StdinStreamId    uint32
+	StdoutStreamId   uint32
+	StderrStreamId   uint32
+	ResponseStreamId uint32
 }
 
 type CommunicatorDownloadArgs struct {
-	Path          string
-	WriterAddress string
+	Path           string
+	WriterStreamId uint32
 }
 
 type CommunicatorUploadArgs struct {
-	Path          string
-	ReaderAddress string
+	Path           string
+	ReaderStreamId uint32
 }
 
 type CommunicatorUploadDirArgs struct {
@@ -50,7 +48,7 @@ type CommunicatorUploadDirArgs struct {
 	Exclude []string
 }
 
-func Communicator(client *rpc.Client) *communicator {
+func Communicator(client *Client) *communicator {
 	return &communicator{client}
 }
 
@@ -59,30 +57,25 @@ func (c *communicator) Start(cmd *packer.RemoteCmd) (err error) {
 	args.Command = cmd.Command
 
 	if cmd.Stdin != nil {
-		stdinL := netListenerInRange(portRangeMin, portRangeMax)
-		args.StdinAddress = stdinL.Addr().String()
-		go serveSingleCopy("stdin", stdinL, nil, cmd.Stdin)
+		args.StdinStreamId = c.client.mux.NextId()
+		go serveSingleCopy("stdin", c.client.mux, args.StdinStreamId, nil, cmd.Stdin)
 	}
 
 	if cmd.Stdout != nil {
-		stdoutL := netListenerInRange(portRangeMin, portRangeMax)
-		args.StdoutAddress = stdoutL.Addr().String()
-		go serveSingleCopy("stdout", stdoutL, cmd.Stdout, nil)
+		args.StdoutStreamId = c.client.mux.NextId()
+		go serveSingleCopy("stdout", c.client.mux, args.StdoutStreamId, cmd.Stdout, nil)
 	}
 
 	if cmd.Stderr != nil {
-		stderrL := netListenerInRange(portRangeMin, portRangeMax)
-		args.StderrAddress = stderrL.Addr().String()
-		go serveSingleCopy("stderr", stderrL, cmd.Stderr, nil)
+		args.StderrStreamId = c.client.mux.NextId()
+		go serveSingleCopy("stderr", c.client.mux, args.StderrStreamId, cmd.Stderr, nil)
 	}
 
-	responseL := netListenerInRange(portRangeMin, portRangeMax)
-	args.ResponseAddress = responseL.Addr().String()
+	responseStreamId := c.client.mux.NextId()
+	args.ResponseStreamId = responseStreamId
 
 	go func() {
-		defer responseL.Close()
-
-		conn, err := responseL.Accept()
+		conn, err := c.client.mux.Accept(responseStreamId)
 		if err != nil {
 			cmd.SetExited(123)
 			return
@@ -106,23 +99,16 @@ func (c *communicator) Start(cmd *packer.RemoteCmd) (err error) {
 }
 
 func (c *communicator) Upload(path string, r io.Reader) (err error) {
-	// We need to create a server that can proxy the reader data
+	// We need to create a stream that can proxy the reader data
 	// over because we can't simply gob encode an io.Reader
-	readerL := netListenerInRange(portRangeMin, portRangeMax)
-	if readerL == nil {
-		err = errors.New("couldn't allocate listener for upload reader")
-		return
-	}
-
-	// Make sure at the end of this call, we close the listener
-	defer readerL.Close()
+	streamId := c.client.mux.NextId()
 
 	// Pipe the reader through to the connection
-	go serveSingleCopy("uploadReader", readerL, nil, r)
+	go serveSingleCopy("uploadReader", c.client.mux, streamId, nil, r)
 
 	args := CommunicatorUploadArgs{
 		path,
-		readerL.Addr().String(),
+		streamId,
 	}
 
 	err = c.client.Call("Communicator.Upload", &args, new(interface{}))
@@ -146,23 +132,16 @@ func (c *communicator) UploadDir(dst string, src string, exclude []string) error
 }
 
 func (c *communicator) Download(path string, w io.Writer) (err error) {
-	// We need to create a server that can proxy that data downloaded
+	// We need to create a stream that can proxy that data downloaded
 	// into the writer because we can't gob encode a writer directly.
-	writerL := netListenerInRange(portRangeMin, portRangeMax)
-	if writerL == nil {
-		err = errors.New("couldn't allocate listener for download writer")
-		return
-	}
-
-	// Make sure we close the listener once we're done because we'll be done
-	defer writerL.Close()
+	streamId := c.client.mux.NextId()
 
 	// Serve a single connection and a single copy
-	go serveSingleCopy("downloadWriter", writerL, w, nil)
+	go serveSingleCopy("downloadWriter", c.client.mux, streamId, w, nil)
 
 	args := CommunicatorDownloadArgs{
 		path,
-		writerL.Addr().String(),
+		streamId,
 	}
 
 	err = c.client.Call("Communicator.Download", &args, new(interface{}))
@@ -175,9 +154,9 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 	var cmd packer.RemoteCmd
 	cmd.Command = args.Command
 
-	toClose := make([]net.Conn, 0)
-	if args.StdinAddress != "" {
-		stdinC, err := tcpDial(args.StdinAddress)
+	toClose := make([]io.Closer, 0)
+	if args.StdinStreamId > 0 {
+		stdinC, err := c.mux.Dial(args.StdinStreamId)
 		if err != nil {
 			return err
 		}
@@ -186,8 +165,8 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 		cmd.Stdin = stdinC
 	}
 
-	if args.StdoutAddress != "" {
-		stdoutC, err := tcpDial(args.StdoutAddress)
+	if args.StdoutStreamId > 0 {
+		stdoutC, err := c.mux.Dial(args.StdoutStreamId)
 		if err != nil {
 			return err
 		}
@@ -196,8 +175,8 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 		cmd.Stdout = stdoutC
 	}
 
-	if args.StderrAddress != "" {
-		stderrC, err := tcpDial(args.StderrAddress)
+	if args.StderrStreamId > 0 {
+		stderrC, err := c.mux.Dial(args.StderrStreamId)
 		if err != nil {
 			return err
 		}
@@ -206,9 +185,9 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 		cmd.Stderr = stderrC
 	}
 
-	// Connect to the response address so we can write our result to it
+	// Connect to the response stream so we can write our result to it
 	// when ready.
-	responseC, err := tcpDial(args.ResponseAddress)
+	responseC, err := c.mux.Dial(args.ResponseStreamId)
 	if err != nil {
 		return err
 	}
@@ -234,7 +213,7 @@ func (c *CommunicatorServer) Start(args *CommunicatorStartArgs, reply *interface
 }
 
 func (c *CommunicatorServer) Upload(args *CommunicatorUploadArgs, reply *interface{}) (err error) {
-	readerC, err := tcpDial(args.ReaderAddress)
+	readerC, err := c.mux.Dial(args.ReaderStreamId)
 	if err != nil {
 		return
 	}
@@ -250,7 +229,7 @@ func (c *CommunicatorServer) UploadDir(args *CommunicatorUploadDirArgs, reply *e
 }
 
 func (c *CommunicatorServer) Download(args *CommunicatorDownloadArgs, reply *interface{}) (err error) {
-	writerC, err := tcpDial(args.WriterAddress)
+	writerC, err := c.mux.Dial(args.WriterStreamId)
 	if err != nil {
 		return
 	}
@@ -261,10 +240,8 @@ func (c *CommunicatorServer) Download(args *CommunicatorDownloadArgs, reply *int
 	return
 }
 
-func serveSingleCopy(name string, l net.Listener, dst io.Writer, src io.Reader) {
-	defer l.Close()
-
-	conn, err := l.Accept()
+func serveSingleCopy(name string, mux *MuxConn, id uint32, dst io.Writer, src io.Reader) {
+	conn, err := mux.Accept(id)
 	if err != nil {
 		log.Printf("'%s' accept error: %s", name, err)
 		return
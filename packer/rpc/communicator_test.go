@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"github.com/mitchellh/packer/packer"
 	"io"
-	"net/rpc"
 	"reflect"
 	"testing"
 )
@@ -13,16 +12,8 @@ func TestCommunicatorRPC(t *testing.T) {
 	// Create the interface to test
 	c := new(packer.MockCommunicator)
 
-	// Start the server
-	server := rpc.NewServer()
+	client, server := testClientServer(t)
 	RegisterCommunicator(server, c)
-	address := serveSingleConn(server)
-
-	// Create the client over RPC and run some methods to verify it works
-	client, err := rpc.Dial("tcp", address)
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
 	remote := Communicator(client)
 
 	// The remote command we'll use
@@ -42,7 +33,7 @@ func TestCommunicatorRPC(t *testing.T) {
 	c.StartExitStatus = 42
 
 	// Test Start
-	err = remote.Start(&cmd)
+	err := remote.Start(&cmd)
 	if err != nil {
 		t.Fatalf("err: %s", err)
 	}
@@ -1,20 +1,18 @@
 package rpc
 
-import (
-	"github.com/mitchellh/packer/packer"
-	"net/rpc"
-)
+import "github.com/mitchellh/packer/packer"
 
 // A Environment is an implementation of the packer.Environment interface
 // where the actual environment is executed over an RPC connection.
 type Environment struct {
-	client *rpc.Client
+	client *Client
 }
 
 // A EnvironmentServer wraps a packer.Environment and makes it exportable
 // as part of a Golang RPC server.
 type EnvironmentServer struct {
 	env packer.Environment
+	mux *MuxConn
 }
 
 type EnvironmentCliArgs struct {
@@ -22,13 +20,13 @@ type EnvironmentCliArgs struct {
 }
 
 func (e *Environment) Builder(name string) (b packer.Builder, err error) {
-	var reply string
+	var reply uint32
 	err = e.client.Call("Environment.Builder", name, &reply)
 	if err != nil {
 		return
 	}
 
-	client, err := rpcDial(reply)
+	client, err := newClientWithMux(e.client.mux, reply)
 	if err != nil {
 		return
 	}
@@ -38,12 +36,12 @@ func (e *Environment) Builder(name string) (b packer.Builder, err error) {
 }
 
 func (e *Environment) Cache() packer.Cache {
-	var reply string
+	var reply uint32
 	if err := e.client.Call("Environment.Cache", new(interface{}), &reply); err != nil {
 		panic(err)
 	}
 
-	client, err := rpcDial(reply)
+	client, err := newClientWithMux(e.client.mux, reply)
 	if err != nil {
 		panic(err)
 	}
@@ -58,13 +56,13 @@ func (e *Environment) Cli(args []string) (result int, err error) {
 }
 
 func (e *Environment) Hook(name string) (h packer.Hook, err error) {
-	var reply string
+	var reply uint32
 	err = e.client.Call("Environment.Hook", name, &reply)
 	if err != nil {
 		return
 	}
 
-	client, err := rpcDial(reply)
+	client, err := newClientWithMux(e.client.mux, reply)
 	if err != nil {
 		return
 	}
@@ -74,13 +72,13 @@ func (e *Environment) Hook(name string) (h packer.Hook, err error) {
 }
 
 func (e *Environment) PostProcessor(name string) (p packer.PostProcessor, err error) {
-	var reply string
+	var reply uint32
 	err = e.client.Call("Environment.PostProcessor", name, &reply)
 	if err != nil {
 		return
 	}
 
-	client, err := rpcDial(reply)
+	client, err := newClientWithMux(e.client.mux, reply)
 	if err != nil {
 		return
 	}
@@ -90,13 +88,13 @@ func (e *Environment) PostProcessor(name string) (p packer.PostProcessor, err er
 }
 
 func (e *Environment) Provisioner(name string) (p packer.Provisioner, err error) {
-	var reply string
+	var reply uint32
 	err = e.client.Call("Environment.Provisioner", name, &reply)
 	if err != nil {
 		return
 	}
 
-	client, err := rpcDial(reply)
+	client, err := newClientWithMux(e.client.mux, reply)
 	if err != nil {
 		return
 	}
@@ -106,10 +104,10 @@ func (e *Environment) Provisioner(name string) (p packer.Provisioner, err error)
 }
 
 func (e *Environment) Ui() packer.Ui {
-	var reply string
+	var reply uint32
 	e.client.Call("Environment.Ui", new(interface{}), &reply)
 
-	client, err := rpcDial(reply)
+	client, err := newClientWithMux(e.client.mux, reply)
 	if err != nil {
 		panic(err)
 	}
@@ -117,26 +115,27 @@ func (e *Environment) Ui() packer.Ui {
 	return &Ui{client}
 }
 
-func (e *EnvironmentServer) Builder(name *string, reply *string) error {
+func (e *EnvironmentServer) Builder(name *string, reply *uint32) error {
 	builder, err := e.env.Builder(*name)
 	if err != nil {
 		return err
 	}
 
 	// Wrap it
-	server := rpc.NewServer()
+	*reply = e.mux.NextId()
+	server := newServerWithMux(e.mux, *reply)
 	RegisterBuilder(server, builder)
-
-	*reply = serveSingleConn(server)
+	go server.Serve()
 	return nil
 }
 
-func (e *EnvironmentServer) Cache(args *interface{}, reply *string) error {
+func (e *EnvironmentServer) Cache(args *interface{}, reply *uint32) error {
 	cache := e.env.Cache()
 
-	server := rpc.NewServer()
+	*reply = e.mux.NextId()
+	server := newServerWithMux(e.mux, *reply)
 	RegisterCache(server, cache)
-	*reply = serveSingleConn(server)
+	go server.Serve()
 	return nil
 }
 
@@ -145,53 +144,53 @@ func (e *EnvironmentServer) Cli(args *EnvironmentCliArgs, reply *int) (err error
 	return
 }
 
-func (e *EnvironmentServer) Hook(name *string, reply *string) error {
+func (e *EnvironmentServer) Hook(name *string, reply *uint32) error {
 	hook, err := e.env.Hook(*name)
 	if err != nil {
 		return err
 	}
 
 	// Wrap it
-	server := rpc.NewServer()
+	*reply = e.mux.NextId()
+	server := newServerWithMux(e.mux, *reply)
 	RegisterHook(server, hook)
-
-	*reply = serveSingleConn(server)
+	go server.Serve()
 	return nil
 }
 
-func (e *EnvironmentServer) PostProcessor(name *string, reply *string) error {
+func (e *EnvironmentServer) PostProcessor(name *string, reply *uint32) error {
 	pp, err := e.env.PostProcessor(*name)
 	if err != nil {
 		return err
 	}
 
-	server := rpc.NewServer()
+	*reply = e.mux.NextId()
+	server := newServerWithMux(e.mux, *reply)
 	RegisterPostProcessor(server, pp)
-
-	*reply = serveSingleConn(server)
+	go server.Serve()
 	return nil
 }
 
-func (e *EnvironmentServer) Provisioner(name *string, reply *string) error {
+func (e *EnvironmentServer) Provisioner(name *string, reply *uint32) error {
 	prov, err := e.env.Provisioner(*name)
 	if err != nil {
 		return err
 	}
 
-	server := rpc.NewServer()
+	*reply = e.mux.NextId()
+	server := newServerWithMux(e.mux, *reply)
 	RegisterProvisioner(server, prov)
-
-	*reply = serveSingleConn(server)
+	go server.Serve()
 	return nil
 }
 
-func (e *EnvironmentServer) Ui(args *interface{}, reply *string) error {
+func (e *EnvironmentServer) Ui(args *interface{}, reply *uint32) error {
 	ui := e.env.Ui()
 
 	// Wrap it
-	server := rpc.NewServer()
+	*reply = e.mux.NextId()
+	server := newServerWithMux(e.mux, *reply)
 	RegisterUi(server, ui)
-
-	*reply = serveSingleConn(server)
+	go server.Serve()
 	return nil
 }
@@ -3,38 +3,40 @@ package rpc
 import (
 	"github.com/mitchellh/packer/packer"
 	"log"
-	"net/rpc"
 )
 
 // An implementation of packer.Hook where the hook is actually executed
 // over an RPC connection.
 type hook struct {
-	client *rpc.Client
+	client *Client
 }
 
 // HookServer wraps a packer.Hook implementation and makes it exportable
 // as part of a Golang RPC server.
 type HookServer struct {
 	hook packer.Hook
+	mux  *MuxConn
 }
 
 type HookRunArgs struct {
-	Name       string
-	Data       interface{}
-	RPCAddress string
+	Name     string
+	Data     interface{}
+	StreamId uint32
 }
 
-func Hook(client *rpc.Client) *hook {
+func Hook(client *Client) *hook {
 	return &hook{client}
 }
 
 func (h *hook) Run(name string, ui packer.Ui, comm packer.Communicator, data interface{}) error {
-	server := rpc.NewServer()
+	streamId := h.client.mux.NextId()
+	server := newServerWithMux(h.client.mux, streamId)
 	RegisterCommunicator(server, comm)
 	RegisterUi(server, ui)
-	address := serveSingleConn(server)
+	go server.Serve()
+	defer server.Close()
 
-	args := &HookRunArgs{name, data, address}
+	args := &HookRunArgs{name, data, streamId}
 	return h.client.Call("Hook.Run", args, new(interface{}))
 }
 
@@ -46,10 +48,11 @@ func (h *hook) Cancel() {
 }
 
 func (h *HookServer) Run(args *HookRunArgs, reply *interface{}) error {
-	client, err := rpcDial(args.RPCAddress)
+	client, err := newClientWithMux(h.mux, args.StreamId)
 	if err != nil {
-		return err
+		return NewBasicError(err)
 	}
+	defer client.Close()
 
 	if err := h.hook.Run(args.Name, &Ui{client}, Communicator(client), args.Data); err != nil {
 		return NewBasicError(err)
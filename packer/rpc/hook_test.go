@@ -2,7 +2,6 @@ package rpc
 
 import (
 	"github.com/mitchellh/packer/packer"
-	"net/rpc"
 	"reflect"
 	"sync"
 	"testing"
@@ -13,16 +12,8 @@ func TestHookRPC(t *testing.T) {
 	// Create the UI to test
 	h := new(packer.MockHook)
 
-	// Serve
-	server := rpc.NewServer()
+	client, server := testClientServer(t)
 	RegisterHook(server, h)
-	address := serveSingleConn(server)
-
-	// Create the client over RPC and run some methods to verify it works
-	client, err := rpc.Dial("tcp", address)
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
 
 	hClient := Hook(client)
 
@@ -59,16 +50,8 @@ func TestHook_cancelWhileRun(t *testing.T) {
 		},
 	}
 
-	// Serve
-	server := rpc.NewServer()
+	client, server := testClientServer(t)
 	RegisterHook(server, h)
-	address := serveSingleConn(server)
-
-	// Create the client over RPC and run some methods to verify it works
-	client, err := rpc.Dial("tcp", address)
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
 
 	hClient := Hook(client)
 
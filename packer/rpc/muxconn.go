@@ -0,0 +1,439 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"sync"
+)
+
+// MuxConn is able to multiplex multiple independent streams on top of
+// a single io.ReadWriteCloser. These streams act like TCP connections
+// (Dial, Accept, Close, full duplex, etc.).
+//
+// The underlying io.ReadWriteCloser is expected to guarantee delivery
+// and ordering, such as TCP or a Unix domain socket. Data received for a
+// stream is buffered in memory until it is read so that a slow reader
+// on one stream never blocks the other streams. Each stream has a window
+// of data that may be sent before the reader acknowledges reading it,
+// which bounds that buffer the way TCP backpressure would.
+type MuxConn struct {
+	rwc     io.ReadWriteCloser
+	streams map[uint32]*Stream
+	nextId  uint32
+	closed  bool
+	doneCh  chan struct{}
+	l       sync.Mutex
+	wlock   sync.Mutex
+}
+
+type muxPacketType byte
+
+const (
+	muxPacketSyn muxPacketType = iota
+	muxPacketData
+	muxPacketFin
+	muxPacketAck
+)
+
+// The size of a packet header: type (1 byte), stream ID (4 bytes) and
+// data length (4 bytes).
+const muxHeaderSize = 9
+
+// The maximum amount of data sent in a single packet. Larger writes are
+// split into multiple packets so that streams are interleaved fairly.
+const muxMaxDataSize = 32 * 1024
+
+// The amount of data that can be sent on a stream before the other side
+// acknowledges reading it. This is the most that is buffered per stream.
+const muxWindowSize = 256 * 1024
+
+var errMuxClosed = errors.New("mux connection closed")
+
+// NewMuxConnClient creates the client side of a multiplexed connection.
+// The client and server sides allocate stream IDs from separate ranges
+// so that both can create new streams without coordinating. Stream ID 0
+// is reserved for the initial stream that the client dials.
+func NewMuxConnClient(rwc io.ReadWriteCloser) *MuxConn {
+	return newMuxConn(rwc, 1)
+}
+
+// NewMuxConnServer creates the server side of a multiplexed connection.
+func NewMuxConnServer(rwc io.ReadWriteCloser) *MuxConn {
+	return newMuxConn(rwc, 2)
+}
+
+func newMuxConn(rwc io.ReadWriteCloser, startId uint32) *MuxConn {
+	m := &MuxConn{
+		rwc:     rwc,
+		streams: make(map[uint32]*Stream),
+		nextId:  startId,
+		doneCh:  make(chan struct{}),
+	}
+
+	go m.loop()
+	return m
+}
+
+// Accept waits for the other side to Dial the stream with the given ID
+// and returns it. The ID should come from NextId on this side of the
+// connection, or be 0 for the initial stream on the server side.
+func (m *MuxConn) Accept(id uint32) (io.ReadWriteCloser, error) {
+	stream, err := m.openStream(id)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-stream.synCh:
+		return stream, nil
+	case <-m.doneCh:
+		return nil, errMuxClosed
+	}
+}
+
+// stream returns the open stream with the given ID, or nil if there
+// isn't one.
+func (m *MuxConn) stream(id uint32) *Stream {
+	m.l.Lock()
+	defer m.l.Unlock()
+	return m.streams[id]
+}
+
+// Close closes the underlying connection and all the streams on it.
+// Any pending reads on streams will return io.EOF once the data that
+// has already been received is consumed.
+func (m *MuxConn) Close() error {
+	m.l.Lock()
+	if m.closed {
+		m.l.Unlock()
+		return nil
+	}
+
+	m.closed = true
+	close(m.doneCh)
+	streams := m.streams
+	m.streams = make(map[uint32]*Stream)
+	m.l.Unlock()
+
+	for _, s := range streams {
+		s.remoteClose()
+	}
+
+	return m.rwc.Close()
+}
+
+// Dial opens the stream with the given ID, which the other side must
+// Accept. The ID is usually sent to the other side over an existing
+// stream, such as in the arguments of an RPC call.
+func (m *MuxConn) Dial(id uint32) (io.ReadWriteCloser, error) {
+	stream, err := m.openStream(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.write(id, muxPacketSyn, nil); err != nil {
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// NextId returns the next available stream ID that this side of the
+// connection can Accept on. IDs are never reused.
+func (m *MuxConn) NextId() uint32 {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	id := m.nextId
+	m.nextId += 2
+	return id
+}
+
+func (m *MuxConn) openStream(id uint32) (*Stream, error) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	if m.closed {
+		return nil, errMuxClosed
+	}
+
+	if stream, ok := m.streams[id]; ok {
+		return stream, nil
+	}
+
+	stream := newStream(m, id)
+	m.streams[id] = stream
+	return stream, nil
+}
+
+func (m *MuxConn) removeStream(id uint32) {
+	m.l.Lock()
+	defer m.l.Unlock()
+	delete(m.streams, id)
+}
+
+func (m *MuxConn) loop() {
+	defer m.Close()
+
+	var header [muxHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(m.rwc, header[:]); err != nil {
+			if err != io.EOF {
+				log.Printf("[ERR] Error reading mux packet header: %s", err)
+			}
+
+			return
+		}
+
+		packetType := muxPacketType(header[0])
+		id := binary.BigEndian.Uint32(header[1:5])
+		length := binary.BigEndian.Uint32(header[5:9])
+		if length > muxMaxDataSize {
+			log.Printf("[ERR] Mux packet for stream %d too large: %d", id, length)
+			return
+		}
+
+		var data []byte
+		if length > 0 {
+			data = make([]byte, length)
+			if _, err := io.ReadFull(m.rwc, data); err != nil {
+				log.Printf("[ERR] Error reading mux packet data: %s", err)
+				return
+			}
+		}
+
+		// Only a SYN opens a stream. Anything else for a stream that
+		// isn't open is left over from a stream that is closed.
+		var stream *Stream
+		if packetType == muxPacketSyn {
+			var err error
+			stream, err = m.openStream(id)
+			if err != nil {
+				return
+			}
+		} else if stream = m.stream(id); stream == nil {
+			continue
+		}
+
+		switch packetType {
+		case muxPacketSyn:
+			stream.setSyn()
+		case muxPacketData:
+			if err := stream.push(data); err != nil {
+				log.Printf("[ERR] Mux stream %d: %s", id, err)
+				return
+			}
+		case muxPacketFin:
+			stream.remoteClose()
+		case muxPacketAck:
+			if len(data) != 4 {
+				log.Printf("[ERR] Bad mux ack for stream %d", id)
+				return
+			}
+
+			stream.ack(binary.BigEndian.Uint32(data))
+		default:
+			log.Printf("[ERR] Unknown mux packet type: %d", packetType)
+			return
+		}
+	}
+}
+
+func (m *MuxConn) write(id uint32, packetType muxPacketType, data []byte) error {
+	packet := make([]byte, muxHeaderSize+len(data))
+	packet[0] = byte(packetType)
+	binary.BigEndian.PutUint32(packet[1:5], id)
+	binary.BigEndian.PutUint32(packet[5:9], uint32(len(data)))
+	copy(packet[muxHeaderSize:], data)
+
+	m.wlock.Lock()
+	defer m.wlock.Unlock()
+
+	select {
+	case <-m.doneCh:
+		return errMuxClosed
+	default:
+	}
+
+	_, err := m.rwc.Write(packet)
+	return err
+}
+
+// Stream is a single stream of data within a MuxConn. It implements
+// io.ReadWriteCloser. Closing a stream closes it in both directions.
+type Stream struct {
+	id     uint32
+	mux    *MuxConn
+	synCh  chan struct{}
+	synced bool
+
+	buf          bytes.Buffer
+	cond         *sync.Cond
+	l            sync.Mutex
+	localClosed  bool
+	remoteClosed bool
+
+	// sendWindow is how much more may be written before the other side
+	// acknowledges reading it, and unacked is how much was read without
+	// acknowledging it yet.
+	sendWindow uint32
+	unacked    uint32
+}
+
+func newStream(m *MuxConn, id uint32) *Stream {
+	s := &Stream{
+		id:         id,
+		mux:        m,
+		synCh:      make(chan struct{}),
+		sendWindow: muxWindowSize,
+	}
+
+	s.cond = sync.NewCond(&s.l)
+	return s
+}
+
+func (s *Stream) Close() error {
+	s.l.Lock()
+	if s.localClosed {
+		s.l.Unlock()
+		return nil
+	}
+
+	s.localClosed = true
+	remoteClosed := s.remoteClosed
+	s.cond.Broadcast()
+	s.l.Unlock()
+
+	// Once both sides are closed, nothing else will arrive for this
+	// stream so we can forget about it.
+	if remoteClosed {
+		s.mux.removeStream(s.id)
+	}
+
+	err := s.mux.write(s.id, muxPacketFin, nil)
+	if err == errMuxClosed {
+		err = nil
+	}
+
+	return err
+}
+
+func (s *Stream) Read(p []byte) (int, error) {
+	s.l.Lock()
+	for s.buf.Len() == 0 {
+		if s.localClosed || s.remoteClosed {
+			s.l.Unlock()
+			return 0, io.EOF
+		}
+
+		s.cond.Wait()
+	}
+
+	n, err := s.buf.Read(p)
+
+	// Acknowledge what was read once it's half the window, so the other
+	// side can send more without an ack for every read.
+	var ack uint32
+	s.unacked += uint32(n)
+	if s.unacked >= muxWindowSize/2 {
+		ack = s.unacked
+		s.unacked = 0
+	}
+	s.l.Unlock()
+
+	if ack > 0 {
+		var data [4]byte
+		binary.BigEndian.PutUint32(data[:], ack)
+		if err := s.mux.write(s.id, muxPacketAck, data[:]); err != nil && err != errMuxClosed {
+			log.Printf("[ERR] Error acknowledging mux stream %d: %s", s.id, err)
+		}
+	}
+
+	return n, err
+}
+
+func (s *Stream) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		// Wait until the other side has room for more data.
+		s.l.Lock()
+		for s.sendWindow == 0 && !s.localClosed && !s.remoteClosed {
+			s.cond.Wait()
+		}
+
+		if s.localClosed || s.remoteClosed {
+			s.l.Unlock()
+			return n, io.ErrClosedPipe
+		}
+
+		size := uint32(len(p))
+		if size > muxMaxDataSize {
+			size = muxMaxDataSize
+		}
+		if size > s.sendWindow {
+			size = s.sendWindow
+		}
+		s.sendWindow -= size
+		s.l.Unlock()
+
+		if err := s.mux.write(s.id, muxPacketData, p[:size]); err != nil {
+			return n, err
+		}
+
+		n += int(size)
+		p = p[size:]
+	}
+
+	return n, nil
+}
+
+func (s *Stream) push(data []byte) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	// If we closed our side, nobody will ever read this data.
+	if s.localClosed {
+		return nil
+	}
+
+	if s.buf.Len()+len(data) > muxWindowSize {
+		return errors.New("data received beyond the window")
+	}
+
+	s.buf.Write(data)
+	s.cond.Broadcast()
+	return nil
+}
+
+func (s *Stream) ack(n uint32) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.sendWindow += n
+	s.cond.Broadcast()
+}
+
+func (s *Stream) remoteClose() {
+	s.l.Lock()
+	s.remoteClosed = true
+	localClosed := s.localClosed
+	s.cond.Broadcast()
+	s.l.Unlock()
+
+	if localClosed {
+		s.mux.removeStream(s.id)
+	}
+}
+
+func (s *Stream) setSyn() {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if !s.synced {
+		s.synced = true
+		close(s.synCh)
+	}
+}
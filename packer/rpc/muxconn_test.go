@@ -0,0 +1,284 @@
+package rpc
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testMux(t *testing.T) (client *MuxConn, server *MuxConn) {
+	clientConn, serverConn := testConn(t)
+	return NewMuxConnClient(clientConn), NewMuxConnServer(serverConn)
+}
+
+func TestMuxConn(t *testing.T) {
+	client, server := testMux(t)
+	defer client.Close()
+	defer server.Close()
+
+	// When the server is done
+	doneCh := make(chan struct{})
+
+	go func() {
+		defer close(doneCh)
+
+		s0, err := server.Accept(0)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			return
+		}
+
+		s1, err := server.Accept(2)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			defer s0.Close()
+			data, err := ioutil.ReadAll(s0)
+			if err != nil || string(data) != "hello" {
+				t.Errorf("bad: %#v %s", string(data), err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			defer s1.Close()
+			data, err := ioutil.ReadAll(s1)
+			if err != nil || string(data) != "world" {
+				t.Errorf("bad: %#v %s", string(data), err)
+			}
+		}()
+
+		wg.Wait()
+	}()
+
+	s0, err := client.Dial(0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	s1, err := client.Dial(2)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := s0.Write([]byte("hello")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s0.Close()
+
+	if _, err := s1.Write([]byte("world")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	s1.Close()
+
+	<-doneCh
+}
+
+func TestMuxConn_duplex(t *testing.T) {
+	client, server := testMux(t)
+	defer client.Close()
+	defer server.Close()
+
+	id := server.NextId()
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+
+		s, err := server.Accept(id)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			return
+		}
+		defer s.Close()
+
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(s, buf); err != nil {
+			t.Errorf("err: %s", err)
+			return
+		}
+
+		if string(buf) != "ping" {
+			t.Errorf("bad: %s", buf)
+			return
+		}
+
+		if _, err := s.Write([]byte("pong")); err != nil {
+			t.Errorf("err: %s", err)
+		}
+	}()
+
+	s, err := client.Dial(id)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := s.Write([]byte("ping")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data := readStream(t, s)
+	if data != "pong" {
+		t.Fatalf("bad: %#v", data)
+	}
+
+	<-doneCh
+}
+
+func TestMuxConn_largeWrite(t *testing.T) {
+	client, server := testMux(t)
+	defer client.Close()
+	defer server.Close()
+
+	expected := bytes.Repeat([]byte("packer"), muxMaxDataSize)
+
+	id := client.NextId()
+	go func() {
+		s, err := server.Dial(id)
+		if err != nil {
+			t.Errorf("err: %s", err)
+			return
+		}
+		defer s.Close()
+
+		if _, err := s.Write(expected); err != nil {
+			t.Errorf("err: %s", err)
+		}
+	}()
+
+	s, err := client.Accept(id)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data := readStream(t, s)
+	if data != string(expected) {
+		t.Fatalf("bad length: %d", len(data))
+	}
+}
+
+func TestMuxConn_window(t *testing.T) {
+	client, server := testMux(t)
+	defer client.Close()
+	defer server.Close()
+
+	expected := bytes.Repeat([]byte("p"), muxWindowSize*2)
+
+	id := client.NextId()
+	s, err := client.Dial(id)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	writeCh := make(chan error)
+	go func() {
+		_, err := s.Write(expected)
+		s.Close()
+		writeCh <- err
+	}()
+
+	r, err := server.Accept(id)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Nothing is reading, so the write should stop at the window
+	select {
+	case err := <-writeCh:
+		t.Fatalf("write should block: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	stream := r.(*Stream)
+	stream.l.Lock()
+	buffered := stream.buf.Len()
+	stream.l.Unlock()
+	if buffered != muxWindowSize {
+		t.Fatalf("bad buffered: %d", buffered)
+	}
+
+	data := readStream(t, r)
+	if data != string(expected) {
+		t.Fatalf("bad length: %d", len(data))
+	}
+
+	if err := <-writeCh; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+}
+
+func TestMuxConn_nextId(t *testing.T) {
+	client, server := testMux(t)
+	defer client.Close()
+	defer server.Close()
+
+	seen := make(map[uint32]bool)
+	for i := 0; i < 10; i++ {
+		for _, id := range []uint32{client.NextId(), server.NextId()} {
+			if id == 0 {
+				t.Fatal("stream 0 is reserved")
+			}
+
+			if seen[id] {
+				t.Fatalf("duplicate id: %d", id)
+			}
+
+			seen[id] = true
+		}
+	}
+}
+
+func TestMuxConn_close(t *testing.T) {
+	client, server := testMux(t)
+	defer server.Close()
+
+	id := client.NextId()
+	errCh := make(chan error)
+	go func() {
+		_, err := client.Accept(id)
+		errCh <- err
+	}()
+
+	client.Close()
+	if err := <-errCh; err == nil {
+		t.Fatal("accept should fail after close")
+	}
+
+	if _, err := client.Dial(id); err == nil {
+		t.Fatal("dial should fail after close")
+	}
+}
+
+func TestMuxConn_remoteClose(t *testing.T) {
+	client, server := testMux(t)
+	defer client.Close()
+
+	s, err := client.Dial(0)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// Closing the other side should end any reads
+	server.Close()
+	data := readStream(t, s)
+	if data != "" {
+		t.Fatalf("bad: %#v", data)
+	}
+}
+
+func readStream(t *testing.T, s io.Reader) string {
+	data, err := ioutil.ReadAll(s)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return string(data)
+}
@@ -1,20 +1,18 @@
 package rpc
 
-import (
-	"github.com/mitchellh/packer/packer"
-	"net/rpc"
-)
+import "github.com/mitchellh/packer/packer"
 
 // An implementation of packer.PostProcessor where the PostProcessor is actually
 // executed over an RPC connection.
 type postProcessor struct {
-	client *rpc.Client
+	client *Client
 }
 
 // PostProcessorServer wraps a packer.PostProcessor implementation and makes it
 // exportable as part of a Golang RPC server.
 type PostProcessorServer struct {
-	p packer.PostProcessor
+	p   packer.PostProcessor
+	mux *MuxConn
 }
 
 type PostProcessorConfigureArgs struct {
@@ -22,12 +20,12 @@ type PostProcessorConfigureArgs struct {
 }
 
 type PostProcessorProcessResponse struct {
-	Err        error
-	Keep       bool
-	RPCAddress string
+	Err      error
+	Keep     bool
+	StreamId uint32
 }
 
-func PostProcessor(client *rpc.Client) *postProcessor {
+func PostProcessor(client *Client) *postProcessor {
 	return &postProcessor{client}
 }
 func (p *postProcessor) Configure(raw ...interface{}) (err error) {
@@ -40,12 +38,14 @@ func (p *postProcessor) Configure(raw ...interface{}) (err error) {
 }
 
 func (p *postProcessor) PostProcess(ui packer.Ui, a packer.Artifact) (packer.Artifact, bool, error) {
-	server := rpc.NewServer()
+	streamId := p.client.mux.NextId()
+	server := newServerWithMux(p.client.mux, streamId)
 	RegisterArtifact(server, a)
 	RegisterUi(server, ui)
+	go server.Serve()
 
 	var response PostProcessorProcessResponse
-	if err := p.client.Call("PostProcessor.PostProcess", serveSingleConn(server), &response); err != nil {
+	if err := p.client.Call("PostProcessor.PostProcess", streamId, &response); err != nil {
 		return nil, false, err
 	}
 
@@ -53,11 +53,11 @@ func (p *postProcessor) PostProcess(ui packer.Ui, a packer.Artifact) (packer.Art
 		return nil, false, response.Err
 	}
 
-	if response.RPCAddress == "" {
+	if response.StreamId == 0 {
 		return nil, false, nil
 	}
 
-	client, err := rpcDial(response.RPCAddress)
+	client, err := newClientWithMux(p.client.mux, response.StreamId)
 	if err != nil {
 		return nil, false, err
 	}
@@ -74,19 +74,19 @@ func (p *PostProcessorServer) Configure(args *PostProcessorConfigureArgs, reply
 	return nil
 }
 
-func (p *PostProcessorServer) PostProcess(address string, reply *PostProcessorProcessResponse) error {
-	client, err := rpcDial(address)
+func (p *PostProcessorServer) PostProcess(streamId uint32, reply *PostProcessorProcessResponse) error {
+	client, err := newClientWithMux(p.mux, streamId)
 	if err != nil {
-		return err
+		return NewBasicError(err)
 	}
 
-	responseAddress := ""
-
+	var responseStreamId uint32
 	artifact, keep, err := p.p.PostProcess(&Ui{client}, Artifact(client))
 	if err == nil && artifact != nil {
-		server := rpc.NewServer()
+		responseStreamId = p.mux.NextId()
+		server := newServerWithMux(p.mux, responseStreamId)
 		RegisterArtifact(server, artifact)
-		responseAddress = serveSingleConn(server)
+		go server.Serve()
 	}
 
 	if err != nil {
@@ -94,9 +94,9 @@ func (p *PostProcessorServer) PostProcess(address string, reply *PostProcessorPr
 	}
 
 	*reply = PostProcessorProcessResponse{
-		Err:        err,
-		Keep:       keep,
-		RPCAddress: responseAddress,
+		Err:      err,
+		Keep:     keep,
+		StreamId: responseStreamId,
 	}
 
 	return nil
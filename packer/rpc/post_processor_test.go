@@ -2,7 +2,6 @@ package rpc
 
 import (
 	"github.com/mitchellh/packer/packer"
-	"net/rpc"
 	"reflect"
 	"testing"
 )
@@ -34,21 +33,13 @@ func TestPostProcessorRPC(t *testing.T) {
 	// Create the interface to test
 	p := new(TestPostProcessor)
 
-	// Start the server
-	server := rpc.NewServer()
+	client, server := testClientServer(t)
 	RegisterPostProcessor(server, p)
-	address := serveSingleConn(server)
-
-	// Create the client over RPC and run some methods to verify it works
-	client, err := rpc.Dial("tcp", address)
-	if err != nil {
-		t.Fatalf("Error connecting to rpc: %s", err)
-	}
 
 	// Test Configure
 	config := 42
 	pClient := PostProcessor(client)
-	err = pClient.Configure(config)
+	err := pClient.Configure(config)
 	if err != nil {
 		t.Fatalf("error: %s", err)
 	}
@@ -3,19 +3,19 @@ package rpc
 import (
 	"github.com/mitchellh/packer/packer"
 	"log"
-	"net/rpc"
 )
 
 // An implementation of packer.Provisioner where the provisioner is actually
 // executed over an RPC connection.
 type provisioner struct {
-	client *rpc.Client
+	client *Client
 }
 
 // ProvisionerServer wraps a packer.Provisioner implementation and makes it
 // exportable as part of a Golang RPC server.
 type ProvisionerServer struct {
-	p packer.Provisioner
+	p   packer.Provisioner
+	mux *MuxConn
 }
 
 type ProvisionerPrepareArgs struct {
@@ -23,10 +23,10 @@ type ProvisionerPrepareArgs struct {
 }
 
 type ProvisionerProvisionArgs struct {
-	RPCAddress string
+	StreamId uint32
 }
 
-func Provisioner(client *rpc.Client) *provisioner {
+func Provisioner(client *Client) *provisioner {
 	return &provisioner{client}
 }
 func (p *provisioner) Prepare(configs ...interface{}) (err error) {
@@ -40,11 +40,14 @@ func (p *provisioner) Prepare(configs ...interface{}) (err error) {
 
 func (p *provisioner) Provision(ui packer.Ui, comm packer.Communicator) error {
 	// TODO: Error handling
-	server := rpc.NewServer()
+	streamId := p.client.mux.NextId()
+	server := newServerWithMux(p.client.mux, streamId)
 	RegisterCommunicator(server, comm)
 	RegisterUi(server, ui)
+	go server.Serve()
+	defer server.Close()
 
-	args := &ProvisionerProvisionArgs{serveSingleConn(server)}
+	args := &ProvisionerProvisionArgs{streamId}
 	return p.client.Call("Provisioner.Provision", args, new(interface{}))
 }
 
@@ -65,10 +68,11 @@ func (p *ProvisionerServer) Prepare(args *ProvisionerPrepareArgs, reply *error)
 }
 
 func (p *ProvisionerServer) Provision(args *ProvisionerProvisionArgs, reply *interface{}) error {
-	client, err := rpcDial(args.RPCAddress)
+	client, err := newClientWithMux(p.mux, args.StreamId)
 	if err != nil {
-		return err
+		return NewBasicError(err)
 	}
+	defer client.Close()
 
 	comm := Communicator(client)
 	ui := &Ui{client}
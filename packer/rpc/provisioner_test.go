@@ -2,7 +2,6 @@ package rpc
 
 import (
 	"github.com/mitchellh/packer/packer"
-	"net/rpc"
 	"reflect"
 	"testing"
 )
@@ -11,16 +10,8 @@ func TestProvisionerRPC(t *testing.T) {
 	// Create the interface to test
 	p := new(packer.MockProvisioner)
 
-	// Start the server
-	server := rpc.NewServer()
+	client, server := testClientServer(t)
 	RegisterProvisioner(server, p)
-	address := serveSingleConn(server)
-
-	// Create the client over RPC and run some methods to verify it works
-	client, err := rpc.Dial("tcp", address)
-	if err != nil {
-		t.Fatalf("err: %s", err)
-	}
 
 	// Test Prepare
 	config := 42
@@ -34,6 +25,11 @@ func TestProvisionerRPC(t *testing.T) {
 	}
 
 	// Test Provision
+	p.ProvFunc = func() error {
+		p.ProvUi.Say("foo")
+		return nil
+	}
+
 	ui := &testUi{}
 	comm := &packer.MockCommunicator{}
 	pClient.Provision(ui, comm)
@@ -41,7 +37,6 @@ func TestProvisionerRPC(t *testing.T) {
 		t.Fatal("should be called")
 	}
 
-	p.ProvUi.Say("foo")
 	if !ui.sayCalled {
 		t.Fatal("should be called")
 	}
@@ -2,87 +2,126 @@ package rpc
 
 import (
 	"github.com/mitchellh/packer/packer"
+	"io"
+	"log"
 	"net/rpc"
 )
 
+// Server represents an RPC server for Packer. It serves the components
+// registered on it over a single stream of a MuxConn. Components that
+// need to hand other objects across the connection, such as the Ui given
+// to a Builder, serve them on new streams of the same MuxConn rather than
+// opening new connections.
+type Server struct {
+	mux      *MuxConn
+	streamId uint32
+	server   *rpc.Server
+	closeMux bool
+}
+
+// NewServer returns a new Packer RPC server that serves over the given
+// connection, which must be paired with a Client on the other end.
+func NewServer(conn io.ReadWriteCloser) *Server {
+	result := newServerWithMux(NewMuxConnServer(conn), 0)
+	result.closeMux = true
+	return result
+}
+
+func newServerWithMux(mux *MuxConn, streamId uint32) *Server {
+	return &Server{
+		mux:      mux,
+		streamId: streamId,
+		server:   rpc.NewServer(),
+	}
+}
+
+// Close closes the connection the server is serving on if the server
+// was created with NewServer, and otherwise the stream it serves on.
+func (s *Server) Close() error {
+	if s.closeMux {
+		return s.mux.Close()
+	}
+
+	if stream := s.mux.stream(s.streamId); stream != nil {
+		return stream.Close()
+	}
+
+	return nil
+}
+
+// Serve waits for the client to connect and serves RPC calls until the
+// client disconnects. This blocks.
+func (s *Server) Serve() {
+	stream, err := s.mux.Accept(s.streamId)
+	if err != nil {
+		log.Printf("[ERR] Error retrieving stream for serving: %s", err)
+		return
+	}
+
+	s.server.ServeConn(stream)
+}
+
 // Registers the appropriate endpoint on an RPC server to serve an
 // Artifact.
-func RegisterArtifact(s *rpc.Server, a packer.Artifact) {
-	s.RegisterName("Artifact", &ArtifactServer{a})
+func RegisterArtifact(s *Server, a packer.Artifact) {
+	s.server.RegisterName("Artifact", &ArtifactServer{a})
 }
 
 // Registers the appropriate endpoint on an RPC server to serve a
 // Packer Build.
-func RegisterBuild(s *rpc.Server, b packer.Build) {
-	s.RegisterName("Build", &BuildServer{b})
+func RegisterBuild(s *Server, b packer.Build) {
+	s.server.RegisterName("Build", &BuildServer{b, s.mux})
 }
 
 // Registers the appropriate endpoint on an RPC server to serve a
 // Packer Builder.
-func RegisterBuilder(s *rpc.Server, b packer.Builder) {
-	s.RegisterName("Builder", &BuilderServer{b})
+func RegisterBuilder(s *Server, b packer.Builder) {
+	s.server.RegisterName("Builder", &BuilderServer{b, s.mux})
 }
 
 // Registers the appropriate endpoint on an RPC server to serve a
 // Packer Cache.
-func RegisterCache(s *rpc.Server, c packer.Cache) {
-	s.RegisterName("Cache", &CacheServer{c})
+func RegisterCache(s *Server, c packer.Cache) {
+	s.server.RegisterName("Cache", &CacheServer{c})
 }
 
 // Registers the appropriate endpoint on an RPC server to serve a
 // Packer Command.
-func RegisterCommand(s *rpc.Server, c packer.Command) {
-	s.RegisterName("Command", &CommandServer{c})
+func RegisterCommand(s *Server, c packer.Command) {
+	s.server.RegisterName("Command", &CommandServer{c, s.mux})
 }
 
 // Registers the appropriate endpoint on an RPC server to serve a
 // Packer Communicator.
-func RegisterCommunicator(s *rpc.Server, c packer.Communicator) {
-	s.RegisterName("Communicator", &CommunicatorServer{c})
+func RegisterCommunicator(s *Server, c packer.Communicator) {
+	s.server.RegisterName("Communicator", &CommunicatorServer{c, s.mux})
 }
 
 // Registers the appropriate endpoint on an RPC server to serve a
 // Packer Environment
-func RegisterEnvironment(s *rpc.Server, e packer.Environment) {
-	s.RegisterName("Environment", &EnvironmentServer{e})
+func RegisterEnvironment(s *Server, e packer.Environment) {
+	s.server.RegisterName("Environment", &EnvironmentServer{e, s.mux})
 }
 
 // Registers the appropriate endpoint on an RPC server to serve a
 // Hook.
-func RegisterHook(s *rpc.Server, hook packer.Hook) {
-	s.RegisterName("Hook", &HookServer{hook})
+func RegisterHook(s *Server, hook packer.Hook) {
+	s.server.RegisterName("Hook", &HookServer{hook, s.mux})
 }
 
 // Registers the appropriate endpoing on an RPC server to serve a
 // PostProcessor.
-func RegisterPostProcessor(s *rpc.Server, p packer.PostProcessor) {
-	s.RegisterName("PostProcessor", &PostProcessorServer{p})
+func RegisterPostProcessor(s *Server, p packer.PostProcessor) {
+	s.server.RegisterName("PostProcessor", &PostProcessorServer{p, s.mux})
 }
 
 // Registers the appropriate endpoint on an RPC server to serve a packer.Provisioner
-func RegisterProvisioner(s *rpc.Server, p packer.Provisioner) {
-	s.RegisterName("Provisioner", &ProvisionerServer{p})
+func RegisterProvisioner(s *Server, p packer.Provisioner) {
+	s.server.RegisterName("Provisioner", &ProvisionerServer{p, s.mux})
 }
 
 // Registers the appropriate endpoint on an RPC server to serve a
 // Packer UI
-func RegisterUi(s *rpc.Server, ui packer.Ui) {
-	s.RegisterName("Ui", &UiServer{ui})
-}
-
-func serveSingleConn(s *rpc.Server) string {
-	l := netListenerInRange(portRangeMin, portRangeMax)
-
-	// Accept a single connection in a goroutine and then exit
-	go func() {
-		defer l.Close()
-		conn, err := l.Accept()
-		if err != nil {
-			panic(err)
-		}
-
-		s.ServeConn(conn)
-	}()
-
-	return l.Addr().String()
+func RegisterUi(s *Server, ui packer.Ui) {
+	s.server.RegisterName("Ui", &UiServer{ui})
 }
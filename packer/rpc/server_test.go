@@ -1 +1,50 @@
 package rpc
+
+import (
+	"net"
+	"testing"
+)
+
+func testConn(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer l.Close()
+
+	var serverConn net.Conn
+	doneCh := make(chan error)
+	go func() {
+		var err error
+		serverConn, err = l.Accept()
+		doneCh <- err
+	}()
+
+	clientConn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := <-doneCh; err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return clientConn, serverConn
+}
+
+// testClientServer returns a connected Client and Server. The server is
+// already serving, so components must be registered before the client
+// makes any calls.
+func testClientServer(t *testing.T) (*Client, *Server) {
+	clientConn, serverConn := testConn(t)
+
+	server := NewServer(serverConn)
+	go server.Serve()
+
+	client, err := NewClient(clientConn)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return client, server
+}
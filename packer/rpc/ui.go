@@ -3,13 +3,12 @@ package rpc
 import (
 	"github.com/mitchellh/packer/packer"
 	"log"
-	"net/rpc"
 )
 
 // An implementation of packer.Ui where the Ui is actually executed
 // over an RPC connection.
 type Ui struct {
-	client *rpc.Client
+	client *Client
 }
 
 // UiServer wraps a packer.Ui implementation and makes it exportable
@@ -1,7 +1,6 @@
 package rpc
 
 import (
-	"net/rpc"
 	"reflect"
 	"testing"
 )
@@ -51,16 +50,8 @@ func TestUiRPC(t *testing.T) {
 	// Create the UI to test
 	ui := new(testUi)
 
-	// Start the RPC server
-	server := rpc.NewServer()
+	client, server := testClientServer(t)
 	RegisterUi(server, ui)
-	address := serveSingleConn(server)
-
-	// Create the client over RPC and run some methods to verify it works
-	client, err := rpc.Dial("tcp", address)
-	if err != nil {
-		panic(err)
-	}
 
 	uiClient := &Ui{client}
 
@@ -0,0 +1,285 @@
+package amazonimport
+
+import (
+	"fmt"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/goamz/s3"
+	awscommon "github.com/mitchellh/packer/builder/amazon/common"
+	"github.com/mitchellh/packer/common"
+	"github.com/mitchellh/packer/packer"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// The unique ID for the artifacts of this post-processor.
+const BuilderId = "packer.post-processor.amazon-import"
+
+// The size of the parts the OVA is uploaded to S3 in. Parts must be at
+// least 5 MB, except for the last one.
+var uploadPartSize int64 = 64 * 1024 * 1024
+
+// These are the builders whose artifacts can be imported, as long as they
+// have been exported to an OVA.
+var builtins = map[string]string{
+	"mitchellh.virtualbox": "virtualbox",
+	"mitchellh.vmware":     "vmware",
+}
+
+type Config struct {
+	common.PackerConfig    `mapstructure:",squash"`
+	awscommon.AccessConfig `mapstructure:",squash"`
+
+	S3Bucket    string            `mapstructure:"s3_bucket_name"`
+	S3Key       string            `mapstructure:"s3_key_name"`
+	SkipClean   bool              `mapstructure:"skip_clean"`
+	Tags        map[string]string `mapstructure:"tags"`
+	LicenseType string            `mapstructure:"license_type"`
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	md, err := common.DecodeConfig(&p.config, raws...)
+	if err != nil {
+		return err
+	}
+
+	tpl, err := packer.NewConfigTemplate()
+	if err != nil {
+		return err
+	}
+	tpl.UserVars = p.config.PackerUserVars
+
+	if p.config.S3Key == "" {
+		p.config.S3Key = "packer-import-{{timestamp}}.ova"
+	}
+
+	// Accumulate any errors
+	errs := common.CheckUnusedConfig(md)
+	errs = packer.MultiErrorAppend(errs, p.config.AccessConfig.Prepare(tpl)...)
+
+	templates := map[string]*string{
+		"s3_bucket_name": &p.config.S3Bucket,
+		"s3_key_name":    &p.config.S3Key,
+		"license_type":   &p.config.LicenseType,
+	}
+
+	for n, ptr := range templates {
+		var err error
+		*ptr, err = tpl.Process(*ptr, nil)
+		if err != nil {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("Error processing %s: %s", n, err))
+		}
+	}
+
+	newTags := make(map[string]string)
+	for k, v := range p.config.Tags {
+		k, err := tpl.Process(k, nil)
+		if err != nil {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("Error processing tag key %s: %s", k, err))
+			continue
+		}
+
+		v, err := tpl.Process(v, nil)
+		if err != nil {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("Error processing tag value '%s': %s", v, err))
+			continue
+		}
+
+		newTags[k] = v
+	}
+
+	p.config.Tags = newTags
+
+	if p.config.S3Bucket == "" {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("s3_bucket_name must be set"))
+	}
+
+	switch p.config.LicenseType {
+	case "", "AWS", "BYOL":
+	default:
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("license_type must be \"AWS\" or \"BYOL\""))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	if _, ok := builtins[artifact.BuilderId()]; !ok {
+		return nil, false, fmt.Errorf("Unknown artifact type, can't import: %s", artifact.BuilderId())
+	}
+
+	ova := ""
+	for _, path := range artifact.Files() {
+		if strings.HasSuffix(path, ".ova") {
+			ova = path
+			break
+		}
+	}
+
+	if ova == "" {
+		return nil, false, fmt.Errorf(
+			"OVA file not found. Set the format of the %s builder to \"ova\".",
+			builtins[artifact.BuilderId()])
+	}
+
+	auth, err := p.config.AccessConfig.Auth()
+	if err != nil {
+		return nil, false, err
+	}
+
+	region, err := p.config.AccessConfig.Region()
+	if err != nil {
+		return nil, false, err
+	}
+
+	ec2conn := ec2.New(auth, region)
+	bucket := s3.New(auth, region).Bucket(p.config.S3Bucket)
+
+	result, err := p.importOVA(ui, ova, ec2conn, bucket)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return result, false, nil
+}
+
+// importOVA uploads the OVA to S3, imports it as an AMI and waits for the
+// import to finish. The uploaded OVA is deleted afterwards, whether the
+// import succeeded or not, unless SkipClean is set.
+func (p *PostProcessor) importOVA(
+	ui packer.Ui, ova string, ec2conn *ec2.EC2, bucket *s3.Bucket) (*awscommon.Artifact, error) {
+	ui.Say(fmt.Sprintf("Uploading %s to s3://%s/%s...", ova, bucket.Name, p.config.S3Key))
+	if err := uploadFile(bucket, p.config.S3Key, ova); err != nil {
+		return nil, fmt.Errorf("Error uploading OVA to S3: %s", err)
+	}
+
+	if !p.config.SkipClean {
+		defer func() {
+			ui.Message(fmt.Sprintf(
+				"Deleting s3://%s/%s...", bucket.Name, p.config.S3Key))
+			if err := bucket.Del(p.config.S3Key); err != nil {
+				ui.Error(fmt.Sprintf(
+					"Error deleting s3://%s/%s: %s", bucket.Name, p.config.S3Key, err))
+			}
+		}()
+	}
+
+	ui.Say("Starting the import of the OVA as an AMI...")
+	taskId, err := awscommon.ImportImage(ec2conn,
+		fmt.Sprintf("Packer import of %s", p.config.S3Key),
+		p.config.LicenseType,
+		[]awscommon.ImportImageDisk{
+			{
+				Description: p.config.S3Key,
+				Format:      "ova",
+				S3Bucket:    bucket.Name,
+				S3Key:       p.config.S3Key,
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("Error starting the import: %s", err)
+	}
+
+	ui.Message(fmt.Sprintf("Waiting for import task (%s) to complete...", taskId))
+	ui.Message("It is normal for this to take an hour or more.")
+	stateChange := awscommon.StateChangeConf{
+		Conn:    ec2conn,
+		Pending: []string{"pending", "active"},
+		Refresh: awscommon.ImportImageStateRefreshFunc(ec2conn, taskId),
+		Target:  "completed",
+	}
+
+	raw, err := awscommon.WaitForState(&stateChange)
+	if err != nil {
+		return nil, fmt.Errorf("Error waiting for import task (%s): %s", taskId, err)
+	}
+
+	imageId := raw.(*awscommon.ImportImageTask).ImageId
+	ui.Message(fmt.Sprintf("Imported AMI: %s", imageId))
+
+	if len(p.config.Tags) > 0 {
+		ui.Say(fmt.Sprintf("Adding tags to AMI (%s)...", imageId))
+		if _, err := ec2conn.CreateTags([]string{imageId}, p.tags()); err != nil {
+			return nil, fmt.Errorf("Error adding tags to AMI (%s): %s", imageId, err)
+		}
+	}
+
+	return &awscommon.Artifact{
+		Amis:           map[string]string{ec2conn.Region.Name: imageId},
+		BuilderIdValue: BuilderId,
+		Conn:           ec2conn,
+	}, nil
+}
+
+// tags returns the configured tags as EC2 tags, sorted by key.
+func (p *PostProcessor) tags() []ec2.Tag {
+	keys := make([]string, 0, len(p.config.Tags))
+	for k := range p.config.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]ec2.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, ec2.Tag{Key: k, Value: p.config.Tags[k]})
+	}
+
+	return tags
+}
+
+// uploadFile uploads the file with a multipart upload, since a single
+// PUT to S3 is limited to 5 GB, which OVAs often exceed.
+func uploadFile(bucket *s3.Bucket, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// An upload has at most 10,000 parts, so the parts of large files
+	// have to be bigger.
+	partSize := uploadPartSize
+	if min := (fi.Size() + 9999) / 10000; min > partSize {
+		partSize = min
+	}
+
+	log.Printf("Uploading %d bytes from %s in parts of %d bytes", fi.Size(), path, partSize)
+	multi, err := bucket.InitMulti(key, "application/octet-stream", s3.Private)
+	if err != nil {
+		return err
+	}
+
+	parts, err := multi.PutAll(f, partSize)
+	if err == nil {
+		err = multi.Complete(parts)
+	}
+
+	if err != nil {
+		if abortErr := multi.Abort(); abortErr != nil {
+			log.Printf("Error aborting upload of %s: %s", key, abortErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,415 @@
+package amazonimport
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/mitchellh/goamz/aws"
+	"github.com/mitchellh/goamz/ec2"
+	"github.com/mitchellh/goamz/s3"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"region":         "us-east-1",
+		"s3_bucket_name": "packer-imports",
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+type testArtifact struct {
+	builderId string
+	files     []string
+}
+
+func (a *testArtifact) BuilderId() string { return a.builderId }
+func (a *testArtifact) Files() []string   { return a.files }
+func (*testArtifact) Id() string          { return "id" }
+func (*testArtifact) String() string      { return "string" }
+func (*testArtifact) Destroy() error      { return nil }
+
+// fakeAWS is a local stand-in for the S3 and EC2 APIs. Objects are
+// uploaded with multipart uploads and DELETEd under /bucket/key, and EC2
+// actions come in as queries. The import task stays active for the given
+// number of polls before ending up in the final status.
+type fakeAWS struct {
+	sync.Mutex
+
+	objects     map[string][]byte
+	parts       map[string][]byte
+	partCount   int
+	actions     []url.Values
+	activePolls int
+	finalStatus string
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	r.ParseForm()
+	if r.URL.Path != "/" {
+		f.serveS3(w, r)
+		return
+	}
+
+	f.actions = append(f.actions, r.Form)
+
+	w.Header().Set("Content-Type", "text/xml")
+	switch r.Form.Get("Action") {
+	case "ImportImage":
+		w.Write([]byte(`<ImportImageResponse>
+  <importTaskId>import-ami-12345</importTaskId>
+  <status>active</status>
+  <progress>2</progress>
+</ImportImageResponse>`))
+	case "DescribeImportImageTasks":
+		status, imageId := "active", ""
+		if f.activePolls > 0 {
+			f.activePolls--
+		} else {
+			status = f.finalStatus
+			if status == "completed" {
+				imageId = "ami-12345"
+			}
+		}
+
+		w.Write([]byte(`<DescribeImportImageTasksResponse>
+  <importImageTaskSet>
+    <item>
+      <importTaskId>import-ami-12345</importTaskId>
+      <imageId>` + imageId + `</imageId>
+      <status>` + status + `</status>
+      <statusMessage>ClientError: Unknown OS</statusMessage>
+    </item>
+  </importImageTaskSet>
+</DescribeImportImageTasksResponse>`))
+	default:
+		w.Write([]byte(`<Response><return>true</return></Response>`))
+	}
+}
+
+func (f *fakeAWS) serveS3(w http.ResponseWriter, r *http.Request) {
+	_, uploads := r.Form["uploads"]
+	uploadId := r.Form.Get("uploadId")
+
+	switch {
+	case r.Method == "POST" && uploads:
+		f.parts = make(map[string][]byte)
+		w.Write([]byte(`<InitiateMultipartUploadResult>
+  <Bucket>packer-imports</Bucket>
+  <Key>` + strings.TrimPrefix(r.URL.Path, "/packer-imports/") + `</Key>
+  <UploadId>upload-1</UploadId>
+</InitiateMultipartUploadResult>`))
+	case r.Method == "GET" && uploadId != "":
+		w.Write([]byte(`<ListPartsResult>
+  <UploadId>upload-1</UploadId>
+  <IsTruncated>false</IsTruncated>
+</ListPartsResult>`))
+	case r.Method == "PUT" && uploadId != "":
+		n := r.Form.Get("partNumber")
+		f.parts[n], _ = ioutil.ReadAll(r.Body)
+		f.partCount++
+		w.Header().Set("ETag", `"etag-`+n+`"`)
+	case r.Method == "POST" && uploadId != "":
+		var complete struct {
+			Parts []struct {
+				N string `xml:"PartNumber"`
+			} `xml:"Part"`
+		}
+		xml.NewDecoder(r.Body).Decode(&complete)
+
+		var data []byte
+		for _, part := range complete.Parts {
+			data = append(data, f.parts[part.N]...)
+		}
+		f.objects[r.URL.Path] = data
+		w.Write([]byte(`<CompleteMultipartUploadResult>
+  <Key>` + strings.TrimPrefix(r.URL.Path, "/packer-imports/") + `</Key>
+</CompleteMultipartUploadResult>`))
+	case r.Method == "PUT":
+		// Objects must be uploaded in parts, since S3 limits single
+		// uploads to 5 GB.
+		w.WriteHeader(http.StatusBadRequest)
+	case r.Method == "DELETE":
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (f *fakeAWS) actionNames() []string {
+	f.Lock()
+	defer f.Unlock()
+
+	result := make([]string, len(f.actions))
+	for i, v := range f.actions {
+		result[i] = v.Get("Action")
+	}
+
+	return result
+}
+
+func testAWS(t *testing.T, finalStatus string) (*ec2.EC2, *s3.Bucket, *fakeAWS, func()) {
+	fake := &fakeAWS{
+		objects:     make(map[string][]byte),
+		activePolls: 1,
+		finalStatus: finalStatus,
+	}
+	server := httptest.NewServer(fake)
+
+	region := aws.Region{
+		Name:        "us-east-1",
+		EC2Endpoint: server.URL,
+		S3Endpoint:  server.URL,
+	}
+
+	auth := aws.Auth{AccessKey: "foo", SecretKey: "bar"}
+	bucket := s3.New(auth, region).Bucket("packer-imports")
+	return ec2.New(auth, region), bucket, fake, server.Close
+}
+
+func testOVA(t *testing.T) string {
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer tf.Close()
+
+	if _, err := tf.Write([]byte("ova")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return tf.Name()
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !strings.HasPrefix(p.config.S3Key, "packer-import-") ||
+		!strings.HasSuffix(p.config.S3Key, ".ova") {
+		t.Fatalf("bad: %s", p.config.S3Key)
+	}
+}
+
+func TestPostProcessorConfigure_S3Bucket(t *testing.T) {
+	var p PostProcessor
+	c := testConfig()
+	delete(c, "s3_bucket_name")
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorConfigure_LicenseType(t *testing.T) {
+	for _, lt := range []string{"AWS", "BYOL"} {
+		var p PostProcessor
+		c := testConfig()
+		c["license_type"] = lt
+		if err := p.Configure(c); err != nil {
+			t.Fatalf("%s: err: %s", lt, err)
+		}
+	}
+
+	var p PostProcessor
+	c := testConfig()
+	c["license_type"] = "foo"
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorConfigure_BadKey(t *testing.T) {
+	var p PostProcessor
+	c := testConfig()
+	c["i_should_not_be_valid"] = true
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorPostProcess_badArtifact(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{builderId: "packer.docker", files: []string{"foo.ova"}}
+	if _, _, err := p.PostProcess(testUi(), artifact); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorPostProcess_noOVA(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{
+		builderId: "mitchellh.virtualbox",
+		files:     []string{"foo.ovf", "foo-disk1.vmdk"},
+	}
+	_, _, err := p.PostProcess(testUi(), artifact)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.Error(), "OVA") {
+		t.Fatalf("bad: %s", err)
+	}
+}
+
+func TestPostProcessorImportOVA(t *testing.T) {
+	conn, bucket, fake, closeFn := testAWS(t, "completed")
+	defer closeFn()
+
+	ova := testOVA(t)
+	defer os.Remove(ova)
+
+	var p PostProcessor
+	c := testConfig()
+	c["s3_key_name"] = "imports/foo.ova"
+	c["tags"] = map[string]string{"OS": "Ubuntu"}
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact, err := p.importOVA(testUi(), ova, conn, bucket)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if artifact.BuilderId() != BuilderId {
+		t.Fatalf("bad: %s", artifact.BuilderId())
+	}
+	if artifact.Id() != "us-east-1:ami-12345" {
+		t.Fatalf("bad: %s", artifact.Id())
+	}
+
+	expected := []string{
+		"ImportImage", "DescribeImportImageTasks", "DescribeImportImageTasks", "CreateTags",
+	}
+	if actual := fake.actionNames(); strings.Join(actual, ",") != strings.Join(expected, ",") {
+		t.Fatalf("bad: %#v", actual)
+	}
+
+	importImage := fake.actions[0]
+	if importImage.Get("DiskContainer.1.Format") != "ova" ||
+		importImage.Get("DiskContainer.1.UserBucket.S3Bucket") != "packer-imports" ||
+		importImage.Get("DiskContainer.1.UserBucket.S3Key") != "imports/foo.ova" {
+		t.Fatalf("bad: %#v", importImage)
+	}
+
+	createTags := fake.actions[3]
+	if createTags.Get("ResourceId.1") != "ami-12345" ||
+		createTags.Get("Tag.1.Key") != "OS" || createTags.Get("Tag.1.Value") != "Ubuntu" {
+		t.Fatalf("bad: %#v", createTags)
+	}
+
+	// The OVA is deleted from S3 once it is imported
+	if len(fake.objects) != 0 {
+		t.Fatalf("bad: %#v", fake.objects)
+	}
+}
+
+func TestPostProcessorImportOVA_failed(t *testing.T) {
+	conn, bucket, fake, closeFn := testAWS(t, "deleted")
+	defer closeFn()
+
+	ova := testOVA(t)
+	defer os.Remove(ova)
+
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	_, err := p.importOVA(testUi(), ova, conn, bucket)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.Error(), "Unknown OS") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	// The OVA is cleaned up even though the import failed
+	if len(fake.objects) != 0 {
+		t.Fatalf("bad: %#v", fake.objects)
+	}
+}
+
+func TestPostProcessorImportOVA_skipClean(t *testing.T) {
+	conn, bucket, fake, closeFn := testAWS(t, "completed")
+	defer closeFn()
+
+	ova := testOVA(t)
+	defer os.Remove(ova)
+
+	var p PostProcessor
+	c := testConfig()
+	c["s3_key_name"] = "foo.ova"
+	c["skip_clean"] = true
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := p.importOVA(testUi(), ova, conn, bucket); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(fake.objects["/packer-imports/foo.ova"]) != "ova" {
+		t.Fatalf("bad: %#v", fake.objects)
+	}
+}
+
+func TestPostProcessorImportOVA_multipart(t *testing.T) {
+	conn, bucket, fake, closeFn := testAWS(t, "completed")
+	defer closeFn()
+
+	ova := testOVA(t)
+	defer os.Remove(ova)
+
+	defer func(size int64) { uploadPartSize = size }(uploadPartSize)
+	uploadPartSize = 2
+
+	var p PostProcessor
+	c := testConfig()
+	c["s3_key_name"] = "foo.ova"
+	c["skip_clean"] = true
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, err := p.importOVA(testUi(), ova, conn, bucket); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if fake.partCount != 2 {
+		t.Fatalf("bad: %d", fake.partCount)
+	}
+	if string(fake.objects["/packer-imports/foo.ova"]) != "ova" {
+		t.Fatalf("bad: %#v", fake.objects)
+	}
+}
@@ -0,0 +1,58 @@
+package vagrantcatalog
+
+import (
+	"fmt"
+	"os"
+)
+
+const BuilderId = "packer.post-processor.vagrant-catalog"
+
+// Artifact is a box added to a catalog.
+type Artifact struct {
+	// The path of the box in the catalog, and the URL it's served at
+	Path string
+	URL  string
+
+	// The metadata.json the box was added to
+	MetadataPath string
+
+	Name     string
+	Version  string
+	Provider string
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (a *Artifact) Files() []string {
+	return []string{a.Path}
+}
+
+func (a *Artifact) Id() string {
+	return a.URL
+}
+
+func (a *Artifact) String() string {
+	return fmt.Sprintf("'%s' provider of box '%s' version %s: %s",
+		a.Provider, a.Name, a.Version, a.URL)
+}
+
+// Destroy removes the box from the catalog, and the metadata.json entry
+// that points to it.
+func (a *Artifact) Destroy() error {
+	catalogLock.Lock()
+	defer catalogLock.Unlock()
+
+	c, err := readCatalog(a.MetadataPath)
+	if err != nil {
+		return err
+	}
+
+	c.removeProvider(a.Version, a.Provider)
+	if err := c.write(a.MetadataPath); err != nil {
+		return err
+	}
+
+	return os.Remove(a.Path)
+}
@@ -0,0 +1,113 @@
+package vagrantcatalog
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// boxProvider reads the provider of a box from the metadata.json in it.
+// Boxes are tar files, which may be gzipped.
+func boxProvider(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+	magic, err := r.(*bufio.Reader).Peek(2)
+	if err != nil {
+		return "", err
+	}
+
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gzr, err := gzip.NewReader(r)
+		if err != nil {
+			return "", err
+		}
+		defer gzr.Close()
+
+		r = gzr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if filepath.Clean(hdr.Name) != "metadata.json" {
+			continue
+		}
+
+		var metadata struct {
+			Provider string `json:"provider"`
+		}
+		if err := json.NewDecoder(tr).Decode(&metadata); err != nil {
+			return "", fmt.Errorf("Error decoding metadata.json: %s", err)
+		}
+
+		if metadata.Provider == "" {
+			return "", fmt.Errorf("No provider in metadata.json")
+		}
+
+		return metadata.Provider, nil
+	}
+
+	return "", fmt.Errorf("No metadata.json found in box")
+}
+
+// copyBox copies the box to dst, returning its SHA256 checksum. If the
+// box already is at dst, it is only checksummed.
+func copyBox(dst, src string) (string, error) {
+	srcF, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer srcF.Close()
+
+	hash := sha256.New()
+	var w io.Writer = hash
+
+	srcAbs, err := filepath.Abs(src)
+	if err != nil {
+		return "", err
+	}
+	dstAbs, err := filepath.Abs(dst)
+	if err != nil {
+		return "", err
+	}
+
+	if srcAbs != dstAbs {
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return "", err
+		}
+
+		dstF, err := os.Create(dst)
+		if err != nil {
+			return "", err
+		}
+		defer dstF.Close()
+
+		w = io.MultiWriter(hash, dstF)
+	}
+
+	if _, err := io.Copy(w, srcF); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
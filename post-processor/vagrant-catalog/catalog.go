@@ -0,0 +1,131 @@
+package vagrantcatalog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// catalogLock serializes the updates of catalogs, since the builds of a
+// template are post-processed in parallel.
+var catalogLock sync.Mutex
+
+// catalog is the metadata.json of a box, which lists the versions of the
+// box and the providers of each version. Vagrant reads it when the URL of
+// the box is added with `vagrant box add`.
+type catalog struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Versions    []*catalogVersion `json:"versions"`
+}
+
+type catalogVersion struct {
+	Version     string             `json:"version"`
+	Description string             `json:"description,omitempty"`
+	Providers   []*catalogProvider `json:"providers"`
+}
+
+type catalogProvider struct {
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	ChecksumType string `json:"checksum_type"`
+	Checksum     string `json:"checksum"`
+}
+
+// readCatalog reads the catalog at the path, or returns an empty catalog
+// if it doesn't exist yet.
+func readCatalog(path string) (*catalog, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &catalog{Versions: []*catalogVersion{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// write writes the catalog to the path. The file is replaced in one go,
+// so it's never read half written.
+func (c *catalog) write(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// addProvider adds the provider to the version, adding the version if
+// it doesn't exist yet. An existing provider of the same name is
+// replaced.
+func (c *catalog) addProvider(version, description string, p *catalogProvider) {
+	var v *catalogVersion
+	for _, existing := range c.Versions {
+		if existing.Version == version {
+			v = existing
+			break
+		}
+	}
+
+	if v == nil {
+		v = &catalogVersion{Version: version}
+		c.Versions = append(c.Versions, v)
+	}
+
+	if description != "" {
+		v.Description = description
+	}
+
+	for i, existing := range v.Providers {
+		if existing.Name == p.Name {
+			v.Providers[i] = p
+			return
+		}
+	}
+
+	v.Providers = append(v.Providers, p)
+}
+
+// removeProvider removes the provider from the version, and the version
+// if it has no providers left.
+func (c *catalog) removeProvider(version, name string) {
+	versions := make([]*catalogVersion, 0, len(c.Versions))
+	for _, v := range c.Versions {
+		if v.Version == version {
+			providers := make([]*catalogProvider, 0, len(v.Providers))
+			for _, p := range v.Providers {
+				if p.Name != name {
+					providers = append(providers, p)
+				}
+			}
+
+			if len(providers) == 0 {
+				continue
+			}
+
+			v.Providers = providers
+		}
+
+		versions = append(versions, v)
+	}
+
+	c.Versions = versions
+}
@@ -0,0 +1,45 @@
+package vagrantcatalog
+
+import (
+	"testing"
+)
+
+func TestCatalogAddProvider(t *testing.T) {
+	c := &catalog{}
+
+	c.addProvider("1.0.0", "First", &catalogProvider{Name: "virtualbox", Checksum: "a"})
+	c.addProvider("1.0.0", "", &catalogProvider{Name: "vmware_desktop", Checksum: "b"})
+	c.addProvider("1.0.0", "", &catalogProvider{Name: "virtualbox", Checksum: "c"})
+
+	if len(c.Versions) != 1 {
+		t.Fatalf("bad: %#v", c.Versions)
+	}
+
+	v := c.Versions[0]
+	if v.Description != "First" {
+		t.Fatalf("bad: %s", v.Description)
+	}
+	if len(v.Providers) != 2 {
+		t.Fatalf("bad: %#v", v.Providers)
+	}
+	if v.Providers[0].Checksum != "c" {
+		t.Fatalf("provider should be replaced: %#v", v.Providers[0])
+	}
+}
+
+func TestCatalogRemoveProvider(t *testing.T) {
+	c := &catalog{}
+	c.addProvider("1.0.0", "", &catalogProvider{Name: "virtualbox"})
+	c.addProvider("1.1.0", "", &catalogProvider{Name: "virtualbox"})
+	c.addProvider("1.1.0", "", &catalogProvider{Name: "vmware_desktop"})
+
+	c.removeProvider("1.1.0", "virtualbox")
+	if len(c.Versions) != 2 || len(c.Versions[1].Providers) != 1 {
+		t.Fatalf("bad: %#v", c.Versions)
+	}
+
+	c.removeProvider("1.0.0", "virtualbox")
+	if len(c.Versions) != 1 || c.Versions[0].Version != "1.1.0" {
+		t.Fatalf("bad: %#v", c.Versions)
+	}
+}
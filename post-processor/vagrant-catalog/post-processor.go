@@ -0,0 +1,180 @@
+// vagrantcatalog implements the packer.PostProcessor interface and adds
+// Vagrant boxes to a catalog: a directory of boxes, with a metadata.json
+// per box that lists its versions. Served over HTTP, the catalog gives
+// versioned boxes without Vagrant Cloud.
+package vagrantcatalog
+
+import (
+	"fmt"
+	"github.com/mitchellh/packer/common"
+	"github.com/mitchellh/packer/packer"
+	"github.com/mitchellh/packer/post-processor/vagrant"
+	"path/filepath"
+	"strings"
+)
+
+const defaultBoxPath = "{{.Name}}/{{.Version}}/{{.Provider}}.box"
+
+type Config struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	BoxName            string `mapstructure:"box_name"`
+	Description        string `mapstructure:"description"`
+	Version            string `mapstructure:"version"`
+	VersionDescription string `mapstructure:"version_description"`
+	OutputDir          string `mapstructure:"output_directory"`
+	BaseURL            string `mapstructure:"base_url"`
+	BoxPath            string `mapstructure:"box_path"`
+
+	tpl *packer.ConfigTemplate
+}
+
+type BoxPathTemplate struct {
+	Name     string
+	Version  string
+	Provider string
+}
+
+type PostProcessor struct {
+	config Config
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	md, err := common.DecodeConfig(&p.config, raws...)
+	if err != nil {
+		return err
+	}
+
+	p.config.tpl, err = packer.NewConfigTemplate()
+	if err != nil {
+		return err
+	}
+	p.config.tpl.UserVars = p.config.PackerUserVars
+
+	if p.config.BoxPath == "" {
+		p.config.BoxPath = defaultBoxPath
+	}
+
+	// Accumulate any errors
+	errs := common.CheckUnusedConfig(md)
+
+	templates := map[string]*string{
+		"box_name":            &p.config.BoxName,
+		"description":         &p.config.Description,
+		"version":             &p.config.Version,
+		"version_description": &p.config.VersionDescription,
+		"output_directory":    &p.config.OutputDir,
+		"base_url":            &p.config.BaseURL,
+	}
+
+	for n, ptr := range templates {
+		var err error
+		*ptr, err = p.config.tpl.Process(*ptr, nil)
+		if err != nil {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("Error processing %s: %s", n, err))
+		}
+	}
+
+	if err := p.config.tpl.Validate(p.config.BoxPath); err != nil {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("Error parsing box_path: %s", err))
+	}
+
+	required := map[string]*string{
+		"box_name":         &p.config.BoxName,
+		"version":          &p.config.Version,
+		"output_directory": &p.config.OutputDir,
+		"base_url":         &p.config.BaseURL,
+	}
+
+	for n, ptr := range required {
+		if *ptr == "" {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("%s must be set", n))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	if artifact.BuilderId() != vagrant.BuilderId {
+		return nil, false, fmt.Errorf(
+			"Unknown artifact type, requires a box from the vagrant post-processor: %s",
+			artifact.BuilderId())
+	}
+
+	box := ""
+	for _, path := range artifact.Files() {
+		if strings.HasSuffix(path, ".box") {
+			box = path
+			break
+		}
+	}
+
+	if box == "" {
+		return nil, false, fmt.Errorf("No box found in artifact")
+	}
+
+	provider, err := boxProvider(box)
+	if err != nil {
+		return nil, false, fmt.Errorf("Error reading box %s: %s", box, err)
+	}
+
+	boxPath, err := p.config.tpl.Process(p.config.BoxPath, &BoxPathTemplate{
+		Name:     p.config.BoxName,
+		Version:  p.config.Version,
+		Provider: provider,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("Error processing box_path: %s", err)
+	}
+
+	path := filepath.Join(p.config.OutputDir, filepath.FromSlash(boxPath))
+	ui.Message(fmt.Sprintf("Copying '%s' box to %s", provider, path))
+	checksum, err := copyBox(path, box)
+	if err != nil {
+		return nil, false, fmt.Errorf("Error copying box: %s", err)
+	}
+
+	a := &Artifact{
+		Path:         path,
+		URL:          strings.TrimRight(p.config.BaseURL, "/") + "/" + boxPath,
+		MetadataPath: filepath.Join(p.config.OutputDir, filepath.FromSlash(p.config.BoxName), "metadata.json"),
+		Name:         p.config.BoxName,
+		Version:      p.config.Version,
+		Provider:     provider,
+	}
+
+	ui.Message(fmt.Sprintf("Adding version %s to %s", a.Version, a.MetadataPath))
+	catalogLock.Lock()
+	defer catalogLock.Unlock()
+
+	c, err := readCatalog(a.MetadataPath)
+	if err != nil {
+		return nil, false, fmt.Errorf("Error reading %s: %s", a.MetadataPath, err)
+	}
+
+	c.Name = p.config.BoxName
+	if p.config.Description != "" {
+		c.Description = p.config.Description
+	}
+
+	c.addProvider(a.Version, p.config.VersionDescription, &catalogProvider{
+		Name:         provider,
+		URL:          a.URL,
+		ChecksumType: "sha256",
+		Checksum:     checksum,
+	})
+
+	if err := c.write(a.MetadataPath); err != nil {
+		return nil, false, fmt.Errorf("Error writing %s: %s", a.MetadataPath, err)
+	}
+
+	return a, false, nil
+}
@@ -0,0 +1,261 @@
+package vagrantcatalog
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/mitchellh/packer/packer"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testArtifact struct {
+	builderId string
+	files     []string
+}
+
+func (a *testArtifact) BuilderId() string { return a.builderId }
+func (a *testArtifact) Files() []string   { return a.files }
+func (*testArtifact) Id() string          { return "" }
+func (*testArtifact) String() string      { return "string" }
+func (*testArtifact) Destroy() error      { return nil }
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+func testConfig(dir string) map[string]interface{} {
+	return map[string]interface{}{
+		"box_name":         "acme/base",
+		"version":          "1.0.0",
+		"output_directory": dir,
+		"base_url":         "http://boxes.example.com/",
+	}
+}
+
+// testBox writes a box for the provider, gzipped or not.
+func testBox(t *testing.T, dir, provider string, compress bool) string {
+	path := filepath.Join(dir, provider+".box")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if compress {
+		gzw := gzip.NewWriter(f)
+		defer gzw.Close()
+		w = gzw
+	}
+
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	files := []struct {
+		name string
+		data string
+	}{
+		{"Vagrantfile", "Vagrant.configure(\"2\")"},
+		{"metadata.json", `{"provider":"` + provider + `"}`},
+	}
+	for _, file := range files {
+		hdr := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.data))}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if _, err := tw.Write([]byte(file.data)); err != nil {
+			t.Fatalf("err: %s", err)
+		}
+	}
+
+	return path
+}
+
+func testReadCatalog(t *testing.T, path string) *catalog {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var c catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return &c
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	var p PostProcessor
+
+	// Good
+	if err := p.Configure(testConfig("catalog")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if p.config.BoxPath != defaultBoxPath {
+		t.Fatalf("bad: %s", p.config.BoxPath)
+	}
+
+	// Required settings
+	for _, k := range []string{"box_name", "version", "output_directory", "base_url"} {
+		p = PostProcessor{}
+		c := testConfig("catalog")
+		delete(c, k)
+		if err := p.Configure(c); err == nil {
+			t.Fatalf("should have error without %s", k)
+		}
+	}
+
+	// Bad box_path
+	p = PostProcessor{}
+	c := testConfig("catalog")
+	c["box_path"] = "{{.Name"
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Unknown settings
+	p = PostProcessor{}
+	c = testConfig("catalog")
+	c["bad"] = "value"
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorPostProcess_badArtifact(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig("catalog")); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{
+		builderId: "mitchellh.virtualbox",
+		files:     []string{"packer.ovf"},
+	}
+	if _, _, err := p.PostProcess(testUi(), artifact); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorPostProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	catalogDir := filepath.Join(dir, "catalog")
+	var p PostProcessor
+	config := testConfig(catalogDir)
+	config["description"] = "A base box"
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// A gzipped VirtualBox box and an uncompressed VMware box
+	boxes := []string{
+		testBox(t, dir, "virtualbox", true),
+		testBox(t, dir, "vmware_desktop", false),
+	}
+
+	var artifacts []packer.Artifact
+	for _, box := range boxes {
+		artifact := &testArtifact{
+			builderId: "mitchellh.post-processor.vagrant",
+			files:     []string{box},
+		}
+
+		result, keep, err := p.PostProcess(testUi(), artifact)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if keep {
+			t.Fatal("should not keep")
+		}
+
+		artifacts = append(artifacts, result)
+	}
+
+	if artifacts[0].Id() != "http://boxes.example.com/acme/base/1.0.0/virtualbox.box" {
+		t.Fatalf("bad: %s", artifacts[0].Id())
+	}
+
+	metadataPath := filepath.Join(catalogDir, "acme", "base", "metadata.json")
+	c := testReadCatalog(t, metadataPath)
+	if c.Name != "acme/base" || c.Description != "A base box" {
+		t.Fatalf("bad: %#v", c)
+	}
+	if len(c.Versions) != 1 || len(c.Versions[0].Providers) != 2 {
+		t.Fatalf("bad: %#v", c.Versions)
+	}
+
+	for i, provider := range c.Versions[0].Providers {
+		data, err := ioutil.ReadFile(boxes[i])
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		sum := sha256.Sum256(data)
+
+		if provider.ChecksumType != "sha256" || provider.Checksum != hex.EncodeToString(sum[:]) {
+			t.Fatalf("bad: %#v", provider)
+		}
+
+		copied, err := ioutil.ReadFile(artifacts[i].Files()[0])
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !bytes.Equal(copied, data) {
+			t.Fatalf("box %s not copied", boxes[i])
+		}
+	}
+
+	// A new version is added after the existing one
+	p = PostProcessor{}
+	config["version"] = "1.1.0"
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{
+		builderId: "mitchellh.post-processor.vagrant",
+		files:     []string{boxes[0]},
+	}
+	result, _, err := p.PostProcess(testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c = testReadCatalog(t, metadataPath)
+	if len(c.Versions) != 2 || c.Versions[1].Version != "1.1.0" {
+		t.Fatalf("bad: %#v", c.Versions)
+	}
+
+	// Destroying removes the version again
+	if err := result.Destroy(); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	c = testReadCatalog(t, metadataPath)
+	if len(c.Versions) != 1 || c.Versions[0].Version != "1.0.0" {
+		t.Fatalf("bad: %#v", c.Versions)
+	}
+	if _, err := os.Stat(result.Files()[0]); !os.IsNotExist(err) {
+		t.Fatalf("box should be removed: %s", err)
+	}
+}
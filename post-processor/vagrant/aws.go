@@ -16,9 +16,11 @@ import (
 type AWSBoxConfig struct {
 	common.PackerConfig `mapstructure:",squash"`
 
-	OutputPath          string `mapstructure:"output"`
-	VagrantfileTemplate string `mapstructure:"vagrantfile_template"`
-	CompressionLevel    string `mapstructure:"compression_level"`
+	OutputPath          string                 `mapstructure:"output"`
+	VagrantfileTemplate string                 `mapstructure:"vagrantfile_template"`
+	CompressionLevel    string                 `mapstructure:"compression_level"`
+	Include             []string               `mapstructure:"include"`
+	Metadata            map[string]interface{} `mapstructure:"metadata"`
 
 	tpl *packer.ConfigTemplate
 }
@@ -140,11 +142,19 @@ func (p *AWSBoxPostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact
 	}
 
 	// Create the metadata
-	metadata := map[string]string{"provider": "aws"}
+	metadata := map[string]interface{}{"provider": "aws"}
+	if err := MergeMetadata(metadata, p.config.Metadata); err != nil {
+		return nil, false, err
+	}
 	if err := WriteMetadata(dir, metadata); err != nil {
 		return nil, false, err
 	}
 
+	// Add the included files
+	if err := IncludeFiles(dir, p.config.Include, ui); err != nil {
+		return nil, false, err
+	}
+
 	// Compress the directory to the given output path
 	if err := DirToBox(outputPath, dir, ui, level); err != nil {
 		err = fmt.Errorf("error creating box: %s", err)
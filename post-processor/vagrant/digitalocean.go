@@ -16,9 +16,11 @@ import (
 type DigitalOceanBoxConfig struct {
 	common.PackerConfig `mapstructure:",squash"`
 
-	OutputPath          string `mapstructure:"output"`
-	VagrantfileTemplate string `mapstructure:"vagrantfile_template"`
-	CompressionLevel    string `mapstructure:"compression_level"`
+	OutputPath          string                 `mapstructure:"output"`
+	VagrantfileTemplate string                 `mapstructure:"vagrantfile_template"`
+	CompressionLevel    string                 `mapstructure:"compression_level"`
+	Include             []string               `mapstructure:"include"`
+	Metadata            map[string]interface{} `mapstructure:"metadata"`
 
 	tpl *packer.ConfigTemplate
 }
@@ -71,13 +73,20 @@ func (p *DigitalOceanBoxPostProcessor) PostProcess(ui packer.Ui, artifact packer
 	// Determine the image and region...
 	tplData := &DigitalOceanVagrantfileTemplate{}
 
-	parts := strings.Split(artifact.Id(), ":")
-	if len(parts) != 2 {
-		return nil, false, fmt.Errorf("Poorly formatted artifact ID: %s", artifact.Id())
-	}
+	// The artifact ID lists the snapshot in each region it is available
+	// in, like the aws builder, starting with the region it was created
+	// in, which is the one the box uses.
+	for i, regions := range strings.Split(artifact.Id(), ",") {
+		parts := strings.Split(regions, ":")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, false, fmt.Errorf("Poorly formatted artifact ID: %s", artifact.Id())
+		}
 
-	tplData.Region = parts[0]
-	tplData.Image = parts[1]
+		if i == 0 {
+			tplData.Region = parts[0]
+			tplData.Image = parts[1]
+		}
+	}
 
 	// Compile the output path
 	outputPath, err := p.config.tpl.Process(p.config.OutputPath, &OutputPathTemplate{
@@ -130,11 +139,19 @@ func (p *DigitalOceanBoxPostProcessor) PostProcess(ui packer.Ui, artifact packer
 	vf.Close()
 
 	// Create the metadata
-	metadata := map[string]string{"provider": "digital_ocean"}
+	metadata := map[string]interface{}{"provider": "digital_ocean"}
+	if err := MergeMetadata(metadata, p.config.Metadata); err != nil {
+		return nil, false, err
+	}
 	if err := WriteMetadata(dir, metadata); err != nil {
 		return nil, false, err
 	}
 
+	// Add the included files
+	if err := IncludeFiles(dir, p.config.Include, ui); err != nil {
+		return nil, false, err
+	}
+
 	// Compress the directory to the given output path
 	var level int = flate.DefaultCompression
 	if p.config.CompressionLevel != "" {
@@ -2,6 +2,10 @@ package vagrant
 
 import (
 	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -12,3 +16,40 @@ func TestDigitalOceanBoxPostProcessor_ImplementsPostProcessor(t *testing.T) {
 		t.Fatalf("Digitalocean PostProcessor should be a PostProcessor")
 	}
 }
+
+func TestDigitalOceanBoxPostProcessor_PostProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var p DigitalOceanBoxPostProcessor
+	config := map[string]interface{}{
+		"output": filepath.Join(dir, "packer_{{.Provider}}.box"),
+	}
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The box uses the region the snapshot was created in, which is first
+	artifact := &testArtifact{
+		builderId: "pearkes.digitalocean",
+		id:        "nyc2:packer-foo,ams2:packer-foo",
+	}
+	result, _, err := p.PostProcess(testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	contents := string(testBoxContents(t, result.Files()[0])["Vagrantfile"])
+	if !strings.Contains(contents, `digital_ocean.image = "packer-foo"`) ||
+		!strings.Contains(contents, `digital_ocean.region = "nyc2"`) {
+		t.Fatalf("bad: %s", contents)
+	}
+
+	artifact.id = "nyc2:packer-foo,ams2"
+	if _, _, err := p.PostProcess(testUi(), artifact); err == nil {
+		t.Fatal("should have error")
+	}
+}
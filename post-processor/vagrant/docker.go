@@ -0,0 +1,193 @@
+package vagrant
+
+import (
+	"compress/flate"
+	"fmt"
+	"github.com/mitchellh/packer/builder/docker"
+	"github.com/mitchellh/packer/common"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+type DockerBoxConfig struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	OutputPath          string                 `mapstructure:"output"`
+	VagrantfileTemplate string                 `mapstructure:"vagrantfile_template"`
+	CompressionLevel    string                 `mapstructure:"compression_level"`
+	Include             []string               `mapstructure:"include"`
+	Metadata            map[string]interface{} `mapstructure:"metadata"`
+	Image               string                 `mapstructure:"image"`
+
+	tpl *packer.ConfigTemplate
+}
+
+type DockerVagrantfileTemplate struct {
+	Image string
+}
+
+type DockerBoxPostProcessor struct {
+	config DockerBoxConfig
+
+	// driver imports the exported container. It is only set by tests,
+	// otherwise the docker command is used.
+	driver docker.Driver
+}
+
+func (p *DockerBoxPostProcessor) Configure(raws ...interface{}) error {
+	md, err := common.DecodeConfig(&p.config, raws...)
+	if err != nil {
+		return err
+	}
+
+	p.config.tpl, err = packer.NewConfigTemplate()
+	if err != nil {
+		return err
+	}
+	p.config.tpl.UserVars = p.config.PackerUserVars
+
+	// Accumulate any errors
+	errs := common.CheckUnusedConfig(md)
+
+	validates := map[string]*string{
+		"output":               &p.config.OutputPath,
+		"vagrantfile_template": &p.config.VagrantfileTemplate,
+		"compression_level":    &p.config.CompressionLevel,
+		"image":                &p.config.Image,
+	}
+
+	for n, ptr := range validates {
+		if err := p.config.tpl.Validate(*ptr); err != nil {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("Error parsing %s: %s", n, err))
+		}
+	}
+
+	// The Docker builder exports the container to a file, which can't be
+	// referenced by a box, so it is imported as an image with this name.
+	if p.config.Image == "" {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("image must be specified"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *DockerBoxPostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	image, err := p.config.tpl.Process(p.config.Image, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Import the exported container, so that the box references an image
+	// that exists.
+	driver := p.driver
+	if driver == nil {
+		driver = &docker.DockerDriver{Ui: ui}
+	}
+
+	ui.Message(fmt.Sprintf("Importing the exported container as image: %s", image))
+	if err := driver.Import(artifact.Files()[0], image); err != nil {
+		return nil, false, err
+	}
+
+	tplData := &DockerVagrantfileTemplate{
+		Image: image,
+	}
+
+	// Compile the output path
+	outputPath, err := p.config.tpl.Process(p.config.OutputPath, &OutputPathTemplate{
+		ArtifactId: artifact.Id(),
+		BuildName:  p.config.PackerBuildName,
+		Provider:   "docker",
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Create a temporary directory for us to build the contents of the box in
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		return nil, false, err
+	}
+	defer os.RemoveAll(dir)
+
+	// Create the Vagrantfile from the template
+	vf, err := os.Create(filepath.Join(dir, "Vagrantfile"))
+	if err != nil {
+		return nil, false, err
+	}
+	defer vf.Close()
+
+	vagrantfileContents := defaultDockerVagrantfile
+	if p.config.VagrantfileTemplate != "" {
+		log.Printf("Using vagrantfile template: %s", p.config.VagrantfileTemplate)
+		f, err := os.Open(p.config.VagrantfileTemplate)
+		if err != nil {
+			err = fmt.Errorf("error opening vagrantfile template: %s", err)
+			return nil, false, err
+		}
+		defer f.Close()
+
+		contents, err := ioutil.ReadAll(f)
+		if err != nil {
+			err = fmt.Errorf("error reading vagrantfile template: %s", err)
+			return nil, false, err
+		}
+
+		vagrantfileContents = string(contents)
+	}
+
+	vagrantfileContents, err = p.config.tpl.Process(vagrantfileContents, tplData)
+	if err != nil {
+		return nil, false, fmt.Errorf("Error writing Vagrantfile: %s", err)
+	}
+	vf.Write([]byte(vagrantfileContents))
+	vf.Close()
+
+	var level int = flate.DefaultCompression
+	if p.config.CompressionLevel != "" {
+		level, err = strconv.Atoi(p.config.CompressionLevel)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Create the metadata
+	metadata := map[string]interface{}{"provider": "docker"}
+	if err := MergeMetadata(metadata, p.config.Metadata); err != nil {
+		return nil, false, err
+	}
+	if err := WriteMetadata(dir, metadata); err != nil {
+		return nil, false, err
+	}
+
+	// Add the included files
+	if err := IncludeFiles(dir, p.config.Include, ui); err != nil {
+		return nil, false, err
+	}
+
+	// Compress the directory to the given output path
+	if err := DirToBox(outputPath, dir, ui, level); err != nil {
+		err = fmt.Errorf("error creating box: %s", err)
+		return nil, false, err
+	}
+
+	return NewArtifact("docker", outputPath), true, nil
+}
+
+var defaultDockerVagrantfile = `
+Vagrant.configure("2") do |config|
+  config.vm.provider "docker" do |d|
+    d.image = "{{ .Image }}"
+  end
+end
+`
@@ -0,0 +1,93 @@
+package vagrant
+
+import (
+	"errors"
+	"github.com/mitchellh/packer/builder/docker"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDockerBoxPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var raw interface{}
+	raw = &DockerBoxPostProcessor{}
+	if _, ok := raw.(packer.PostProcessor); !ok {
+		t.Fatalf("Docker PostProcessor should be a PostProcessor")
+	}
+}
+
+func TestDockerBoxPostProcessor_PostProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	driver := new(docker.MockDriver)
+	p := DockerBoxPostProcessor{driver: driver}
+	config := map[string]interface{}{
+		"output": filepath.Join(dir, "packer_{{.Provider}}.box"),
+		"image":  "example/app",
+	}
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The Docker builder only exports containers
+	artifact := new(docker.ExportArtifact)
+	result, _, err := p.PostProcess(testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The export is imported as the image the box references
+	if !driver.ImportCalled || driver.ImportPath != artifact.Files()[0] || driver.ImportRepo != "example/app" {
+		t.Fatalf("bad: %#v", driver)
+	}
+
+	contents := testBoxContents(t, result.Files()[0])
+	if !strings.Contains(string(contents["Vagrantfile"]), `d.image = "example/app"`) {
+		t.Fatalf("bad: %s", contents["Vagrantfile"])
+	}
+	if strings.TrimSpace(string(contents["metadata.json"])) != `{"provider":"docker"}` {
+		t.Fatalf("bad: %s", contents["metadata.json"])
+	}
+}
+
+func TestDockerBoxPostProcessor_Configure_image(t *testing.T) {
+	var p DockerBoxPostProcessor
+
+	// An exported container needs the name of the image it is imported as
+	if err := p.Configure(map[string]interface{}{"output": "packer.box"}); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestDockerBoxPostProcessor_PostProcess_importError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	driver := &docker.MockDriver{ImportError: errors.New("foo")}
+	p := DockerBoxPostProcessor{driver: driver}
+	config := map[string]interface{}{
+		"output": filepath.Join(dir, "packer.box"),
+		"image":  "example/app",
+	}
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if _, _, err := p.PostProcess(testUi(), new(docker.ExportArtifact)); err == nil {
+		t.Fatal("should have error")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "packer.box")); err == nil {
+		t.Fatal("should not create the box")
+	}
+}
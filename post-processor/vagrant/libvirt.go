@@ -0,0 +1,217 @@
+package vagrant
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"github.com/mitchellh/packer/common"
+	"github.com/mitchellh/packer/packer"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+type LibVirtBoxConfig struct {
+	common.PackerConfig `mapstructure:",squash"`
+
+	OutputPath          string                 `mapstructure:"output"`
+	VagrantfileTemplate string                 `mapstructure:"vagrantfile_template"`
+	CompressionLevel    string                 `mapstructure:"compression_level"`
+	Include             []string               `mapstructure:"include"`
+	Metadata            map[string]interface{} `mapstructure:"metadata"`
+
+	tpl *packer.ConfigTemplate
+}
+
+type LibVirtVagrantfileTemplate struct {
+	VirtualSize uint64
+}
+
+type LibVirtBoxPostProcessor struct {
+	config LibVirtBoxConfig
+}
+
+func (p *LibVirtBoxPostProcessor) Configure(raws ...interface{}) error {
+	md, err := common.DecodeConfig(&p.config, raws...)
+	if err != nil {
+		return err
+	}
+
+	p.config.tpl, err = packer.NewConfigTemplate()
+	if err != nil {
+		return err
+	}
+	p.config.tpl.UserVars = p.config.PackerUserVars
+
+	// Accumulate any errors
+	errs := common.CheckUnusedConfig(md)
+
+	validates := map[string]*string{
+		"output":               &p.config.OutputPath,
+		"vagrantfile_template": &p.config.VagrantfileTemplate,
+		"compression_level":    &p.config.CompressionLevel,
+	}
+
+	for n, ptr := range validates {
+		if err := p.config.tpl.Validate(*ptr); err != nil {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("Error parsing %s: %s", n, err))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func (p *LibVirtBoxPostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (packer.Artifact, bool, error) {
+	// vagrant-libvirt only supports qcow2 disks
+	disk := ""
+	for _, path := range artifact.Files() {
+		if filepath.Ext(path) == ".qcow2" {
+			disk = path
+			break
+		}
+	}
+
+	if disk == "" {
+		return nil, false, fmt.Errorf(
+			"No qcow2 disk found. Set the format of the qemu builder to \"qcow2\".")
+	}
+
+	virtualSize, err := qcow2VirtualSize(disk)
+	if err != nil {
+		return nil, false, fmt.Errorf("Error reading qcow2 disk: %s", err)
+	}
+
+	// Compile the output path
+	outputPath, err := p.config.tpl.Process(p.config.OutputPath, &OutputPathTemplate{
+		ArtifactId: artifact.Id(),
+		BuildName:  p.config.PackerBuildName,
+		Provider:   "libvirt",
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Create a temporary directory for us to build the contents of the box in
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		return nil, false, err
+	}
+	defer os.RemoveAll(dir)
+
+	// The disk must be named box.img
+	ui.Message(fmt.Sprintf("Copying: %s", disk))
+	if err := CopyContents(filepath.Join(dir, "box.img"), disk); err != nil {
+		return nil, false, err
+	}
+
+	// Create the Vagrantfile from the template
+	tplData := &LibVirtVagrantfileTemplate{VirtualSize: virtualSize}
+
+	vf, err := os.Create(filepath.Join(dir, "Vagrantfile"))
+	if err != nil {
+		return nil, false, err
+	}
+	defer vf.Close()
+
+	vagrantfileContents := defaultLibVirtVagrantfile
+	if p.config.VagrantfileTemplate != "" {
+		log.Printf("Using vagrantfile template: %s", p.config.VagrantfileTemplate)
+		f, err := os.Open(p.config.VagrantfileTemplate)
+		if err != nil {
+			err = fmt.Errorf("error opening vagrantfile template: %s", err)
+			return nil, false, err
+		}
+		defer f.Close()
+
+		contents, err := ioutil.ReadAll(f)
+		if err != nil {
+			err = fmt.Errorf("error reading vagrantfile template: %s", err)
+			return nil, false, err
+		}
+
+		vagrantfileContents = string(contents)
+	}
+
+	vagrantfileContents, err = p.config.tpl.Process(vagrantfileContents, tplData)
+	if err != nil {
+		return nil, false, fmt.Errorf("Error writing Vagrantfile: %s", err)
+	}
+	vf.Write([]byte(vagrantfileContents))
+	vf.Close()
+
+	var level int = flate.DefaultCompression
+	if p.config.CompressionLevel != "" {
+		level, err = strconv.Atoi(p.config.CompressionLevel)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Create the metadata
+	metadata := map[string]interface{}{
+		"provider":     "libvirt",
+		"format":       "qcow2",
+		"virtual_size": virtualSize,
+	}
+	if err := MergeMetadata(metadata, p.config.Metadata); err != nil {
+		return nil, false, err
+	}
+	if err := WriteMetadata(dir, metadata); err != nil {
+		return nil, false, err
+	}
+
+	// Add the included files
+	if err := IncludeFiles(dir, p.config.Include, ui); err != nil {
+		return nil, false, err
+	}
+
+	// Compress the directory to the given output path
+	ui.Message(fmt.Sprintf("Compressing box..."))
+	if err := DirToBox(outputPath, dir, ui, level); err != nil {
+		return nil, false, err
+	}
+
+	return NewArtifact("libvirt", outputPath), false, nil
+}
+
+// qcow2VirtualSize reads the virtual size of a qcow2 disk from its
+// header, rounded up to whole gigabytes as vagrant-libvirt expects.
+func qcow2VirtualSize(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// The header starts with the magic, and has the size in bytes at
+	// offset 24. All fields are big endian.
+	header := make([]byte, 32)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return 0, err
+	}
+
+	if !bytes.Equal(header[:4], []byte{'Q', 'F', 'I', 0xfb}) {
+		return 0, fmt.Errorf("Not a qcow2 disk: %s", path)
+	}
+
+	size := binary.BigEndian.Uint64(header[24:32])
+	gb := uint64(1024 * 1024 * 1024)
+	return (size + gb - 1) / gb, nil
+}
+
+var defaultLibVirtVagrantfile = `
+Vagrant.configure("2") do |config|
+  config.vm.provider :libvirt do |libvirt|
+    libvirt.driver = "kvm"
+  end
+end
+`
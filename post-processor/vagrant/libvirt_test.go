@@ -0,0 +1,219 @@
+package vagrant
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/mitchellh/packer/packer"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testArtifact struct {
+	builderId string
+	id        string
+	files     []string
+}
+
+func (a *testArtifact) BuilderId() string { return a.builderId }
+func (a *testArtifact) Files() []string   { return a.files }
+func (a *testArtifact) Id() string        { return a.id }
+func (*testArtifact) String() string      { return "string" }
+func (*testArtifact) Destroy() error      { return nil }
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+// testQcow2 writes the header of a qcow2 disk of the given virtual size.
+func testQcow2(t *testing.T, dir string, size uint64) string {
+	header := make([]byte, 72)
+	copy(header, []byte{'Q', 'F', 'I', 0xfb})
+	binary.BigEndian.PutUint32(header[4:8], 2)
+	binary.BigEndian.PutUint64(header[24:32], size)
+
+	path := filepath.Join(dir, "packer.qcow2")
+	if err := ioutil.WriteFile(path, header, 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return path
+}
+
+// testBoxContents returns the files in a gzipped box by name.
+func testBoxContents(t *testing.T, path string) map[string][]byte {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	result := make(map[string][]byte)
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		result[hdr.Name] = data
+	}
+
+	return result
+}
+
+func TestLibVirtBoxPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var raw interface{}
+	raw = &LibVirtBoxPostProcessor{}
+	if _, ok := raw.(packer.PostProcessor); !ok {
+		t.Fatalf("LibVirt PostProcessor should be a PostProcessor")
+	}
+}
+
+func TestQcow2VirtualSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 40000 MB, as the qemu builder creates by default
+	size, err := qcow2VirtualSize(testQcow2(t, dir, 40000*1024*1024))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if size != 40 {
+		t.Fatalf("bad: %d", size)
+	}
+
+	raw := filepath.Join(dir, "packer.raw")
+	if err := ioutil.WriteFile(raw, make([]byte, 64), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if _, err := qcow2VirtualSize(raw); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestLibVirtBoxPostProcessor_PostProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var p LibVirtBoxPostProcessor
+	config := map[string]interface{}{
+		"output": filepath.Join(dir, "packer_{{.Provider}}.box"),
+	}
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{
+		builderId: "transcend.qemu",
+		files:     []string{testQcow2(t, dir, 10*1024*1024*1024)},
+	}
+	result, _, err := p.PostProcess(testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result.Files()[0] != filepath.Join(dir, "packer_libvirt.box") {
+		t.Fatalf("bad: %#v", result.Files())
+	}
+
+	contents := testBoxContents(t, result.Files()[0])
+	if _, ok := contents["box.img"]; !ok {
+		t.Fatalf("bad: %#v", contents)
+	}
+	if _, ok := contents["Vagrantfile"]; !ok {
+		t.Fatalf("bad: %#v", contents)
+	}
+
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(contents["metadata.json"], &metadata); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if metadata["provider"] != "libvirt" || metadata["format"] != "qcow2" ||
+		metadata["virtual_size"] != float64(10) {
+		t.Fatalf("bad: %#v", metadata)
+	}
+}
+
+func TestLibVirtBoxPostProcessor_PostProcess_raw(t *testing.T) {
+	var p LibVirtBoxPostProcessor
+	if err := p.Configure(map[string]interface{}{"output": "packer.box"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{builderId: "transcend.qemu", files: []string{"packer.raw"}}
+	if _, _, err := p.PostProcess(testUi(), artifact); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestLibVirtBoxPostProcessor_PostProcess_include(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	readme := filepath.Join(dir, "README.md")
+	if err := ioutil.WriteFile(readme, []byte("readme"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	var p LibVirtBoxPostProcessor
+	config := map[string]interface{}{
+		"output":   filepath.Join(dir, "packer_{{.Provider}}.box"),
+		"include":  []string{readme},
+		"metadata": map[string]interface{}{"author": "packer"},
+	}
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{
+		builderId: "transcend.qemu",
+		files:     []string{testQcow2(t, dir, 10*1024*1024*1024)},
+	}
+	result, _, err := p.PostProcess(testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	contents := testBoxContents(t, result.Files()[0])
+	if string(contents["README.md"]) != "readme" {
+		t.Fatalf("bad: %#v", contents)
+	}
+
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(contents["metadata.json"], &metadata); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if metadata["provider"] != "libvirt" || metadata["author"] != "packer" {
+		t.Fatalf("bad: %#v", metadata)
+	}
+}
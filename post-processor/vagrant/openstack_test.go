@@ -0,0 +1,53 @@
+package vagrant
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenStackBoxPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var raw interface{}
+	raw = &OpenStackBoxPostProcessor{}
+	if _, ok := raw.(packer.PostProcessor); !ok {
+		t.Fatalf("OpenStack PostProcessor should be a PostProcessor")
+	}
+}
+
+func TestOpenStackBoxPostProcessor_PostProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var p OpenStackBoxPostProcessor
+	config := map[string]interface{}{
+		"output": filepath.Join(dir, "packer_{{.Provider}}.box"),
+	}
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{builderId: "mitchellh.openstack", id: "abcd-1234"}
+	result, keep, err := p.PostProcess(testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The image is needed by the box
+	if !keep {
+		t.Fatal("should keep")
+	}
+
+	contents := testBoxContents(t, result.Files()[0])
+	if !strings.Contains(string(contents["Vagrantfile"]), `os.image = "abcd-1234"`) {
+		t.Fatalf("bad: %s", contents["Vagrantfile"])
+	}
+	if strings.TrimSpace(string(contents["metadata.json"])) != `{"provider":"openstack"}` {
+		t.Fatalf("bad: %s", contents["metadata.json"])
+	}
+}
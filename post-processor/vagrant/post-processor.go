@@ -14,15 +14,21 @@ import (
 var builtins = map[string]string{
 	"mitchellh.amazonebs":       "aws",
 	"mitchellh.amazon.instance": "aws",
+	"mitchellh.openstack":       "openstack",
+	"packer.docker":             "docker",
 	"mitchellh.virtualbox":      "virtualbox",
 	"mitchellh.vmware":          "vmware",
 	"pearkes.digitalocean":      "digitalocean",
+	"transcend.qemu":            "libvirt",
 }
 
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 
-	OutputPath string `mapstructure:"output"`
+	OutputPath       string                 `mapstructure:"output"`
+	CompressionLevel string                 `mapstructure:"compression_level"`
+	Include          []string               `mapstructure:"include"`
+	Metadata         map[string]interface{} `mapstructure:"metadata"`
 }
 
 type PostProcessor struct {
@@ -55,9 +61,13 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 			errs, fmt.Errorf("Error parsing output template: %s", err))
 	}
 
-	// Store extra configuration we'll send to each post-processor type
+	// Store extra configuration we'll send to each post-processor type.
+	// The provider specific configuration can override it.
 	p.extraConfig = make(map[string]interface{})
 	p.extraConfig["output"] = p.config.OutputPath
+	p.extraConfig["compression_level"] = p.config.CompressionLevel
+	p.extraConfig["include"] = p.config.Include
+	p.extraConfig["metadata"] = p.config.Metadata
 	p.extraConfig["packer_build_name"] = p.config.PackerBuildName
 	p.extraConfig["packer_builder_type"] = p.config.PackerBuilderType
 	p.extraConfig["packer_debug"] = p.config.PackerDebug
@@ -142,8 +152,14 @@ func keyToPostProcessor(key string) packer.PostProcessor {
 	switch key {
 	case "aws":
 		return new(AWSBoxPostProcessor)
+	case "docker":
+		return new(DockerBoxPostProcessor)
 	case "digitalocean":
 		return new(DigitalOceanBoxPostProcessor)
+	case "libvirt":
+		return new(LibVirtBoxPostProcessor)
+	case "openstack":
+		return new(OpenStackBoxPostProcessor)
 	case "virtualbox":
 		return new(VBoxBoxPostProcessor)
 	case "vmware":
@@ -47,3 +47,37 @@ func TestBuilderPrepare_PPConfig(t *testing.T) {
 		t.Fatalf("err: %s", err)
 	}
 }
+
+func TestBuilderPrepare_PPConfigShared(t *testing.T) {
+	var p PostProcessor
+
+	// The shared settings are passed to each provider, which can
+	// override them
+	c := testConfig()
+	c["compression_level"] = 0
+	c["include"] = []string{"README.md"}
+	c["metadata"] = map[string]interface{}{"author": "packer"}
+	c["aws"] = map[string]interface{}{}
+	c["libvirt"] = map[string]interface{}{
+		"include": []string{"Vagrantfile.local"},
+	}
+	if err := p.Configure(c); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	aws := p.premade["aws"].(*AWSBoxPostProcessor).config
+	if aws.CompressionLevel != "0" {
+		t.Fatalf("bad: %#v", aws.CompressionLevel)
+	}
+	if len(aws.Include) != 1 || aws.Include[0] != "README.md" {
+		t.Fatalf("bad: %#v", aws.Include)
+	}
+	if aws.Metadata["author"] != "packer" {
+		t.Fatalf("bad: %#v", aws.Metadata)
+	}
+
+	libvirt := p.premade["libvirt"].(*LibVirtBoxPostProcessor).config
+	if len(libvirt.Include) != 1 || libvirt.Include[0] != "Vagrantfile.local" {
+		t.Fatalf("bad: %#v", libvirt.Include)
+	}
+}
@@ -132,3 +132,41 @@ func WriteMetadata(dir string, contents interface{}) error {
 	enc := json.NewEncoder(f)
 	return enc.Encode(contents)
 }
+
+// IncludeFiles copies files into the directory of a box, so they are
+// packaged with it. The files are added by their base name, and can't
+// replace the files Packer adds, such as the Vagrantfile.
+func IncludeFiles(dir string, files []string, ui packer.Ui) error {
+	for _, path := range files {
+		dstPath := filepath.Join(dir, filepath.Base(path))
+		if _, err := os.Stat(dstPath); err == nil {
+			return fmt.Errorf(
+				"Included file conflicts with a file of the box: %s", path)
+		}
+
+		if ui != nil {
+			ui.Message(fmt.Sprintf("Including: %s", path))
+		}
+
+		if err := CopyContents(dstPath, path); err != nil {
+			return fmt.Errorf("Error including %s: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
+// MergeMetadata adds the extra keys to the metadata of a box, replacing
+// the keys Packer sets. The provider can't be replaced, since Vagrant
+// needs it to use the box.
+func MergeMetadata(metadata, extra map[string]interface{}) error {
+	for k, v := range extra {
+		if k == "provider" {
+			return fmt.Errorf("The provider can't be set in metadata")
+		}
+
+		metadata[k] = v
+	}
+
+	return nil
+}
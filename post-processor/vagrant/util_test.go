@@ -0,0 +1,116 @@
+package vagrant
+
+import (
+	"archive/tar"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirToBox_noCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	boxDir := filepath.Join(dir, "box")
+	if err := os.Mkdir(boxDir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := WriteMetadata(boxDir, map[string]string{"provider": "virtualbox"}); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	box := filepath.Join(dir, "packer.box")
+	if err := DirToBox(box, boxDir, nil, flate.NoCompression); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	// The box is a plain tar
+	f, err := os.Open(box)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer f.Close()
+
+	var names []string
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		names = append(names, hdr.Name)
+	}
+
+	if len(names) != 1 || names[0] != "metadata.json" {
+		t.Fatalf("bad: %#v", names)
+	}
+}
+
+func TestIncludeFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	boxDir := filepath.Join(dir, "box")
+	if err := os.Mkdir(boxDir, 0755); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	readme := filepath.Join(dir, "README.md")
+	if err := ioutil.WriteFile(readme, []byte("readme"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if err := IncludeFiles(boxDir, []string{readme}, testUi()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(boxDir, "README.md"))
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if string(data) != "readme" {
+		t.Fatalf("bad: %s", data)
+	}
+
+	// Files of the box can't be replaced
+	if err := IncludeFiles(boxDir, []string{readme}, testUi()); err == nil {
+		t.Fatal("should have error")
+	}
+
+	// Missing files
+	missing := filepath.Join(dir, "missing")
+	if err := IncludeFiles(boxDir, []string{missing}, testUi()); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	metadata := map[string]interface{}{"provider": "libvirt", "format": "qcow2"}
+	extra := map[string]interface{}{"format": "raw", "author": "packer"}
+	if err := MergeMetadata(metadata, extra); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if metadata["provider"] != "libvirt" || metadata["format"] != "raw" ||
+		metadata["author"] != "packer" {
+		t.Fatalf("bad: %#v", metadata)
+	}
+
+	// The provider can't be replaced
+	extra = map[string]interface{}{"provider": "virtualbox"}
+	if err := MergeMetadata(metadata, extra); err == nil {
+		t.Fatal("should have error")
+	}
+}
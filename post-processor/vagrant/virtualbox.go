@@ -19,9 +19,11 @@ import (
 type VBoxBoxConfig struct {
 	common.PackerConfig `mapstructure:",squash"`
 
-	OutputPath          string `mapstructure:"output"`
-	VagrantfileTemplate string `mapstructure:"vagrantfile_template"`
-	CompressionLevel    string `mapstructure:"compression_level"`
+	OutputPath          string                 `mapstructure:"output"`
+	VagrantfileTemplate string                 `mapstructure:"vagrantfile_template"`
+	CompressionLevel    string                 `mapstructure:"compression_level"`
+	Include             []string               `mapstructure:"include"`
+	Metadata            map[string]interface{} `mapstructure:"metadata"`
 
 	tpl *packer.ConfigTemplate
 }
@@ -154,7 +156,10 @@ func (p *VBoxBoxPostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifac
 	}
 
 	// Create the metadata
-	metadata := map[string]string{"provider": "virtualbox"}
+	metadata := map[string]interface{}{"provider": "virtualbox"}
+	if err := MergeMetadata(metadata, p.config.Metadata); err != nil {
+		return nil, false, err
+	}
 	if err := WriteMetadata(dir, metadata); err != nil {
 		return nil, false, err
 	}
@@ -165,6 +170,11 @@ func (p *VBoxBoxPostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifac
 		return nil, false, err
 	}
 
+	// Add the included files
+	if err := IncludeFiles(dir, p.config.Include, ui); err != nil {
+		return nil, false, err
+	}
+
 	// Compress the directory to the given output path
 	ui.Message(fmt.Sprintf("Compressing box..."))
 	if err := DirToBox(outputPath, dir, ui, level); err != nil {
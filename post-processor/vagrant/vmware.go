@@ -14,9 +14,11 @@ import (
 type VMwareBoxConfig struct {
 	common.PackerConfig `mapstructure:",squash"`
 
-	OutputPath          string `mapstructure:"output"`
-	VagrantfileTemplate string `mapstructure:"vagrantfile_template"`
-	CompressionLevel    string `mapstructure:"compression_level"`
+	OutputPath          string                 `mapstructure:"output"`
+	VagrantfileTemplate string                 `mapstructure:"vagrantfile_template"`
+	CompressionLevel    string                 `mapstructure:"compression_level"`
+	Include             []string               `mapstructure:"include"`
+	Metadata            map[string]interface{} `mapstructure:"metadata"`
 
 	tpl *packer.ConfigTemplate
 }
@@ -124,11 +126,19 @@ func (p *VMwareBoxPostProcessor) PostProcess(ui packer.Ui, artifact packer.Artif
 	}
 
 	// Create the metadata
-	metadata := map[string]string{"provider": "vmware_desktop"}
+	metadata := map[string]interface{}{"provider": "vmware_desktop"}
+	if err := MergeMetadata(metadata, p.config.Metadata); err != nil {
+		return nil, false, err
+	}
 	if err := WriteMetadata(dir, metadata); err != nil {
 		return nil, false, err
 	}
 
+	// Add the included files
+	if err := IncludeFiles(dir, p.config.Include, ui); err != nil {
+		return nil, false, err
+	}
+
 	// Compress the directory to the given output path
 	ui.Message(fmt.Sprintf("Compressing box..."))
 	if err := DirToBox(outputPath, dir, ui, level); err != nil {
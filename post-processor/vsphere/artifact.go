@@ -0,0 +1,61 @@
+package vsphere
+
+import (
+	"fmt"
+)
+
+const BuilderId = "packer.post-processor.vsphere"
+
+// Artifact is a VM, or template, uploaded to vSphere.
+type Artifact struct {
+	// The inventory path of the VM, such as "datacenter/vm/folder/name"
+	Path string
+
+	config Config
+}
+
+func NewArtifact(path string, config Config) *Artifact {
+	return &Artifact{
+		Path:   path,
+		config: config,
+	}
+}
+
+func (*Artifact) BuilderId() string {
+	return BuilderId
+}
+
+func (*Artifact) Files() []string {
+	return nil
+}
+
+func (a *Artifact) Id() string {
+	return a.Path
+}
+
+func (a *Artifact) String() string {
+	if a.config.MarkAsTemplate {
+		return fmt.Sprintf("Template uploaded to vSphere: %s", a.Path)
+	}
+
+	return fmt.Sprintf("VM uploaded to vSphere: %s", a.Path)
+}
+
+func (a *Artifact) Destroy() error {
+	c, err := newClient(a.config.Host, a.config.Insecure)
+	if err != nil {
+		return err
+	}
+
+	if err := c.login(a.config.Username, a.config.Password); err != nil {
+		return err
+	}
+	defer c.logout()
+
+	vm, err := c.findByInventoryPath(a.Path)
+	if err != nil {
+		return err
+	}
+
+	return c.destroy(vm)
+}
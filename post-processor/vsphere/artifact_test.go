@@ -0,0 +1,21 @@
+package vsphere
+
+import (
+	"github.com/mitchellh/packer/packer"
+	"testing"
+)
+
+func TestArtifact_ImplementsArtifact(t *testing.T) {
+	var _ packer.Artifact = new(Artifact)
+}
+
+func TestArtifact_Id(t *testing.T) {
+	a := NewArtifact("dc/vm/packer/packer-vm", Config{})
+	if a.Id() != "dc/vm/packer/packer-vm" {
+		t.Fatalf("bad: %s", a.Id())
+	}
+
+	if a.String() != "VM uploaded to vSphere: dc/vm/packer/packer-vm" {
+		t.Fatalf("bad: %s", a.String())
+	}
+}
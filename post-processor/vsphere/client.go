@@ -0,0 +1,529 @@
+package vsphere
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The vSphere API is a SOAP API. Rather than pulling in a full SOAP
+// library, the handful of methods needed to import an OVF are
+// implemented here with plain XML.
+
+const soapEnvelopeStart = `<?xml version="1.0" encoding="UTF-8"?>` +
+	`<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/"` +
+	` xmlns:xsd="http://www.w3.org/2001/XMLSchema"` +
+	` xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` +
+	`<soapenv:Body>`
+
+const soapEnvelopeEnd = `</soapenv:Body></soapenv:Envelope>`
+
+// mor is a reference to a managed object, such as a datastore or a VM.
+type mor struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type serviceContent struct {
+	PropertyCollector mor `xml:"propertyCollector"`
+	SearchIndex       mor `xml:"searchIndex"`
+	SessionManager    mor `xml:"sessionManager"`
+	OvfManager        mor `xml:"ovfManager"`
+}
+
+type networkMapping struct {
+	Name    string `xml:"name"`
+	Network mor    `xml:"network"`
+}
+
+// importSpecParams are the OvfCreateImportSpecParams. The order of the
+// fields is the order the API expects them in.
+type importSpecParams struct {
+	Locale           string           `xml:"locale"`
+	DeploymentOption string           `xml:"deploymentOption"`
+	EntityName       string           `xml:"entityName"`
+	NetworkMapping   []networkMapping `xml:"networkMapping,omitempty"`
+	DiskProvisioning string           `xml:"diskProvisioning,omitempty"`
+}
+
+// importSpec is the opaque, polymorphic import spec, which is passed
+// from CreateImportSpec to ImportVApp unchanged.
+type importSpec struct {
+	Type  string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	Inner string `xml:",innerxml"`
+}
+
+type fileItem struct {
+	DeviceId string `xml:"deviceId"`
+	Path     string `xml:"path"`
+	Size     int64  `xml:"size"`
+	Create   bool   `xml:"create"`
+}
+
+type localizedFault struct {
+	LocalizedMessage string `xml:"localizedMessage"`
+}
+
+type importSpecResult struct {
+	ImportSpec importSpec       `xml:"importSpec"`
+	FileItem   []fileItem       `xml:"fileItem"`
+	Warning    []localizedFault `xml:"warning"`
+	Error      []localizedFault `xml:"error"`
+}
+
+type deviceURL struct {
+	Key       string `xml:"key"`
+	ImportKey string `xml:"importKey"`
+	URL       string `xml:"url"`
+}
+
+// propertyValue holds the value of any of the properties that are
+// retrieved. Which fields are set depends on the property.
+type propertyValue struct {
+	Text             string         `xml:",chardata"`
+	DeviceURL        []deviceURL    `xml:"deviceUrl"`
+	Entity           mor            `xml:"entity"`
+	LocalizedMessage string         `xml:"localizedMessage"`
+	State            string         `xml:"state"`
+	Error            localizedFault `xml:"error"`
+}
+
+// client is a session with a vSphere endpoint, either vCenter or ESXi.
+type client struct {
+	// The URL of the SOAP API, https://host/sdk
+	url *url.URL
+
+	http    *http.Client
+	content serviceContent
+}
+
+// newClient connects to the vSphere endpoint on the given host. If
+// insecure is true, its TLS certificate isn't verified.
+func newClient(host string, insecure bool) (*client, error) {
+	u, err := url.Parse(fmt.Sprintf("https://%s/sdk", host))
+	if err != nil {
+		return nil, err
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &client{
+		url: u,
+		http: &http.Client{
+			Jar: jar,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+			},
+		},
+	}
+
+	var resp struct {
+		Returnval serviceContent `xml:"returnval"`
+	}
+	req := struct {
+		XMLName xml.Name `xml:"urn:vim25 RetrieveServiceContent"`
+		This    mor      `xml:"_this"`
+	}{This: mor{"ServiceInstance", "ServiceInstance"}}
+	if err := c.call(req, &resp); err != nil {
+		return nil, err
+	}
+
+	c.content = resp.Returnval
+	return c, nil
+}
+
+// login starts a session, whose cookie is used for all further requests.
+func (c *client) login(username, password string) error {
+	req := struct {
+		XMLName  xml.Name `xml:"urn:vim25 Login"`
+		This     mor      `xml:"_this"`
+		Username string   `xml:"userName"`
+		Password string   `xml:"password"`
+	}{This: c.content.SessionManager, Username: username, Password: password}
+
+	return c.call(req, nil)
+}
+
+func (c *client) logout() error {
+	req := struct {
+		XMLName xml.Name `xml:"urn:vim25 Logout"`
+		This    mor      `xml:"_this"`
+	}{This: c.content.SessionManager}
+
+	return c.call(req, nil)
+}
+
+// findByInventoryPath looks up a managed object by its inventory path,
+// such as "datacenter/vm/folder". An error is returned if it isn't found.
+func (c *client) findByInventoryPath(path string) (mor, error) {
+	obj, err := c.lookupInventoryPath(path)
+	if err != nil {
+		return mor{}, err
+	}
+
+	if obj == nil {
+		return mor{}, fmt.Errorf("Not found: %s", path)
+	}
+
+	return *obj, nil
+}
+
+// lookupInventoryPath looks up a managed object by its inventory path,
+// returning nil if it doesn't exist.
+func (c *client) lookupInventoryPath(path string) (*mor, error) {
+	req := struct {
+		XMLName       xml.Name `xml:"urn:vim25 FindByInventoryPath"`
+		This          mor      `xml:"_this"`
+		InventoryPath string   `xml:"inventoryPath"`
+	}{This: c.content.SearchIndex, InventoryPath: path}
+
+	var resp struct {
+		Returnval *mor `xml:"returnval"`
+	}
+	if err := c.call(req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Returnval, nil
+}
+
+// property retrieves a single property of a managed object.
+func (c *client) property(obj mor, name string) (*propertyValue, error) {
+	type propertySpec struct {
+		Type    string `xml:"type"`
+		PathSet string `xml:"pathSet"`
+	}
+	type objectSpec struct {
+		Obj mor `xml:"obj"`
+	}
+	type filterSpec struct {
+		PropSet   propertySpec `xml:"propSet"`
+		ObjectSet objectSpec   `xml:"objectSet"`
+	}
+
+	req := struct {
+		XMLName xml.Name   `xml:"urn:vim25 RetrievePropertiesEx"`
+		This    mor        `xml:"_this"`
+		SpecSet filterSpec `xml:"specSet"`
+		Options struct{}   `xml:"options"`
+	}{
+		This: c.content.PropertyCollector,
+		SpecSet: filterSpec{
+			PropSet:   propertySpec{Type: obj.Type, PathSet: name},
+			ObjectSet: objectSpec{Obj: obj},
+		},
+	}
+
+	var resp struct {
+		Objects []struct {
+			PropSet []struct {
+				Name string        `xml:"name"`
+				Val  propertyValue `xml:"val"`
+			} `xml:"propSet"`
+		} `xml:"returnval>objects"`
+	}
+	if err := c.call(req, &resp); err != nil {
+		return nil, err
+	}
+
+	for _, o := range resp.Objects {
+		for _, p := range o.PropSet {
+			if p.Name == name {
+				return &p.Val, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("Property %s of %s not found", name, obj.Value)
+}
+
+// createImportSpec validates the OVF descriptor and turns it into an
+// import spec for the given resource pool and datastore.
+func (c *client) createImportSpec(descriptor string, pool, datastore mor, params importSpecParams) (*importSpecResult, error) {
+	req := struct {
+		XMLName       xml.Name         `xml:"urn:vim25 CreateImportSpec"`
+		This          mor              `xml:"_this"`
+		OvfDescriptor string           `xml:"ovfDescriptor"`
+		ResourcePool  mor              `xml:"resourcePool"`
+		Datastore     mor              `xml:"datastore"`
+		Cisp          importSpecParams `xml:"cisp"`
+	}{
+		This:          c.content.OvfManager,
+		OvfDescriptor: descriptor,
+		ResourcePool:  pool,
+		Datastore:     datastore,
+		Cisp:          params,
+	}
+
+	var resp struct {
+		Returnval importSpecResult `xml:"returnval"`
+	}
+	if err := c.call(req, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp.Returnval, nil
+}
+
+// importVApp creates the VM of the import spec in the folder and returns
+// the NFC lease to upload its disks with.
+func (c *client) importVApp(pool mor, spec importSpec, folder mor) (mor, error) {
+	type rawSpec struct {
+		Type  string `xml:"xsi:type,attr"`
+		Inner string `xml:",innerxml"`
+	}
+
+	req := struct {
+		XMLName xml.Name `xml:"urn:vim25 ImportVApp"`
+		This    mor      `xml:"_this"`
+		Spec    rawSpec  `xml:"spec"`
+		Folder  mor      `xml:"folder"`
+	}{
+		This:   pool,
+		Spec:   rawSpec{Type: spec.Type, Inner: spec.Inner},
+		Folder: folder,
+	}
+
+	var resp struct {
+		Returnval mor `xml:"returnval"`
+	}
+	if err := c.call(req, &resp); err != nil {
+		return mor{}, err
+	}
+
+	return resp.Returnval, nil
+}
+
+func (c *client) leaseProgress(lease mor, percent int) error {
+	req := struct {
+		XMLName xml.Name `xml:"urn:vim25 HttpNfcLeaseProgress"`
+		This    mor      `xml:"_this"`
+		Percent int      `xml:"percent"`
+	}{This: lease, Percent: percent}
+
+	return c.call(req, nil)
+}
+
+func (c *client) leaseComplete(lease mor) error {
+	req := struct {
+		XMLName xml.Name `xml:"urn:vim25 HttpNfcLeaseComplete"`
+		This    mor      `xml:"_this"`
+	}{This: lease}
+
+	return c.call(req, nil)
+}
+
+func (c *client) leaseAbort(lease mor) error {
+	req := struct {
+		XMLName xml.Name `xml:"urn:vim25 HttpNfcLeaseAbort"`
+		This    mor      `xml:"_this"`
+	}{This: lease}
+
+	return c.call(req, nil)
+}
+
+// powerOff powers off a VM and waits for it.
+func (c *client) powerOff(vm mor) error {
+	req := struct {
+		XMLName xml.Name `xml:"urn:vim25 PowerOffVM_Task"`
+		This    mor      `xml:"_this"`
+	}{This: vm}
+
+	return c.callTask(req)
+}
+
+// destroy deletes a VM, including its disks, and waits for it.
+func (c *client) destroy(vm mor) error {
+	req := struct {
+		XMLName xml.Name `xml:"urn:vim25 Destroy_Task"`
+		This    mor      `xml:"_this"`
+	}{This: vm}
+
+	return c.callTask(req)
+}
+
+// setAnnotation sets the notes of a VM and waits for it.
+func (c *client) setAnnotation(vm mor, annotation string) error {
+	type configSpec struct {
+		Annotation string `xml:"annotation"`
+	}
+
+	req := struct {
+		XMLName xml.Name   `xml:"urn:vim25 ReconfigVM_Task"`
+		This    mor        `xml:"_this"`
+		Spec    configSpec `xml:"spec"`
+	}{This: vm, Spec: configSpec{annotation}}
+
+	return c.callTask(req)
+}
+
+func (c *client) markAsTemplate(vm mor) error {
+	req := struct {
+		XMLName xml.Name `xml:"urn:vim25 MarkAsTemplate"`
+		This    mor      `xml:"_this"`
+	}{This: vm}
+
+	return c.call(req, nil)
+}
+
+// callTask calls a method that starts a task, and waits for the task to
+// complete, returning its error if it fails. It gives up waiting after
+// taskTimeout.
+func (c *client) callTask(req interface{}) error {
+	var resp struct {
+		Returnval mor `xml:"returnval"`
+	}
+	if err := c.call(req, &resp); err != nil {
+		return err
+	}
+
+	task := resp.Returnval
+	deadline := time.Now().Add(taskTimeout)
+	for {
+		info, err := c.property(task, "info")
+		if err != nil {
+			return err
+		}
+
+		switch info.State {
+		case "success":
+			return nil
+		case "error":
+			return fmt.Errorf("Task %s failed: %s", task.Value, info.Error.LocalizedMessage)
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timeout waiting for task %s, state: %s", task.Value, info.State)
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+// call sends a SOAP request, and decodes the body of the response into
+// result, if it isn't nil. SOAP faults are returned as errors.
+func (c *client) call(req interface{}, result interface{}) error {
+	body, err := xml.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(soapEnvelopeStart)
+	buf.Write(body)
+	buf.WriteString(soapEnvelopeEnd)
+
+	httpReq, err := http.NewRequest("POST", c.url.String(), &buf)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	httpReq.Header.Set("SOAPAction", "urn:vim25/5.0")
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// The response is decoded as part of the whole envelope, rather than
+	// from the body alone, so the namespaces declared on the envelope,
+	// such as xsi, are known.
+	d := xml.NewDecoder(bytes.NewReader(respBody))
+	depth := 0
+	for {
+		t, err := d.Token()
+		if err != nil {
+			log.Printf("Bad response from vSphere (HTTP %d): %s", resp.StatusCode, respBody)
+			return fmt.Errorf("Error decoding response from vSphere (HTTP %d): %s",
+				resp.StatusCode, err)
+		}
+
+		switch t := t.(type) {
+		case xml.EndElement:
+			depth--
+		case xml.StartElement:
+			depth++
+
+			// The Envelope contains the Body, which contains the
+			// response or a fault.
+			if depth < 3 {
+				continue
+			}
+
+			if t.Name.Local == "Fault" {
+				var fault struct {
+					String string `xml:"faultstring"`
+				}
+				if err := d.DecodeElement(&fault, &t); err != nil {
+					return err
+				}
+
+				return fmt.Errorf("Error from vSphere: %s", fault.String)
+			}
+
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("Unexpected response from vSphere (HTTP %d)", resp.StatusCode)
+			}
+
+			if result == nil {
+				return nil
+			}
+
+			return d.DecodeElement(result, &t)
+		}
+	}
+}
+
+// upload sends a disk to a device URL of an NFC lease, using the
+// session cookie to authenticate.
+func (c *client) upload(method string, rawURL string, body io.Reader, size int64) error {
+	// ESXi leaves the host of the URL up to the client.
+	rawURL = strings.Replace(rawURL, "://*/", fmt.Sprintf("://%s/", c.url.Host), 1)
+
+	req, err := http.NewRequest(method, rawURL, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/x-vnd.vmware-streamVmdk")
+
+	// Through vCenter, the disks are uploaded to the ESXi host directly,
+	// which the session cookie isn't sent to by default.
+	if req.URL.Host != c.url.Host {
+		for _, cookie := range c.http.Jar.Cookies(c.url) {
+			req.AddCookie(cookie)
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Error uploading %s (HTTP %d): %s", rawURL, resp.StatusCode, respBody)
+	}
+
+	return nil
+}
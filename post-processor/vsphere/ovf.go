@@ -0,0 +1,147 @@
+package vsphere
+
+import (
+	"archive/tar"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// ovfSource is an OVF package to import: an OVF descriptor and the disks
+// it refers to.
+type ovfSource interface {
+	// Descriptor returns the contents of the OVF descriptor.
+	Descriptor() (string, error)
+
+	// Open opens a file of the package by its path in the descriptor,
+	// returning its size as well.
+	Open(name string) (io.ReadCloser, int64, error)
+}
+
+// ovfDir is an OVF descriptor with its disks next to it, as created by
+// the VirtualBox builder or by ovftool.
+type ovfDir struct {
+	path string
+}
+
+func (o *ovfDir) Descriptor() (string, error) {
+	data, err := ioutil.ReadFile(o.path)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func (o *ovfDir) Open(name string) (io.ReadCloser, int64, error) {
+	f, err := os.Open(filepath.Join(filepath.Dir(o.path), name))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, fi.Size(), nil
+}
+
+// ova is an OVF package in a single tar file.
+type ova struct {
+	path string
+}
+
+func (o *ova) Descriptor() (string, error) {
+	r, _, err := o.find(func(name string) bool {
+		return strings.HasSuffix(name, ".ovf")
+	})
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func (o *ova) Open(name string) (io.ReadCloser, int64, error) {
+	return o.find(func(n string) bool {
+		return n == name
+	})
+}
+
+// find returns a reader for the first file in the tar that matches.
+func (o *ova) find(match func(string) bool) (io.ReadCloser, int64, error) {
+	f, err := os.Open(o.path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+
+		if match(hdr.Name) {
+			return &tarFile{tr, f}, hdr.Size, nil
+		}
+	}
+
+	f.Close()
+	return nil, 0, fmt.Errorf("File not found in %s", o.path)
+}
+
+// tarFile reads a file in a tar, closing the tar when it's closed.
+type tarFile struct {
+	io.Reader
+	io.Closer
+}
+
+// ovfNetworks returns the names of the networks in an OVF descriptor.
+func ovfNetworks(descriptor string) ([]string, error) {
+	var envelope struct {
+		Networks []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"NetworkSection>Network"`
+	}
+	if err := xml.Unmarshal([]byte(descriptor), &envelope); err != nil {
+		return nil, fmt.Errorf("Error parsing OVF descriptor: %s", err)
+	}
+
+	result := make([]string, len(envelope.Networks))
+	for i, n := range envelope.Networks {
+		result[i] = n.Name
+	}
+
+	return result, nil
+}
+
+// countingReader adds the bytes read to a counter, so the progress of
+// an upload can be reported while it's running.
+type countingReader struct {
+	io.Reader
+	n *int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	atomic.AddInt64(r.n, int64(n))
+	return n, err
+}
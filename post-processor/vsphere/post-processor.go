@@ -1,18 +1,34 @@
 package vsphere
 
 import (
-	"bytes"
 	"fmt"
 	"github.com/mitchellh/packer/common"
 	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"log"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 var builtins = map[string]string{
-	"mitchellh.vmware": "vmware",
+	"mitchellh.virtualbox": "virtualbox",
+	"mitchellh.vmware":     "vmware",
 }
 
+// The time between checks of the state of NFC leases and tasks, and
+// between the progress reports while uploading. A lease expires if there
+// is no progress for five minutes.
+var pollInterval = 1 * time.Second
+var leaseProgressInterval = 10 * time.Second
+
+// How long to wait for an NFC lease to become ready, or for a task to
+// complete, before giving up.
+var taskTimeout = 30 * time.Minute
+
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 
@@ -26,6 +42,10 @@ type Config struct {
 	VMFolder           string `mapstructure:"vm_folder"`
 	VMName             string `mapstructure:"vm_name"`
 	VMNetwork          string `mapstructure:"vm_network"`
+	MarkAsTemplate     bool   `mapstructure:"mark_as_template"`
+	Annotation         string `mapstructure:"annotation"`
+	DiskMode           string `mapstructure:"disk_mode"`
+	Overwrite          bool   `mapstructure:"overwrite"`
 }
 
 type PostProcessor struct {
@@ -47,11 +67,6 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 	// Accumulate any errors
 	errs := new(packer.MultiError)
 
-	if _, err := exec.LookPath("ovftool"); err != nil {
-		errs = packer.MultiErrorAppend(
-			errs, fmt.Errorf("ovftool not found: %s", err))
-	}
-
 	validates := map[string]*string{
 		"datacenter":            &p.config.Datacenter,
 		"datastore":             &p.config.Datastore,
@@ -71,6 +86,19 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 		}
 	}
 
+	p.config.Annotation, err = tpl.Process(p.config.Annotation, nil)
+	if err != nil {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("Error processing annotation: %s", err))
+	}
+
+	switch p.config.DiskMode {
+	case "", "thin", "thick":
+	default:
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("disk_mode must be \"thin\" or \"thick\""))
+	}
+
 	if len(errs.Errors) > 0 {
 		return errs
 	}
@@ -83,43 +111,348 @@ func (p *PostProcessor) PostProcess(ui packer.Ui, artifact packer.Artifact) (pac
 		return nil, false, fmt.Errorf("Unknown artifact type, can't build box: %s", artifact.BuilderId())
 	}
 
+	var source ovfSource
 	vmx := ""
 	for _, path := range artifact.Files() {
-		if strings.HasSuffix(path, ".vmx") {
+		switch filepath.Ext(path) {
+		case ".ovf":
+			source = &ovfDir{path}
+		case ".ova":
+			source = &ova{path}
+		case ".vmx":
 			vmx = path
+		}
+	}
+
+	if source == nil {
+		if vmx == "" {
+			return nil, false, fmt.Errorf("No OVF, OVA or VMX file found")
+		}
+
+		// A VM from the VMware builder that wasn't exported is converted
+		// to an OVF first, which needs ovftool. Without ovftool, the
+		// builder's format has to be "ovf" or "ova".
+		ui.Message(fmt.Sprintf("Converting %s to OVF with ovftool", vmx))
+		dir, err := ioutil.TempDir("", "packer-vsphere")
+		if err != nil {
+			return nil, false, err
+		}
+		defer os.RemoveAll(dir)
+
+		ovf := filepath.Join(dir, p.config.VMName+".ovf")
+		if err := convertVMX(vmx, ovf); err != nil {
+			return nil, false, err
+		}
+
+		source = &ovfDir{ovf}
+	}
+
+	ui.Message(fmt.Sprintf("Uploading %s to vSphere", p.config.VMName))
+	c, err := newClient(p.config.Host, p.config.Insecure)
+	if err != nil {
+		return nil, false, fmt.Errorf("Error connecting to vSphere: %s", err)
+	}
+
+	if err := c.login(p.config.Username, p.config.Password); err != nil {
+		return nil, false, fmt.Errorf("Error logging in to vSphere: %s", err)
+	}
+	defer c.logout()
+
+	path := p.inventoryPath("vm", p.config.VMFolder, p.config.VMName)
+	if err := p.removeExisting(ui, c, path); err != nil {
+		return nil, false, err
+	}
+
+	vm, err := p.importOVF(ui, c, source)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if p.config.Annotation != "" {
+		ui.Message("Setting the annotation of the VM")
+		if err := c.setAnnotation(vm, p.config.Annotation); err != nil {
+			return nil, false, fmt.Errorf("Error setting annotation: %s", err)
+		}
+	}
+
+	if p.config.MarkAsTemplate {
+		ui.Message("Marking the VM as a template")
+		if err := c.markAsTemplate(vm); err != nil {
+			return nil, false, fmt.Errorf("Error marking VM as template: %s", err)
+		}
+	}
+
+	return NewArtifact(path, p.config), false, nil
+}
+
+// removeExisting destroys the VM at the path if it exists and overwrite
+// is set. If it exists and overwrite isn't set, an error is returned.
+func (p *PostProcessor) removeExisting(ui packer.Ui, c *client, path string) error {
+	vm, err := c.lookupInventoryPath(path)
+	if err != nil {
+		return err
+	}
+
+	if vm == nil {
+		return nil
+	}
+
+	if !p.config.Overwrite {
+		return fmt.Errorf("VM already exists: %s. Set overwrite to replace it.", path)
+	}
+
+	ui.Message(fmt.Sprintf("Destroying the existing VM: %s", path))
+	state, err := c.property(*vm, "runtime.powerState")
+	if err != nil {
+		return err
+	}
+
+	if state.Text == "poweredOn" {
+		if err := c.powerOff(*vm); err != nil {
+			return fmt.Errorf("Error powering off existing VM: %s", err)
+		}
+	}
+
+	if err := c.destroy(*vm); err != nil {
+		return fmt.Errorf("Error destroying existing VM: %s", err)
+	}
+
+	return nil
+}
+
+// importOVF imports the OVF package as a new VM, uploading its disks
+// over an NFC lease, and returns the VM.
+func (p *PostProcessor) importOVF(ui packer.Ui, c *client, source ovfSource) (mor, error) {
+	pool, err := p.resourcePool(c)
+	if err != nil {
+		return mor{}, err
+	}
+
+	datastore, err := c.findByInventoryPath(p.inventoryPath("datastore", p.config.Datastore))
+	if err != nil {
+		return mor{}, fmt.Errorf("Error finding datastore: %s", err)
+	}
+
+	folder, err := c.findByInventoryPath(p.inventoryPath("vm", p.config.VMFolder))
+	if err != nil {
+		return mor{}, fmt.Errorf("Error finding VM folder: %s", err)
+	}
+
+	network, err := c.findByInventoryPath(p.inventoryPath("network", p.config.VMNetwork))
+	if err != nil {
+		return mor{}, fmt.Errorf("Error finding network: %s", err)
+	}
+
+	descriptor, err := source.Descriptor()
+	if err != nil {
+		return mor{}, fmt.Errorf("Error reading OVF descriptor: %s", err)
+	}
+
+	networks, err := ovfNetworks(descriptor)
+	if err != nil {
+		return mor{}, err
+	}
+
+	// Every network of the OVF is mapped to the configured network
+	params := importSpecParams{
+		EntityName:       p.config.VMName,
+		DiskProvisioning: p.config.DiskMode,
+	}
+	for _, n := range networks {
+		params.NetworkMapping = append(params.NetworkMapping, networkMapping{
+			Name:    n,
+			Network: network,
+		})
+	}
+
+	spec, err := c.createImportSpec(descriptor, pool, datastore, params)
+	if err != nil {
+		return mor{}, fmt.Errorf("Error creating import spec: %s", err)
+	}
+
+	for _, w := range spec.Warning {
+		ui.Message(fmt.Sprintf("Warning: %s", w.LocalizedMessage))
+	}
+
+	if len(spec.Error) > 0 {
+		messages := make([]string, len(spec.Error))
+		for i, e := range spec.Error {
+			messages[i] = e.LocalizedMessage
+		}
+
+		return mor{}, fmt.Errorf("Invalid OVF: %s", strings.Join(messages, "; "))
+	}
+
+	lease, err := c.importVApp(pool, spec.ImportSpec, folder)
+	if err != nil {
+		return mor{}, fmt.Errorf("Error importing VM: %s", err)
+	}
+
+	vm, err := p.upload(ui, c, lease, source, spec.FileItem)
+	if err != nil {
+		if abortErr := c.leaseAbort(lease); abortErr != nil {
+			log.Printf("Error aborting lease: %s", abortErr)
+		}
+
+		return mor{}, err
+	}
+
+	if err := c.leaseComplete(lease); err != nil {
+		return mor{}, fmt.Errorf("Error completing import: %s", err)
+	}
+
+	return vm, nil
+}
+
+// upload waits for the lease to be ready and uploads the files to its
+// device URLs, returning the VM being imported.
+func (p *PostProcessor) upload(ui packer.Ui, c *client, lease mor, source ovfSource, items []fileItem) (mor, error) {
+	deadline := time.Now().Add(taskTimeout)
+	for {
+		state, err := c.property(lease, "state")
+		if err != nil {
+			return mor{}, err
+		}
+
+		if state.Text == "ready" {
 			break
 		}
+
+		if state.Text == "error" {
+			leaseErr, err := c.property(lease, "error")
+			if err != nil {
+				return mor{}, err
+			}
+
+			return mor{}, fmt.Errorf("Error importing VM: %s", leaseErr.LocalizedMessage)
+		}
+
+		if time.Now().After(deadline) {
+			return mor{}, fmt.Errorf(
+				"Timeout waiting for the import of the VM to start, lease state: %s", state.Text)
+		}
+
+		time.Sleep(pollInterval)
 	}
 
-	if vmx == "" {
-		return nil, false, fmt.Errorf("VMX file not found")
+	info, err := c.property(lease, "info")
+	if err != nil {
+		return mor{}, err
 	}
 
-	ui.Message(fmt.Sprintf("Uploading %s to vSphere", vmx))
+	var total, uploaded int64
+	for _, item := range items {
+		total += item.Size
+	}
 
-	args := []string{
-		fmt.Sprintf("--noSSLVerify=%t", p.config.Insecure),
-		"--acceptAllEulas",
-		fmt.Sprintf("--name=%s", p.config.VMName),
-		fmt.Sprintf("--datastore=%s", p.config.Datastore),
-		fmt.Sprintf("--network=%s", p.config.VMNetwork),
-		fmt.Sprintf("--vmFolder=%s", p.config.VMFolder),
-		fmt.Sprintf("vi://%s:%s@%s/%s/%s",
-			p.config.Username,
-			p.config.Password,
-			p.config.Host,
-			p.config.Datacenter,
-			p.config.PathToResourcePool),
+	// Report the progress while uploading, to keep the lease alive
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(leaseProgressInterval):
+			}
+
+			percent := 0
+			if total > 0 {
+				percent = int(atomic.LoadInt64(&uploaded) * 100 / total)
+			}
+			if err := c.leaseProgress(lease, percent); err != nil {
+				log.Printf("Error reporting progress: %s", err)
+			}
+		}
+	}()
+
+	for _, item := range items {
+		url := ""
+		for _, d := range info.DeviceURL {
+			if d.ImportKey == item.DeviceId {
+				url = d.URL
+				break
+			}
+		}
+
+		if url == "" {
+			return mor{}, fmt.Errorf("No upload URL for %s", item.Path)
+		}
+
+		if err := uploadItem(ui, c, source, item, url, &uploaded); err != nil {
+			return mor{}, err
+		}
 	}
 
-	var out bytes.Buffer
-	cmd := exec.Command("ovftool", args...)
-	cmd.Stdout = &out
-	if err := cmd.Run(); err != nil {
-		return nil, false, fmt.Errorf("Failed: %s\nStdout: %s", err, out.String())
+	return info.Entity, nil
+}
+
+// resourcePool finds the resource pool at the configured path. The path
+// may also point to a host or cluster, whose root resource pool is used.
+func (p *PostProcessor) resourcePool(c *client) (mor, error) {
+	obj, err := c.findByInventoryPath(p.inventoryPath(p.config.PathToResourcePool))
+	if err != nil {
+		return mor{}, fmt.Errorf("Error finding resource pool: %s", err)
+	}
+
+	if obj.Type == "ResourcePool" || obj.Type == "VirtualApp" {
+		return obj, nil
 	}
 
-	ui.Message(fmt.Sprintf("%s", out.String()))
+	pool, err := c.property(obj, "resourcePool")
+	if err != nil {
+		return mor{}, fmt.Errorf("Error finding resource pool of %s: %s", obj.Value, err)
+	}
 
-	return artifact, false, nil
+	return mor{Type: "ResourcePool", Value: pool.Text}, nil
+}
+
+// inventoryPath returns the inventory path of an object within the
+// datacenter.
+func (p *PostProcessor) inventoryPath(parts ...string) string {
+	result := []string{strings.Trim(p.config.Datacenter, "/")}
+	for _, part := range parts {
+		if part = strings.Trim(part, "/"); part != "" {
+			result = append(result, part)
+		}
+	}
+
+	return strings.Join(result, "/")
+}
+
+func uploadItem(ui packer.Ui, c *client, source ovfSource, item fileItem, url string, uploaded *int64) error {
+	r, size, err := source.Open(item.Path)
+	if err != nil {
+		return fmt.Errorf("Error opening %s: %s", item.Path, err)
+	}
+	defer r.Close()
+
+	ui.Message(fmt.Sprintf("Uploading %s (%d bytes)", item.Path, size))
+
+	// New files are PUT, and existing ones POSTed
+	method := "POST"
+	if item.Create {
+		method = "PUT"
+	}
+
+	return c.upload(method, url, &countingReader{r, uploaded}, size)
+}
+
+// convertVMX converts a VMX to an OVF with ovftool. Packer can't do the
+// conversion itself, so this is the only part of the post-processor that
+// needs ovftool.
+func convertVMX(vmx string, ovf string) error {
+	if _, err := exec.LookPath("ovftool"); err != nil {
+		return fmt.Errorf(
+			"The artifact only contains a VMX, which needs ovftool to be converted "+
+				"to an OVF, and ovftool wasn't found: %s. Set the format of the "+
+				"vmware builder to \"ovf\" or \"ova\" to upload without ovftool.", err)
+	}
+
+	out, err := exec.Command("ovftool", "--acceptAllEulas", vmx, ovf).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Error converting VMX to OVF: %s\nOutput: %s", err, out)
+	}
+
+	return nil
 }
@@ -1 +1,547 @@
 package vsphere
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/mitchellh/packer/packer"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func init() {
+	pollInterval = 10 * time.Millisecond
+}
+
+const testDescriptor = `<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1">
+  <References>
+    <File ovf:href="disk.vmdk" ovf:id="file1"/>
+  </References>
+  <NetworkSection>
+    <Info>The list of logical networks</Info>
+    <Network ovf:name="NAT"/>
+  </NetworkSection>
+</Envelope>`
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"datacenter":            "dc",
+		"datastore":             "datastore1",
+		"host":                  "vcenter",
+		"password":              "secret",
+		"path_to_resource_pool": "host/cluster",
+		"username":              "root",
+		"vm_folder":             "packer",
+		"vm_name":               "packer-vm",
+		"vm_network":            "VM Network",
+	}
+}
+
+func testUi() *packer.BasicUi {
+	return &packer.BasicUi{
+		Reader: new(bytes.Buffer),
+		Writer: new(bytes.Buffer),
+	}
+}
+
+type testArtifact struct {
+	builderId string
+	files     []string
+}
+
+func (a *testArtifact) BuilderId() string { return a.builderId }
+func (a *testArtifact) Files() []string   { return a.files }
+func (*testArtifact) Id() string          { return "id" }
+func (*testArtifact) String() string      { return "string" }
+func (*testArtifact) Destroy() error      { return nil }
+
+// fakeVSphere is a simulator of the parts of the vSphere API that are
+// used to import an OVF. It records the SOAP methods called and the
+// disks uploaded.
+type fakeVSphere struct {
+	sync.Mutex
+
+	inventory   map[string]string
+	leaseStates []string
+	taskState   string
+	calls       []string
+	requests    map[string]string
+	uploads     map[string][]byte
+}
+
+var inventoryPathRe = regexp.MustCompile(`<inventoryPath>(.*)</inventoryPath>`)
+var pathSetRe = regexp.MustCompile(`<pathSet>(.*)</pathSet>`)
+var objRe = regexp.MustCompile(`<obj type="(\w+)">`)
+
+func newFakeVSphere() *fakeVSphere {
+	return &fakeVSphere{
+		inventory: map[string]string{
+			"dc/host/cluster":         `type="ClusterComputeResource">domain-c7`,
+			"dc/datastore/datastore1": `type="Datastore">datastore-11`,
+			"dc/vm/packer":            `type="Folder">group-v22`,
+			"dc/network/VM Network":   `type="Network">network-13`,
+		},
+		leaseStates: []string{"initializing", "ready"},
+		taskState:   "success",
+		requests:    make(map[string]string),
+		uploads:     make(map[string][]byte),
+	}
+}
+
+func (f *fakeVSphere) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	if strings.HasPrefix(r.URL.Path, "/nfc/") {
+		f.uploads[r.Method+" "+r.URL.Path] = body
+		return
+	}
+
+	method, err := soapMethod(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.calls = append(f.calls, method)
+	f.requests[method] = string(body)
+
+	var result string
+	switch method {
+	case "RetrieveServiceContent":
+		result = `<returnval>
+  <propertyCollector type="PropertyCollector">propertyCollector</propertyCollector>
+  <searchIndex type="SearchIndex">SearchIndex</searchIndex>
+  <sessionManager type="SessionManager">SessionManager</sessionManager>
+  <ovfManager type="OvfManager">OvfManager</ovfManager>
+</returnval>`
+	case "Login":
+		http.SetCookie(w, &http.Cookie{Name: "vmware_soap_session", Value: "session"})
+		result = `<returnval><userName>root</userName></returnval>`
+	case "FindByInventoryPath":
+		path := inventoryPathRe.FindStringSubmatch(string(body))[1]
+		if obj, ok := f.inventory[path]; ok {
+			result = `<returnval ` + obj + `</returnval>`
+		}
+	case "RetrievePropertiesEx":
+		var val string
+		name := pathSetRe.FindStringSubmatch(string(body))[1]
+		switch name {
+		case "resourcePool":
+			val = `<val type="ResourcePool" xsi:type="ManagedObjectReference">resgroup-8</val>`
+		case "state":
+			val = `<val xsi:type="HttpNfcLeaseState">` + f.leaseStates[0] + `</val>`
+			if len(f.leaseStates) > 1 {
+				f.leaseStates = f.leaseStates[1:]
+			}
+		case "error":
+			val = `<val xsi:type="LocalizedMethodFault"><localizedMessage>Out of space</localizedMessage></val>`
+		case "runtime.powerState":
+			val = `<val xsi:type="VirtualMachinePowerState">poweredOn</val>`
+		case "info":
+			if objRe.FindStringSubmatch(string(body))[1] == "Task" {
+				val = `<val xsi:type="TaskInfo"><key>task-1</key><state>` + f.taskState + `</state></val>`
+				break
+			}
+
+			val = `<val xsi:type="HttpNfcLeaseInfo">
+  <lease type="HttpNfcLease">lease-1</lease>
+  <entity type="VirtualMachine">vm-42</entity>
+  <deviceUrl>
+    <key>/vm-42/VirtualLsiLogicController0:0</key>
+    <importKey>/packer-vm/VirtualLsiLogicController0:0</importKey>
+    <url>https://*/nfc/52a1/disk-0.vmdk</url>
+  </deviceUrl>
+</val>`
+		}
+		result = `<returnval><objects><propSet><name>` + name + `</name>` + val +
+			`</propSet></objects></returnval>`
+	case "CreateImportSpec":
+		result = `<returnval>
+  <importSpec xsi:type="VirtualMachineImportSpec"><configSpec><name>packer-vm</name></configSpec></importSpec>
+  <fileItem>
+    <deviceId>/packer-vm/VirtualLsiLogicController0:0</deviceId>
+    <path>disk.vmdk</path>
+    <size>4</size>
+    <create>true</create>
+  </fileItem>
+</returnval>`
+	case "ImportVApp":
+		result = `<returnval type="HttpNfcLease">lease-1</returnval>`
+	case "PowerOffVM_Task", "Destroy_Task", "ReconfigVM_Task":
+		result = `<returnval type="Task">task-1</returnval>`
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+<soapenv:Body><%sResponse xmlns="urn:vim25">%s</%sResponse></soapenv:Body>
+</soapenv:Envelope>`, method, result, method)
+}
+
+// soapMethod returns the name of the method of a SOAP request, which is
+// the first element in its body.
+func soapMethod(body []byte) (string, error) {
+	d := xml.NewDecoder(bytes.NewReader(body))
+	depth := 0
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+
+		if se, ok := t.(xml.StartElement); ok {
+			if depth == 2 {
+				return se.Name.Local, nil
+			}
+			depth++
+		}
+	}
+}
+
+func testVSphere(t *testing.T) (*fakeVSphere, map[string]interface{}, func()) {
+	fake := newFakeVSphere()
+	server := httptest.NewTLSServer(fake)
+
+	config := testConfig()
+	config["host"] = strings.TrimPrefix(server.URL, "https://")
+	config["insecure"] = true
+	return fake, config, server.Close
+}
+
+func testOVF(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	ovf := filepath.Join(dir, "packer.ovf")
+	if err := ioutil.WriteFile(ovf, []byte(testDescriptor), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "disk.vmdk"), []byte("disk"), 0644); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	return ovf
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessorConfigure(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	p = PostProcessor{}
+	c := testConfig()
+	delete(c, "vm_name")
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorConfigure_DiskMode(t *testing.T) {
+	for _, mode := range []string{"thin", "thick"} {
+		var p PostProcessor
+		c := testConfig()
+		c["disk_mode"] = mode
+		if err := p.Configure(c); err != nil {
+			t.Fatalf("%s: err: %s", mode, err)
+		}
+	}
+
+	var p PostProcessor
+	c := testConfig()
+	c["disk_mode"] = "sparse"
+	if err := p.Configure(c); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorPostProcess_badArtifact(t *testing.T) {
+	var p PostProcessor
+	if err := p.Configure(testConfig()); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{builderId: "packer.docker", files: []string{"foo.ovf"}}
+	if _, _, err := p.PostProcess(testUi(), artifact); err == nil {
+		t.Fatal("should have error")
+	}
+
+	artifact = &testArtifact{builderId: "mitchellh.virtualbox", files: []string{"foo.vdi"}}
+	if _, _, err := p.PostProcess(testUi(), artifact); err == nil {
+		t.Fatal("should have error")
+	}
+}
+
+func TestPostProcessorPostProcess_ovf(t *testing.T) {
+	fake, config, closeFn := testVSphere(t)
+	defer closeFn()
+
+	ovf := testOVF(t)
+	defer os.RemoveAll(filepath.Dir(ovf))
+
+	var p PostProcessor
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{
+		builderId: "mitchellh.virtualbox",
+		files:     []string{ovf, filepath.Join(filepath.Dir(ovf), "disk.vmdk")},
+	}
+	result, _, err := p.PostProcess(testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if result.BuilderId() != BuilderId {
+		t.Fatalf("bad: %s", result.BuilderId())
+	}
+	if result.Id() != "dc/vm/packer/packer-vm" {
+		t.Fatalf("bad: %s", result.Id())
+	}
+
+	expected := []string{
+		"RetrieveServiceContent", "Login", "FindByInventoryPath",
+		"FindByInventoryPath", "RetrievePropertiesEx",
+		"FindByInventoryPath", "FindByInventoryPath", "FindByInventoryPath",
+		"CreateImportSpec", "ImportVApp",
+		"RetrievePropertiesEx", "RetrievePropertiesEx", "RetrievePropertiesEx",
+		"HttpNfcLeaseComplete", "Logout",
+	}
+	if strings.Join(fake.calls, ",") != strings.Join(expected, ",") {
+		t.Fatalf("bad: %#v", fake.calls)
+	}
+
+	createImportSpec := fake.requests["CreateImportSpec"]
+	for _, s := range []string{
+		`<resourcePool type="ResourcePool">resgroup-8</resourcePool>`,
+		`<datastore type="Datastore">datastore-11</datastore>`,
+		`<entityName>packer-vm</entityName>`,
+		`<networkMapping><name>NAT</name><network type="Network">network-13</network></networkMapping>`,
+	} {
+		if !strings.Contains(createImportSpec, s) {
+			t.Fatalf("bad: %s", createImportSpec)
+		}
+	}
+
+	importVApp := fake.requests["ImportVApp"]
+	if !strings.Contains(importVApp, `<spec xsi:type="VirtualMachineImportSpec"><configSpec><name>packer-vm</name></configSpec></spec>`) ||
+		!strings.Contains(importVApp, `<folder type="Folder">group-v22</folder>`) {
+		t.Fatalf("bad: %s", importVApp)
+	}
+
+	if string(fake.uploads["PUT /nfc/52a1/disk-0.vmdk"]) != "disk" {
+		t.Fatalf("bad: %#v", fake.uploads)
+	}
+}
+
+func TestPostProcessorPostProcess_ova(t *testing.T) {
+	fake, config, closeFn := testVSphere(t)
+	defer closeFn()
+
+	tf, err := ioutil.TempFile("", "packer")
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(tf.Name())
+
+	tw := tar.NewWriter(tf)
+	for _, f := range []struct{ name, data string }{
+		{"packer.ovf", testDescriptor},
+		{"disk.vmdk", "disk"},
+	} {
+		tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data))})
+		tw.Write([]byte(f.data))
+	}
+	tw.Close()
+	tf.Close()
+
+	ova := tf.Name() + ".ova"
+	if err := os.Rename(tf.Name(), ova); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	defer os.Remove(ova)
+
+	var p PostProcessor
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{builderId: "mitchellh.vmware", files: []string{ova}}
+	if _, _, err := p.PostProcess(testUi(), artifact); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if string(fake.uploads["PUT /nfc/52a1/disk-0.vmdk"]) != "disk" {
+		t.Fatalf("bad: %#v", fake.uploads)
+	}
+}
+
+func TestPostProcessorPostProcess_leaseError(t *testing.T) {
+	fake, config, closeFn := testVSphere(t)
+	defer closeFn()
+	fake.leaseStates = []string{"error"}
+
+	ovf := testOVF(t)
+	defer os.RemoveAll(filepath.Dir(ovf))
+
+	var p PostProcessor
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{builderId: "mitchellh.virtualbox", files: []string{ovf}}
+	_, _, err := p.PostProcess(testUi(), artifact)
+	if err == nil {
+		t.Fatal("should have error")
+	}
+	if !strings.Contains(err.Error(), "Out of space") {
+		t.Fatalf("bad: %s", err)
+	}
+
+	if _, ok := fake.requests["HttpNfcLeaseAbort"]; !ok {
+		t.Fatalf("lease should be aborted: %#v", fake.calls)
+	}
+	if len(fake.uploads) != 0 {
+		t.Fatalf("bad: %#v", fake.uploads)
+	}
+}
+
+func TestPostProcessorPostProcess_template(t *testing.T) {
+	fake, config, closeFn := testVSphere(t)
+	defer closeFn()
+
+	ovf := testOVF(t)
+	defer os.RemoveAll(filepath.Dir(ovf))
+
+	var p PostProcessor
+	config["mark_as_template"] = true
+	config["annotation"] = "Built by Packer"
+	config["disk_mode"] = "thin"
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{builderId: "mitchellh.virtualbox", files: []string{ovf}}
+	result, _, err := p.PostProcess(testUi(), artifact)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if !strings.HasPrefix(result.String(), "Template") {
+		t.Fatalf("bad: %s", result.String())
+	}
+
+	if !strings.Contains(fake.requests["CreateImportSpec"], "<diskProvisioning>thin</diskProvisioning>") {
+		t.Fatalf("bad: %s", fake.requests["CreateImportSpec"])
+	}
+
+	reconfig := fake.requests["ReconfigVM_Task"]
+	if !strings.Contains(reconfig, `<_this type="VirtualMachine">vm-42</_this>`) ||
+		!strings.Contains(reconfig, "<spec><annotation>Built by Packer</annotation></spec>") {
+		t.Fatalf("bad: %s", reconfig)
+	}
+
+	if !strings.Contains(fake.requests["MarkAsTemplate"], `<_this type="VirtualMachine">vm-42</_this>`) {
+		t.Fatalf("bad: %#v", fake.calls)
+	}
+}
+
+func TestPostProcessorPostProcess_exists(t *testing.T) {
+	fake, config, closeFn := testVSphere(t)
+	defer closeFn()
+	fake.inventory["dc/vm/packer/packer-vm"] = `type="VirtualMachine">vm-7`
+
+	ovf := testOVF(t)
+	defer os.RemoveAll(filepath.Dir(ovf))
+
+	var p PostProcessor
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{builderId: "mitchellh.virtualbox", files: []string{ovf}}
+	if _, _, err := p.PostProcess(testUi(), artifact); err == nil {
+		t.Fatal("should have error")
+	}
+
+	if _, ok := fake.requests["ImportVApp"]; ok {
+		t.Fatalf("bad: %#v", fake.calls)
+	}
+}
+
+func TestPostProcessorPostProcess_overwrite(t *testing.T) {
+	fake, config, closeFn := testVSphere(t)
+	defer closeFn()
+	fake.inventory["dc/vm/packer/packer-vm"] = `type="VirtualMachine">vm-7`
+
+	ovf := testOVF(t)
+	defer os.RemoveAll(filepath.Dir(ovf))
+
+	var p PostProcessor
+	config["overwrite"] = true
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{builderId: "mitchellh.virtualbox", files: []string{ovf}}
+	if _, _, err := p.PostProcess(testUi(), artifact); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	for _, method := range []string{"PowerOffVM_Task", "Destroy_Task"} {
+		if !strings.Contains(fake.requests[method], `<_this type="VirtualMachine">vm-7</_this>`) {
+			t.Fatalf("bad: %#v", fake.calls)
+		}
+	}
+
+	if _, ok := fake.requests["ImportVApp"]; !ok {
+		t.Fatalf("bad: %#v", fake.calls)
+	}
+}
+
+func TestPostProcessorPostProcess_taskTimeout(t *testing.T) {
+	fake, config, closeFn := testVSphere(t)
+	defer closeFn()
+	fake.inventory["dc/vm/packer/packer-vm"] = `type="VirtualMachine">vm-7`
+	fake.taskState = "running"
+
+	defer func(old time.Duration) { taskTimeout = old }(taskTimeout)
+	taskTimeout = 50 * time.Millisecond
+
+	ovf := testOVF(t)
+	defer os.RemoveAll(filepath.Dir(ovf))
+
+	var p PostProcessor
+	config["overwrite"] = true
+	if err := p.Configure(config); err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	artifact := &testArtifact{builderId: "mitchellh.virtualbox", files: []string{ovf}}
+	_, _, err := p.PostProcess(testUi(), artifact)
+	if err == nil || !strings.Contains(err.Error(), "Timeout") {
+		t.Fatalf("bad: %v", err)
+	}
+
+	if _, ok := fake.requests["ImportVApp"]; ok {
+		t.Fatalf("bad: %#v", fake.calls)
+	}
+}
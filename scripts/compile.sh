@@ -38,7 +38,7 @@ gox \
     -arch="${XC_ARCH}" \
     -ldflags "-X github.com/mitchellh/packer/packer.GitCommit ${GIT_COMMIT}${GIT_DIRTY}" \
     -output "pkg/{{.OS}}_{{.Arch}}/packer-{{.Dir}}" \
-    ./...
+    .
 
 # Make sure "packer-packer" is renamed properly
 for PLATFORM in $(find ./pkg -mindepth 1 -maxdepth 1 -type d); do